@@ -0,0 +1,19 @@
+package client
+
+import (
+	"golang-fiber-poc/pkg/di"
+	"net/http"
+)
+
+// Module provides the clients of the downstream service, which share one transport
+func Module(c *di.Container) {
+	di.Provide(c, func(*di.Container) (*http.Transport, error) {
+		return NewTransport(), nil
+	})
+	di.Provide(c, func(c *di.Container) (CustomHttpClient, error) {
+		return NewHttpClient(di.MustGet[*http.Transport](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (CustomRetryableClient, error) {
+		return NewRetryableClient(di.MustGet[*http.Transport](c)), nil
+	})
+}
@@ -0,0 +1,254 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/config"
+	"io"
+	"mime"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+type BulkItemResult struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+func (r *BulkResponse) add(result BulkItemResult) {
+	if result.Error == "" {
+		r.Succeeded++
+	} else {
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// bulkBody holds the items of a bulk request. A JSON body may be a bare array or {"items": [...]},
+// an NDJSON body is decoded lazily so large imports are written batch by batch instead of being buffered whole.
+type bulkBody[T any] struct {
+	items   []T
+	decoder *json.Decoder
+}
+
+func (b *bulkBody[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &b.items)
+	}
+
+	var envelope struct {
+		Items []T `json:"items"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	b.items = envelope.Items
+	return nil
+}
+
+func (b *bulkBody[T]) BindBody(contentType string, body io.Reader) (bool, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != ndjsonContentType {
+		return false, nil
+	}
+
+	b.decoder = json.NewDecoder(body)
+	return true, nil
+}
+
+// next returns up to n items. It returns an empty slice once the body is exhausted.
+func (b *bulkBody[T]) next(n int) ([]T, error) {
+	if b.decoder == nil {
+		batch := b.items[:min(n, len(b.items))]
+		b.items = b.items[len(batch):]
+		return batch, nil
+	}
+
+	batch := make([]T, 0, n)
+	for len(batch) < n {
+		var item T
+		if err := b.decoder.Decode(&item); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return batch, err
+		}
+		batch = append(batch, item)
+	}
+	return batch, nil
+}
+
+// all drains the body, used by all-or-nothing requests that need every item up front. It fails with 413
+// once the body has more than limit items, without reading the rest.
+func (b *bulkBody[T]) all(limit int) ([]T, error) {
+	tooMany := fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("atomic requests take at most %d items", limit))
+	if b.decoder == nil {
+		if len(b.items) > limit {
+			return nil, tooMany
+		}
+		return b.next(len(b.items))
+	}
+
+	var items []T
+	for {
+		batch, err := b.next(min(512, limit+1-len(items)))
+		items = append(items, batch...)
+		switch {
+		case len(items) > limit:
+			return nil, tooMany
+		case err != nil || len(batch) == 0:
+			return items, err
+		}
+	}
+}
+
+// runBatches splits items into batches of batchSize and hands at most concurrency of them
+// to write at the same time. It returns one error per item, in input order.
+func runBatches[T any](items []T, batchSize int, concurrency int, write func(batch []T) []error) []error {
+	errs := make([]error, len(items))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for start := 0; start < len(items); start += batchSize {
+		end := min(start+batchSize, len(items))
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			copy(errs[start:end], write(items[start:end]))
+		}(start, end)
+	}
+
+	wg.Wait()
+	return errs
+}
+
+func bulkStatus(err error, successStatus int) int {
+	switch {
+	case err == nil:
+		return successStatus
+	case errors.Is(err, domain.ErrProductNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, domain.ErrProductAlreadyExists):
+		return fiber.StatusConflict
+	case errors.Is(err, domain.ErrReservedProductID):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrBulkRolledBack):
+		return fiber.StatusFailedDependency
+	case errors.Is(err, domain.ErrStoreUnavailable):
+		return fiber.StatusServiceUnavailable
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+func bulkResult(index int, id string, err error, successStatus int) BulkItemResult {
+	result := BulkItemResult{Index: index, ID: id, Status: bulkStatus(err, successStatus)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// writeBulk reads the body round by round, turns each item into a value with prepare and hands the
+// values to write in concurrent batches. All-or-nothing requests are read whole and written in one call.
+func writeBulk[T, V any](
+	body *bulkBody[T],
+	atomic bool,
+	bulkConfig config.BulkConfig,
+	successStatus int,
+	prepare func(item T) (string, V, error),
+	write func(values []V, atomic bool) []error,
+) (*BulkResponse, error) {
+	response := &BulkResponse{Results: []BulkItemResult{}}
+
+	if atomic {
+		items, err := body.all(bulkConfig.MaxAtomicItems)
+		if err != nil {
+			return nil, err
+		}
+
+		results, values := prepareBulk(items, 0, prepare)
+		if len(values) < len(items) {
+			// Nothing is written when any item is invalid
+			for _, result := range results {
+				if result.Error == "" {
+					result = bulkResult(result.Index, result.ID, domain.ErrBulkRolledBack, successStatus)
+				}
+				response.add(result)
+			}
+			return response, nil
+		}
+
+		collect(response, results, write(values, true), successStatus)
+		return response, nil
+	}
+
+	roundSize := bulkConfig.BatchSize * bulkConfig.Concurrency
+	for index := 0; ; {
+		items, readErr := body.next(roundSize)
+
+		results, values := prepareBulk(items, index, prepare)
+		errs := runBatches(values, bulkConfig.BatchSize, bulkConfig.Concurrency, func(batch []V) []error {
+			return write(batch, false)
+		})
+		collect(response, results, errs, successStatus)
+		index += len(items)
+
+		if readErr != nil {
+			response.add(BulkItemResult{Index: index, Status: fiber.StatusBadRequest, Error: readErr.Error()})
+			return response, nil
+		}
+		if len(items) < roundSize {
+			return response, nil
+		}
+	}
+}
+
+// prepareBulk converts items to values. Items that fail to convert get a 400 result right away,
+// the others get a placeholder result that collect fills in once they are written.
+func prepareBulk[T, V any](items []T, offset int, prepare func(item T) (string, V, error)) ([]BulkItemResult, []V) {
+	results := make([]BulkItemResult, len(items))
+	values := make([]V, 0, len(items))
+
+	for i, item := range items {
+		id, value, err := prepare(item)
+		results[i] = BulkItemResult{Index: offset + i, ID: id}
+		if err != nil {
+			results[i].Status = fiber.StatusBadRequest
+			results[i].Error = err.Error()
+			continue
+		}
+		values = append(values, value)
+	}
+
+	return results, values
+}
+
+func collect(response *BulkResponse, results []BulkItemResult, errs []error, successStatus int) {
+	written := 0
+	for _, result := range results {
+		if result.Error == "" {
+			result = bulkResult(result.Index, result.ID, errs[written], successStatus)
+			written++
+		}
+		response.add(result)
+	}
+}
@@ -0,0 +1,46 @@
+package product
+
+import (
+	"context"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/config"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type BulkCreateProductRequest struct {
+	bulkBody[CreateProductRequest]
+	Atomic bool `query:"atomic"`
+}
+
+type BulkCreateProductHandler struct {
+	repository BulkRepository
+	events     EventPublisher
+	config     config.BulkConfig
+}
+
+func NewBulkCreateProductHandler(repository BulkRepository, events EventPublisher, bulkConfig config.BulkConfig) *BulkCreateProductHandler {
+	return &BulkCreateProductHandler{repository: repository, events: events, config: bulkConfig.WithDefaults()}
+}
+
+func (h *BulkCreateProductHandler) Handle(ctx context.Context, req *BulkCreateProductRequest) (*BulkResponse, error) {
+	prepare := func(item CreateProductRequest) (string, *domain.Product, error) {
+		product := &domain.Product{
+			ID:       uuid.New().String(),
+			Name:     item.Name,
+			Category: item.Category,
+			Tags:     item.Tags,
+		}
+		return product.ID, product, nil
+	}
+
+	write := func(products []*domain.Product, atomic bool) []error {
+		events := bulkEvents(domain.ProductCreated, products)
+		errs := h.repository.CreateProducts(ctx, products, events, atomic)
+		publishWritten(h.events, events, errs)
+		return errs
+	}
+
+	return writeBulk(&req.bulkBody, req.Atomic, h.config, fiber.StatusCreated, prepare, write)
+}
@@ -0,0 +1,50 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type BulkDeleteProductItem struct {
+	ID string `json:"id"`
+}
+
+type BulkDeleteProductRequest struct {
+	bulkBody[BulkDeleteProductItem]
+	Atomic bool `query:"atomic"`
+}
+
+type BulkDeleteProductHandler struct {
+	repository BulkRepository
+	events     EventPublisher
+	config     config.BulkConfig
+}
+
+func NewBulkDeleteProductHandler(repository BulkRepository, events EventPublisher, bulkConfig config.BulkConfig) *BulkDeleteProductHandler {
+	return &BulkDeleteProductHandler{repository: repository, events: events, config: bulkConfig.WithDefaults()}
+}
+
+func (h *BulkDeleteProductHandler) Handle(ctx context.Context, req *BulkDeleteProductRequest) (*BulkResponse, error) {
+	prepare := func(item BulkDeleteProductItem) (string, string, error) {
+		if item.ID == "" {
+			return "", "", errors.New("id is required")
+		}
+		return item.ID, item.ID, nil
+	}
+
+	write := func(ids []string, atomic bool) []error {
+		events := make([]*domain.ProductEvent, len(ids))
+		for i, id := range ids {
+			events[i] = domain.NewProductEvent(domain.ProductDeleted, id, nil, nil)
+		}
+		errs := h.repository.DeleteProducts(ctx, ids, events, atomic)
+		publishWritten(h.events, events, errs)
+		return errs
+	}
+
+	return writeBulk(&req.bulkBody, req.Atomic, h.config, fiber.StatusOK, prepare, write)
+}
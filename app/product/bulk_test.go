@@ -0,0 +1,61 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/config"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// writtenProducts counts the products handed to the repository
+type writtenProducts struct {
+	BulkRepository
+	count int
+}
+
+func (w *writtenProducts) CreateProducts(_ context.Context, products []*domain.Product, _ []*domain.ProductEvent, _ bool) []error {
+	w.count += len(products)
+	return make([]error, len(products))
+}
+
+func TestAtomicBulkLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		body func(req *BulkCreateProductRequest) error
+	}{
+		{name: "json", body: func(req *BulkCreateProductRequest) error {
+			return req.UnmarshalJSON([]byte(`[{"name":"a"},{"name":"b"},{"name":"c"}]`))
+		}},
+		{name: "ndjson", body: func(req *BulkCreateProductRequest) error {
+			_, err := req.BindBody(ndjsonContentType, strings.NewReader("{\"name\":\"a\"}\n{\"name\":\"b\"}\n{\"name\":\"c\"}\n"))
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, limit := range []int{2, 3} {
+				repository := &writtenProducts{}
+				h := NewBulkCreateProductHandler(repository, &recordedEvents{}, config.BulkConfig{MaxAtomicItems: limit})
+				req := &BulkCreateProductRequest{Atomic: true}
+				if err := tt.body(req); err != nil {
+					t.Fatal(err)
+				}
+
+				_, err := h.Handle(context.Background(), req)
+
+				var fiberErr *fiber.Error
+				tooLarge := errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusRequestEntityTooLarge
+				switch {
+				case limit == 2 && (!tooLarge || repository.count != 0):
+					t.Fatalf("limit 2 wrote %d products with error %v, want 413 and nothing written", repository.count, err)
+				case limit == 3 && (err != nil || repository.count != 3):
+					t.Fatalf("limit 3 wrote %d products with error %v, want all of them", repository.count, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type BulkUpsertProductRequest struct {
+	bulkBody[UpdateProductRequest]
+	Atomic bool `query:"atomic"`
+}
+
+type BulkUpsertProductHandler struct {
+	repository BulkRepository
+	events     EventPublisher
+	config     config.BulkConfig
+}
+
+func NewBulkUpsertProductHandler(repository BulkRepository, events EventPublisher, bulkConfig config.BulkConfig) *BulkUpsertProductHandler {
+	return &BulkUpsertProductHandler{repository: repository, events: events, config: bulkConfig.WithDefaults()}
+}
+
+func (h *BulkUpsertProductHandler) Handle(ctx context.Context, req *BulkUpsertProductRequest) (*BulkResponse, error) {
+	prepare := func(item UpdateProductRequest) (string, *domain.Product, error) {
+		if item.ID == "" {
+			return "", nil, errors.New("id is required")
+		}
+		return item.ID, &domain.Product{ID: item.ID, Name: item.Name, Category: item.Category, Tags: item.Tags}, nil
+	}
+
+	write := func(products []*domain.Product, atomic bool) []error {
+		events := bulkEvents(domain.ProductUpdated, products)
+		errs := h.repository.UpsertProducts(ctx, products, events, atomic)
+		publishWritten(h.events, events, errs)
+		return errs
+	}
+
+	return writeBulk(&req.bulkBody, req.Atomic, h.config, fiber.StatusOK, prepare, write)
+}
@@ -0,0 +1,132 @@
+package product
+
+import (
+	"container/list"
+	"context"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/changefeed"
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/feature"
+	"golang-fiber-poc/pkg/tenant"
+	"sync"
+	"time"
+)
+
+// CachedRepository serves GetProduct from memory. A product is evicted when it is written through the
+// repository or changed in the bucket, as seen by the change feed, and after the TTL at the latest.
+type CachedRepository struct {
+	Repository
+
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	products map[string]*list.Element
+	// evictions counts evictions, so that a read that raced with a change is not cached
+	evictions uint64
+	features  *feature.Evaluator
+}
+
+type cachedProduct struct {
+	product   domain.Product
+	expiresAt time.Time
+}
+
+func NewCachedRepository(repository Repository, cacheConfig config.CacheConfig, features *feature.Evaluator) *CachedRepository {
+	capacity := cacheConfig.Size
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	ttl := cacheConfig.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	return &CachedRepository{
+		Repository: repository,
+		capacity:   capacity,
+		ttl:        ttl,
+		order:      list.New(),
+		products:   make(map[string]*list.Element),
+		features:   features,
+	}
+}
+
+func (r *CachedRepository) GetProduct(ctx context.Context, id string) (*domain.Product, error) {
+	// The change feed only sees the shared products, so the products of tenants are not cached
+	if _, ok := tenant.FromContext(ctx); ok {
+		return r.Repository.GetProduct(ctx, id)
+	}
+	if !r.features.Enabled(ctx, FlagReadCache) {
+		return r.Repository.GetProduct(ctx, id)
+	}
+
+	r.mu.Lock()
+	if element, ok := r.products[id]; ok {
+		cached := element.Value.(*cachedProduct)
+		if time.Now().Before(cached.expiresAt) {
+			r.order.MoveToFront(element)
+			product := cached.product
+			r.mu.Unlock()
+			return &product, nil
+		}
+		r.remove(element)
+	}
+	evictions := r.evictions
+	r.mu.Unlock()
+
+	product, err := r.Repository.GetProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A replica may lag, the product would stay stale for the whole TTL
+	if r.evictions == evictions && !product.Stale {
+		r.put(product)
+	}
+	return product, nil
+}
+
+func (r *CachedRepository) CreateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error {
+	defer r.Evict(product.ID)
+	return r.Repository.CreateProduct(ctx, product, event)
+}
+
+func (r *CachedRepository) UpdateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error {
+	defer r.Evict(product.ID)
+	return r.Repository.UpdateProduct(ctx, product, event)
+}
+
+func (r *CachedRepository) Evict(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.evictions++
+	if element, ok := r.products[id]; ok {
+		r.remove(element)
+	}
+}
+
+// Changes evicts the products changed in the bucket, including by bulk writes and other tools
+func (r *CachedRepository) Changes(_ context.Context, change changefeed.Change) {
+	r.Evict(change.Key)
+}
+
+// put caches a product, evicting the least recently used one when the cache is full. Callers must hold the lock.
+func (r *CachedRepository) put(product *domain.Product) {
+	if element, ok := r.products[product.ID]; ok {
+		r.remove(element)
+	}
+	if r.order.Len() >= r.capacity {
+		r.remove(r.order.Back())
+	}
+	r.products[product.ID] = r.order.PushFront(&cachedProduct{product: *product, expiresAt: time.Now().Add(r.ttl)})
+}
+
+// remove drops a cached product. Callers must hold the lock.
+func (r *CachedRepository) remove(element *list.Element) {
+	r.order.Remove(element)
+	delete(r.products, element.Value.(*cachedProduct).product.ID)
+}
@@ -0,0 +1,138 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/changefeed"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// localChangeWindow is how long a change is remembered to match it with the other side
+const localChangeWindow = time.Minute
+
+// FeedEvents publishes the events of the handlers together with the changes of the change feed, so
+// that writes made by other tools reach the change stream too. A change the handlers already published,
+// with its Before, is published once: the feed skips the changes that match an event of the handlers,
+// and the handlers skip the events of changes the feed saw first. Changes of other tools carry no
+// Before and their deletions carry no product.
+type FeedEvents struct {
+	publisher EventPublisher
+
+	mu sync.Mutex
+	// published and fed hold the products of the recent events of the handlers and of the feed, by product
+	published map[string][]localChange
+	fed       map[string][]localChange
+	swept     time.Time
+}
+
+// localChange is the product after a change, nil for deletions
+type localChange struct {
+	product *domain.Product
+	at      time.Time
+}
+
+func NewFeedEvents(publisher EventPublisher) *FeedEvents {
+	return &FeedEvents{
+		publisher: publisher,
+		published: make(map[string][]localChange),
+		fed:       make(map[string][]localChange),
+	}
+}
+
+// Publish publishes an event of the handlers. The change feed only covers the shared collection, so
+// the events of tenants are always published.
+func (f *FeedEvents) Publish(event domain.ProductEvent) {
+	if event.Tenant == "" && !f.match(f.fed, f.published, event.ProductID, event.After) {
+		return
+	}
+	f.publisher.Publish(event)
+}
+
+// Changes is the subscriber of the change feed
+func (f *FeedEvents) Changes(_ context.Context, change changefeed.Change) {
+	var product *domain.Product
+	if !change.Deleted {
+		product = &domain.Product{}
+		if err := json.Unmarshal(change.Value, product); err != nil {
+			zap.L().Warn("Skipped change of a document that is not a product", zap.Error(err), zap.String("key", change.Key))
+			return
+		}
+		product.ID = change.Key
+	}
+	if !f.match(f.published, f.fed, change.Key, product) {
+		return
+	}
+
+	var event *domain.ProductEvent
+	switch {
+	case product == nil:
+		event = domain.NewProductEvent(domain.ProductDeleted, change.Key, nil, nil)
+	case change.Revision == 1:
+		event = domain.NewProductEvent(domain.ProductCreated, change.Key, nil, product)
+	default:
+		event = domain.NewProductEvent(domain.ProductUpdated, change.Key, nil, product)
+	}
+	event.OccurredAt = change.Time()
+	f.publisher.Publish(*event)
+}
+
+// match looks for product in the changes of the other side. A match removes it and the older changes
+// of the product, which the feed may have merged, and returns false. Otherwise product is added to own
+// and true is returned.
+func (f *FeedEvents) match(other, own map[string][]localChange, id string, product *domain.Product) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(f.swept) >= localChangeWindow {
+		f.sweep(now)
+	}
+	f.expire(other, id, now)
+	f.expire(own, id, now)
+
+	changes := other[id]
+	if i := slices.IndexFunc(changes, func(c localChange) bool { return sameProduct(c.product, product) }); i >= 0 {
+		if i == len(changes)-1 {
+			delete(other, id)
+		} else {
+			other[id] = changes[i+1:]
+		}
+		return false
+	}
+
+	own[id] = append(own[id], localChange{product: product, at: now})
+	return true
+}
+
+// sweep forgets the old changes of every product, of which the other side never saw a match
+func (f *FeedEvents) sweep(now time.Time) {
+	for _, changes := range []map[string][]localChange{f.published, f.fed} {
+		for id := range changes {
+			f.expire(changes, id, now)
+		}
+	}
+	f.swept = now
+}
+
+// expire forgets the changes of a product that are older than the window
+func (f *FeedEvents) expire(changes map[string][]localChange, id string, now time.Time) {
+	i := slices.IndexFunc(changes[id], func(c localChange) bool { return now.Sub(c.at) < localChangeWindow })
+	switch {
+	case i < 0:
+		delete(changes, id)
+	case i > 0:
+		changes[id] = changes[id][i:]
+	}
+}
+
+func sameProduct(a, b *domain.Product) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID && a.Name == b.Name && a.Category == b.Category && slices.Equal(a.Tags, b.Tags)
+}
@@ -0,0 +1,85 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/changefeed"
+	"testing"
+)
+
+type recordedEvents []domain.ProductEvent
+
+func (r *recordedEvents) Publish(event domain.ProductEvent) {
+	*r = append(*r, event)
+}
+
+func change(t *testing.T, product *domain.Product, revision uint64) changefeed.Change {
+	t.Helper()
+	if product == nil {
+		return changefeed.Change{Key: "1", Deleted: true, Revision: revision}
+	}
+	value, err := json.Marshal(product)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return changefeed.Change{Key: product.ID, Value: value, Revision: revision}
+}
+
+func TestFeedEvents(t *testing.T) {
+	ctx := context.Background()
+	chair := &domain.Product{ID: "1", Name: "chair"}
+	armchair := &domain.Product{ID: "1", Name: "armchair"}
+
+	t.Run("the handler first keeps its Before", func(t *testing.T) {
+		events := &recordedEvents{}
+		feed := NewFeedEvents(events)
+
+		feed.Publish(*domain.NewProductEvent(domain.ProductUpdated, "1", chair, armchair))
+		feed.Changes(ctx, change(t, armchair, 2))
+
+		if len(*events) != 1 || (*events)[0].Before == nil {
+			t.Fatalf("events %+v, want the event of the handler only", *events)
+		}
+	})
+
+	t.Run("the feed first", func(t *testing.T) {
+		events := &recordedEvents{}
+		feed := NewFeedEvents(events)
+
+		feed.Changes(ctx, change(t, nil, 3))
+		feed.Publish(*domain.NewProductEvent(domain.ProductDeleted, "1", armchair, nil))
+
+		if len(*events) != 1 || (*events)[0].Type != domain.ProductDeleted {
+			t.Fatalf("events %+v, want one deletion", *events)
+		}
+	})
+
+	t.Run("merged changes", func(t *testing.T) {
+		events := &recordedEvents{}
+		feed := NewFeedEvents(events)
+
+		feed.Publish(*domain.NewProductEvent(domain.ProductCreated, "1", nil, chair))
+		feed.Publish(*domain.NewProductEvent(domain.ProductUpdated, "1", chair, armchair))
+		feed.Changes(ctx, change(t, armchair, 2))
+		feed.Changes(ctx, change(t, chair, 3))
+
+		if len(*events) != 3 || (*events)[2].Type != domain.ProductUpdated || (*events)[2].After.Name != "chair" {
+			t.Fatalf("events %+v, want the change of another tool after the events of the handlers", *events)
+		}
+	})
+
+	t.Run("tenants", func(t *testing.T) {
+		events := &recordedEvents{}
+		feed := NewFeedEvents(events)
+
+		event := domain.NewProductEvent(domain.ProductCreated, "1", nil, chair)
+		event.Tenant = "acme"
+		feed.Publish(*event)
+		feed.Changes(ctx, change(t, chair, 1))
+
+		if len(*events) != 2 || (*events)[1].Type != domain.ProductCreated {
+			t.Fatalf("events %+v, want the event of the tenant and the change of the shared collection", *events)
+		}
+	})
+}
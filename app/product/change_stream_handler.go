@@ -0,0 +1,230 @@
+package product
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/eventbus"
+	"golang-fiber-poc/pkg/tenant"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// resetEvent tells a resuming client that some changes are no longer available and it should reload
+const resetEvent = "reset"
+
+type ChangeStreamRequest struct {
+	IDs        []string `query:"id"`
+	Categories []string `query:"category"`
+	// LastEventID resumes the stream for clients that cannot send the Last-Event-ID header, e.g. WebSockets
+	LastEventID string `query:"lastEventId"`
+}
+
+// streamMessage is a WebSocket message, with the fields of a server-sent event
+type streamMessage struct {
+	ID   string               `json:"id,omitempty"`
+	Type string               `json:"type"`
+	Data *domain.ProductEvent `json:"data,omitempty"`
+}
+
+// ChangeStreamHandler streams product changes as server-sent events or over a WebSocket.
+// The streams take over the connection, so they are not bound by the server's write timeout.
+type ChangeStreamHandler struct {
+	events *eventbus.Bus[domain.ProductEvent]
+	config config.StreamConfig
+}
+
+func NewChangeStreamHandler(events *eventbus.Bus[domain.ProductEvent], streamConfig config.StreamConfig) *ChangeStreamHandler {
+	return &ChangeStreamHandler{events: events, config: streamConfig.WithDefaults()}
+}
+
+func (h *ChangeStreamHandler) SSE(c *fiber.Ctx) error {
+	subscription, err := h.subscribe(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(conn net.Conn) {
+		h.serveSSE(conn, subscription)
+	})
+	return nil
+}
+
+func (h *ChangeStreamHandler) WebSocket() fiber.Handler {
+	upgrade := websocket.New(func(conn *websocket.Conn) {
+		h.serveWebSocket(conn, conn.Locals("subscription").(*eventbus.Subscription[domain.ProductEvent]))
+	})
+
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{"error": "this endpoint requires a WebSocket upgrade"})
+		}
+
+		subscription, err := h.subscribe(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		c.Locals("subscription", subscription)
+		if err := upgrade(c); err != nil {
+			subscription.Close()
+			return err
+		}
+		return nil
+	}
+}
+
+func (h *ChangeStreamHandler) subscribe(c *fiber.Ctx) (*eventbus.Subscription[domain.ProductEvent], error) {
+	var req ChangeStreamRequest
+	if err := c.QueryParser(&req); err != nil {
+		return nil, err
+	}
+
+	lastEventID := c.Get("Last-Event-ID", req.LastEventID)
+	var after uint64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid last event id %q", lastEventID)
+		}
+	}
+
+	tenantID, _ := tenant.FromContext(c.UserContext())
+	return h.events.Subscribe(after, changeFilter(tenantID, req.IDs, req.Categories), h.config.Buffer), nil
+}
+
+// changeFilter matches events of the tenant and the given products and categories. Deletions only
+// carry the category when the repository knows the deleted product.
+func changeFilter(tenantID string, ids, categories []string) func(domain.ProductEvent) bool {
+	return func(event domain.ProductEvent) bool {
+		if event.Tenant != tenantID {
+			return false
+		}
+		if len(ids) > 0 && !slices.Contains(ids, event.ProductID) {
+			return false
+		}
+		if len(categories) > 0 && (event.Product() == nil || !slices.Contains(categories, event.Product().Category)) {
+			return false
+		}
+		return true
+	}
+}
+
+func (h *ChangeStreamHandler) serveSSE(conn net.Conn, subscription *eventbus.Subscription[domain.ProductEvent]) {
+	defer subscription.Close()
+	go closeOnDisconnect(conn, subscription)
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/event-stream\r\n" +
+		"Cache-Control: no-cache\r\n" +
+		"Connection: close\r\n" +
+		"X-Accel-Buffering: no\r\n\r\n")
+	fmt.Fprintf(w, "retry: %d\n\n", h.config.Retry.Milliseconds())
+	if subscription.Missed {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", resetEvent)
+	}
+	conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout))
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.config.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				h.logEnd(subscription)
+				return
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				zap.L().Error("Failed to encode product event", zap.Error(err))
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Data.Type, data)
+			// Events that are already queued go out in the same write
+			if len(subscription.Events()) > 0 {
+				continue
+			}
+		case <-heartbeat.C:
+			w.WriteString(": heartbeat\n\n")
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout))
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (h *ChangeStreamHandler) serveWebSocket(conn *websocket.Conn, subscription *eventbus.Subscription[domain.ProductEvent]) {
+	defer subscription.Close()
+	go func() {
+		// Reading handles control frames and notices when the client goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				subscription.Close()
+				return
+			}
+		}
+	}()
+
+	write := func(message streamMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout))
+		return conn.WriteJSON(message)
+	}
+
+	if subscription.Missed {
+		if err := write(streamMessage{Type: resetEvent}); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.config.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				h.logEnd(subscription)
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(h.config.WriteTimeout))
+				return
+			}
+			err = write(streamMessage{ID: strconv.FormatUint(event.ID, 10), Type: string(event.Data.Type), Data: &event.Data})
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.config.WriteTimeout))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (h *ChangeStreamHandler) logEnd(subscription *eventbus.Subscription[domain.ProductEvent]) {
+	if err := subscription.Err(); errors.Is(err, eventbus.ErrSlowSubscriber) {
+		zap.L().Warn("Dropped slow product change stream subscriber", zap.Int("buffer", h.config.Buffer))
+	}
+}
+
+// closeOnDisconnect ends the subscription once the client closes the connection. SSE clients never send anything.
+func closeOnDisconnect(conn net.Conn, subscription *eventbus.Subscription[domain.ProductEvent]) {
+	conn.SetReadDeadline(time.Time{})
+	io.Copy(io.Discard, conn)
+	subscription.Close()
+}
@@ -0,0 +1,57 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/infra/memory"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/handler"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestConsumedMessagesUseTheHandlers(t *testing.T) {
+	ctx := context.Background()
+	repository := memory.NewRepository()
+	if err := repository.CreateProduct(ctx, &domain.Product{ID: "1", Name: "chair"}, domain.NewProductEvent(domain.ProductCreated, "1", nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	events := &recordedEvents{}
+	create := handler.Consume[CreateProductRequest, CreateProductResponse](NewCreateProductHandler(repository, events))
+	update := handler.Consume[UpdateProductRequest, UpdateProductResponse](NewUpdateProductHandler(repository, events))
+
+	tests := []struct {
+		name    string
+		handle  broker.Handler
+		value   string
+		status  int
+		updated string
+	}{
+		{name: "created", handle: create, value: `{"name":"table"}`},
+		{name: "updated", handle: update, value: `{"id":"1","name":"armchair"}`, updated: "armchair"},
+		{name: "update without id", handle: update, value: `{"name":"armchair"}`, status: fiber.StatusBadRequest},
+		{name: "update of a missing product", handle: update, value: `{"id":"2","name":"sofa"}`, status: fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.handle(ctx, broker.Message{ID: tt.name, Value: []byte(tt.value)})
+			var fiberErr *fiber.Error
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Fatalf("error %v", err)
+			case tt.status != 0 && (!errors.As(err, &fiberErr) || fiberErr.Code != tt.status):
+				t.Fatalf("error %v, want status %d", err, tt.status)
+			}
+			if tt.updated != "" {
+				if product, _ := repository.GetProduct(ctx, "1"); product.Name != tt.updated {
+					t.Errorf("name %s, want %s", product.Name, tt.updated)
+				}
+			}
+		})
+	}
+	if len(*events) != 2 || (*events)[0].Type != domain.ProductCreated || (*events)[1].Type != domain.ProductUpdated {
+		t.Errorf("events %+v, want created then updated", *events)
+	}
+}
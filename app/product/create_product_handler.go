@@ -2,12 +2,16 @@ package product
 
 import (
 	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"golang-fiber-poc/domain"
 )
 
 type CreateProductRequest struct {
-	Name string `json:"name"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
 }
 
 type CreateProductResponse struct {
@@ -16,24 +20,33 @@ type CreateProductResponse struct {
 
 type CreateProductHandler struct {
 	repository Repository
+	events     EventPublisher
 }
 
-func NewCreateProductHandler(repository Repository) *CreateProductHandler {
-	return &CreateProductHandler{repository: repository}
+func NewCreateProductHandler(repository Repository, events EventPublisher) *CreateProductHandler {
+	return &CreateProductHandler{repository: repository, events: events}
 }
 
 func (h *CreateProductHandler) Handle(ctx context.Context, req *CreateProductRequest) (*CreateProductResponse, error) {
 	productId := uuid.New().String()
 
 	product := domain.Product{
-		ID:   productId,
-		Name: req.Name,
+		ID:       productId,
+		Name:     req.Name,
+		Category: req.Category,
+		Tags:     req.Tags,
 	}
 
-	err := h.repository.CreateProduct(ctx, &product)
+	event := domain.NewProductEvent(domain.ProductCreated, product.ID, nil, &product)
+	err := h.repository.CreateProduct(ctx, &product, event)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, domain.ErrProductAlreadyExists) {
+			return nil, fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		return nil, storeError(err)
 	}
 
+	h.events.Publish(*event)
+
 	return &CreateProductResponse{ID: product.ID}, nil
 }
@@ -0,0 +1,28 @@
+package product
+
+import (
+	"golang-fiber-poc/domain"
+)
+
+// EventPublisher receives the changes handlers make to products, once they are stored
+type EventPublisher interface {
+	Publish(event domain.ProductEvent)
+}
+
+// bulkEvents creates the events of a bulk write, one per product
+func bulkEvents(eventType domain.ProductEventType, products []*domain.Product) []*domain.ProductEvent {
+	events := make([]*domain.ProductEvent, len(products))
+	for i, product := range products {
+		events[i] = domain.NewProductEvent(eventType, product.ID, nil, product)
+	}
+	return events
+}
+
+// publishWritten publishes the events of the items of a bulk write that succeeded
+func publishWritten(publisher EventPublisher, events []*domain.ProductEvent, errs []error) {
+	for i, event := range events {
+		if errs[i] == nil {
+			publisher.Publish(*event)
+		}
+	}
+}
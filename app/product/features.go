@@ -0,0 +1,16 @@
+package product
+
+import "golang-fiber-poc/pkg/feature"
+
+const (
+	// FlagUpstreamEnrichment calls the upstream service, through its circuit breaker, before getting a product
+	FlagUpstreamEnrichment = "upstream-enrichment"
+	// FlagReadCache serves GetProduct from the CachedRepository, when the cache is configured
+	FlagReadCache = "product-read-cache"
+)
+
+// DefineFlags sets the defaults of the flags of the product handlers, both are on
+func DefineFlags(features *feature.Evaluator) {
+	features.Define(FlagUpstreamEnrichment, feature.Flag{Enabled: true, Rollout: 100})
+	features.Define(FlagReadCache, feature.Flag{Enabled: true, Rollout: 100})
+}
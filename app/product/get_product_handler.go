@@ -2,31 +2,40 @@ package product
 
 import (
 	"context"
+	"errors"
 	"golang-fiber-poc/app/client"
+	"golang-fiber-poc/domain"
 	"golang-fiber-poc/pkg/circuitbreaker"
+	"golang-fiber-poc/pkg/feature"
 	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/sony/gobreaker"
 )
 
 type GetProductRequest struct {
-	Id string `json:"id" param:"id"`
+	Id string `json:"id" param:"id" validate:"required"`
 }
 
 type GetProductResponse struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id       string   `json:"id"`
+	Name     string   `json:"name"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// Stale tells that the product was read from a replica and may miss the latest changes
+	Stale bool `json:"stale,omitempty"`
 }
 
 type GetProductHandler struct {
 	repository    Repository
 	client        client.CustomRetryableClient
 	noRetryClient client.CustomHttpClient
-	cb            *gobreaker.CircuitBreaker
+	cb            *circuitbreaker.Breaker
+	features      *feature.Evaluator
 }
 
-func NewGetProductHandler(repository Repository, client client.CustomRetryableClient, noRetryClient client.CustomHttpClient) *GetProductHandler {
-	cb := circuitbreaker.NewCircuitBreaker(circuitbreaker.CircuitBreakerConfig{
+func NewGetProductHandler(repository Repository, client client.CustomRetryableClient, noRetryClient client.CustomHttpClient, breakers *circuitbreaker.Registry, features *feature.Evaluator) *GetProductHandler {
+	cb := breakers.Register(circuitbreaker.CircuitBreakerConfig{
 		Name:                    "get-product",
 		MaxRequests:             3,
 		Interval:                10 * time.Second,
@@ -40,25 +49,42 @@ func NewGetProductHandler(repository Repository, client client.CustomRetryableCl
 		client:        client,
 		noRetryClient: noRetryClient,
 		cb:            cb,
+		features:      features,
 	}
 }
 
+// Health fails while the circuit of the downstream service is open
+func (h *GetProductHandler) Health(_ context.Context) error {
+	if h.cb.State() == gobreaker.StateOpen {
+		return errors.New("circuit " + h.cb.Name() + " is open")
+	}
+	return nil
+}
+
 func (h *GetProductHandler) Handle(ctx context.Context, req *GetProductRequest) (*GetProductResponse, error) {
-	// Execute GetError through circuit breaker
-	_, err := h.cb.Execute(func() (interface{}, error) {
-		return nil, h.noRetryClient.GetError(ctx)
-	})
-	if err != nil {
-		return nil, err
+	if h.features.Enabled(ctx, FlagUpstreamEnrichment) {
+		// Execute GetError through circuit breaker
+		_, err := h.cb.Execute(func() (interface{}, error) {
+			return nil, h.noRetryClient.GetError(ctx)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	product, err := h.repository.GetProduct(ctx, req.Id)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, domain.ErrProductNotFound) {
+			return nil, fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return nil, storeError(err)
 	}
 
 	return &GetProductResponse{
-		Id:   product.ID,
-		Name: product.Name,
+		Id:       product.ID,
+		Name:     product.Name,
+		Category: product.Category,
+		Tags:     product.Tags,
+		Stale:    product.Stale,
 	}, nil
 }
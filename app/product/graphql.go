@@ -0,0 +1,234 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/dataloader"
+	"golang-fiber-poc/pkg/handler"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+)
+
+// maxLoaderBatch caps the ids fetched by one GetProducts call
+const maxLoaderBatch = 100
+
+type loaderKey struct{}
+
+// GraphQLResolver serves products over GraphQL. Lookups by id are batched per request through
+// BatchRepository, search and mutations run the same handlers as the HTTP routes.
+type GraphQLResolver struct {
+	repository           BatchRepository
+	searchProductHandler *SearchProductHandler
+	createProductHandler *CreateProductHandler
+	updateProductHandler *UpdateProductHandler
+}
+
+func NewGraphQLResolver(repository BatchRepository, searchProductHandler *SearchProductHandler, createProductHandler *CreateProductHandler, updateProductHandler *UpdateProductHandler) *GraphQLResolver {
+	return &GraphQLResolver{
+		repository:           repository,
+		searchProductHandler: searchProductHandler,
+		createProductHandler: createProductHandler,
+		updateProductHandler: updateProductHandler,
+	}
+}
+
+// Context attaches the product loader of one request
+func (r *GraphQLResolver) Context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loaderKey{}, dataloader.New(r.repository.GetProducts, maxLoaderBatch))
+}
+
+func (r *GraphQLResolver) Schema() (graphql.Schema, error) {
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"category": &graphql.Field{Type: graphql.String},
+			"tags":     &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		},
+	})
+
+	searchHitType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductSearchHit",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"category": &graphql.Field{Type: graphql.String},
+			"tags":     &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"score":    &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		},
+	})
+
+	searchPageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductSearchPage",
+		Fields: graphql.Fields{
+			"items": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(searchHitType)))},
+			"total": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"next":  &graphql.Field{Type: graphql.String},
+			"prev":  &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	productInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ProductInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"category": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"tags":     &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+		},
+	})
+
+	stringList := graphql.NewList(graphql.NewNonNull(graphql.String))
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type:    productType,
+				Args:    graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: r.product,
+			},
+			"products": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(productType)),
+				Args:    graphql.FieldConfigArgument{"ids": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))}},
+				Resolve: r.products,
+			},
+			"searchProducts": &graphql.Field{
+				Type: graphql.NewNonNull(searchPageType),
+				Args: graphql.FieldConfigArgument{
+					"q":        {Type: graphql.String},
+					"category": {Type: stringList},
+					"tag":      {Type: stringList},
+					"sort":     {Type: graphql.String},
+					"limit":    {Type: graphql.Int},
+					"cursor":   {Type: graphql.String},
+				},
+				Resolve: r.searchProducts,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createProduct": &graphql.Field{
+				Type:    graphql.NewNonNull(productType),
+				Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(productInputType)}},
+				Resolve: r.createProduct,
+			},
+			"updateProduct": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.ID)},
+					"input": {Type: graphql.NewNonNull(productInputType)},
+				},
+				Resolve: r.updateProduct,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func (r *GraphQLResolver) product(p graphql.ResolveParams) (any, error) {
+	load := loader(p.Context).Load(p.Context, p.Args["id"].(string))
+
+	return func() (any, error) {
+		product, err := load()
+		if err != nil {
+			if errors.Is(err, domain.ErrProductNotFound) {
+				return nil, fiber.NewError(fiber.StatusNotFound, err.Error())
+			}
+			return nil, err
+		}
+		return product, nil
+	}, nil
+}
+
+// products returns null for the ids that do not exist
+func (r *GraphQLResolver) products(p graphql.ResolveParams) (any, error) {
+	load := loader(p.Context).LoadMany(p.Context, stringArgs(p.Args["ids"]))
+
+	return func() (any, error) {
+		products, errs := load()
+		for _, err := range errs {
+			if err != nil && !errors.Is(err, domain.ErrProductNotFound) {
+				return nil, err
+			}
+		}
+		return products, nil
+	}, nil
+}
+
+func (r *GraphQLResolver) searchProducts(p graphql.ResolveParams) (any, error) {
+	req := &SearchProductRequest{
+		Categories: stringArgs(p.Args["category"]),
+		Tags:       stringArgs(p.Args["tag"]),
+	}
+	req.Query, _ = p.Args["q"].(string)
+	req.Sort, _ = p.Args["sort"].(string)
+	req.Limit, _ = p.Args["limit"].(int)
+	req.Cursor, _ = p.Args["cursor"].(string)
+
+	return handler.Call(p.Context, r.searchProductHandler, req)
+}
+
+func (r *GraphQLResolver) createProduct(p graphql.ResolveParams) (any, error) {
+	input := productInput(p.Args["input"])
+	res, err := handler.Call(p.Context, r.createProductHandler, &CreateProductRequest{
+		Name:     input.Name,
+		Category: input.Category,
+		Tags:     input.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	input.ID = res.ID
+	return input, nil
+}
+
+func (r *GraphQLResolver) updateProduct(p graphql.ResolveParams) (any, error) {
+	input := productInput(p.Args["input"])
+	res, err := handler.Call(p.Context, r.updateProductHandler, &UpdateProductRequest{
+		ID:       p.Args["id"].(string),
+		Name:     input.Name,
+		Category: input.Category,
+		Tags:     input.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	input.ID = res.ID
+	return input, nil
+}
+
+func loader(ctx context.Context) *dataloader.Loader[string, *domain.Product] {
+	return ctx.Value(loaderKey{}).(*dataloader.Loader[string, *domain.Product])
+}
+
+func productInput(arg any) *domain.Product {
+	input, _ := arg.(map[string]any)
+	product := &domain.Product{Tags: stringArgs(input["tags"])}
+	product.Name, _ = input["name"].(string)
+	product.Category, _ = input["category"].(string)
+	return product
+}
+
+func stringArgs(arg any) []string {
+	values, _ := arg.([]any)
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,35 @@
+package product
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/handler"
+	productv1 "golang-fiber-poc/proto/product/v1"
+)
+
+// GRPCService serves productv1.ProductService with the same handlers as the HTTP routes
+type GRPCService struct {
+	productv1.UnimplementedProductServiceServer
+	getProduct    func(context.Context, *productv1.GetProductRequest) (*productv1.GetProductResponse, error)
+	createProduct func(context.Context, *productv1.CreateProductRequest) (*productv1.CreateProductResponse, error)
+	updateProduct func(context.Context, *productv1.UpdateProductRequest) (*productv1.UpdateProductResponse, error)
+}
+
+func NewGRPCService(getProductHandler *GetProductHandler, createProductHandler *CreateProductHandler, updateProductHandler *UpdateProductHandler) *GRPCService {
+	return &GRPCService{
+		getProduct:    handler.Unary(getProductHandler, getProductRequestFromProto, (*GetProductResponse).toProto),
+		createProduct: handler.Unary(createProductHandler, createProductRequestFromProto, (*CreateProductResponse).toProto),
+		updateProduct: handler.Unary(updateProductHandler, updateProductRequestFromProto, (*UpdateProductResponse).toProto),
+	}
+}
+
+func (s *GRPCService) GetProduct(ctx context.Context, req *productv1.GetProductRequest) (*productv1.GetProductResponse, error) {
+	return s.getProduct(ctx, req)
+}
+
+func (s *GRPCService) CreateProduct(ctx context.Context, req *productv1.CreateProductRequest) (*productv1.CreateProductResponse, error) {
+	return s.createProduct(ctx, req)
+}
+
+func (s *GRPCService) UpdateProduct(ctx context.Context, req *productv1.UpdateProductRequest) (*productv1.UpdateProductResponse, error) {
+	return s.updateProduct(ctx, req)
+}
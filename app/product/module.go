@@ -0,0 +1,50 @@
+package product
+
+import (
+	"golang-fiber-poc/app/client"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/circuitbreaker"
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/di"
+	"golang-fiber-poc/pkg/eventbus"
+	"golang-fiber-poc/pkg/feature"
+	"golang-fiber-poc/pkg/handler"
+)
+
+// Module provides the product handlers. The app provides the repositories, the EventPublisher, the circuit
+// breaker registry and the feature flags they use.
+func Module(c *di.Container) {
+	di.Provide(c, func(c *di.Container) (*GetProductHandler, error) {
+		return NewGetProductHandler(di.MustGet[Repository](c), di.MustGet[client.CustomRetryableClient](c), di.MustGet[client.CustomHttpClient](c), di.MustGet[*circuitbreaker.Registry](c), di.MustGet[*feature.Evaluator](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*CreateProductHandler, error) {
+		return NewCreateProductHandler(di.MustGet[Repository](c), di.MustGet[EventPublisher](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*UpdateProductHandler, error) {
+		return NewUpdateProductHandler(di.MustGet[Repository](c), di.MustGet[EventPublisher](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*BulkCreateProductHandler, error) {
+		return NewBulkCreateProductHandler(di.MustGet[BulkRepository](c), di.MustGet[EventPublisher](c), di.MustGet[*config.AppConfig](c).Bulk), nil
+	})
+	di.Provide(c, func(c *di.Container) (*BulkUpsertProductHandler, error) {
+		return NewBulkUpsertProductHandler(di.MustGet[BulkRepository](c), di.MustGet[EventPublisher](c), di.MustGet[*config.AppConfig](c).Bulk), nil
+	})
+	di.Provide(c, func(c *di.Container) (*BulkDeleteProductHandler, error) {
+		return NewBulkDeleteProductHandler(di.MustGet[BulkRepository](c), di.MustGet[EventPublisher](c), di.MustGet[*config.AppConfig](c).Bulk), nil
+	})
+	di.Provide(c, func(c *di.Container) (*handler.CursorCodec, error) {
+		return handler.NewCursorCodec([]byte(di.MustGet[*config.AppConfig](c).Pagination.Secret.Value())), nil
+	})
+	di.Provide(c, func(c *di.Container) (*SearchProductHandler, error) {
+		return NewSearchProductHandler(di.MustGet[SearchRepository](c), di.MustGet[*handler.CursorCodec](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*ChangeStreamHandler, error) {
+		return NewChangeStreamHandler(di.MustGet[*eventbus.Bus[domain.ProductEvent]](c), di.MustGet[*config.AppConfig](c).Stream), nil
+	})
+	di.Provide(c, func(c *di.Container) (*GraphQLResolver, error) {
+		return NewGraphQLResolver(di.MustGet[BatchRepository](c), di.MustGet[*SearchProductHandler](c), di.MustGet[*CreateProductHandler](c), di.MustGet[*UpdateProductHandler](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*GRPCService, error) {
+		return NewGRPCService(di.MustGet[*GetProductHandler](c), di.MustGet[*CreateProductHandler](c), di.MustGet[*UpdateProductHandler](c)), nil
+	})
+}
@@ -0,0 +1,80 @@
+package product
+
+import (
+	productv1 "golang-fiber-poc/proto/product/v1"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Conversions between the handler types and the generated messages in proto/product/v1,
+// used by the protobuf codec and the gRPC service
+
+func getProductRequestFromProto(message *productv1.GetProductRequest) *GetProductRequest {
+	return &GetProductRequest{Id: message.GetId()}
+}
+
+func (r *GetProductResponse) toProto() *productv1.GetProductResponse {
+	return &productv1.GetProductResponse{
+		Id:       r.Id,
+		Name:     r.Name,
+		Category: r.Category,
+		Tags:     r.Tags,
+	}
+}
+
+func (r *GetProductResponse) MarshalProto() ([]byte, error) {
+	return proto.Marshal(r.toProto())
+}
+
+func createProductRequestFromProto(message *productv1.CreateProductRequest) *CreateProductRequest {
+	return &CreateProductRequest{
+		Name:     message.GetName(),
+		Category: message.GetCategory(),
+		Tags:     message.GetTags(),
+	}
+}
+
+func (r *CreateProductRequest) UnmarshalProto(data []byte) error {
+	var message productv1.CreateProductRequest
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return err
+	}
+
+	*r = *createProductRequestFromProto(&message)
+	return nil
+}
+
+func (r *CreateProductResponse) toProto() *productv1.CreateProductResponse {
+	return &productv1.CreateProductResponse{Id: r.ID}
+}
+
+func (r *CreateProductResponse) MarshalProto() ([]byte, error) {
+	return proto.Marshal(r.toProto())
+}
+
+func updateProductRequestFromProto(message *productv1.UpdateProductRequest) *UpdateProductRequest {
+	return &UpdateProductRequest{
+		ID:       message.GetId(),
+		Name:     message.GetName(),
+		Category: message.GetCategory(),
+		Tags:     message.GetTags(),
+	}
+}
+
+func (r *UpdateProductRequest) UnmarshalProto(data []byte) error {
+	var message productv1.UpdateProductRequest
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return err
+	}
+
+	*r = *updateProductRequestFromProto(&message)
+	return nil
+}
+
+func (r *UpdateProductResponse) toProto() *productv1.UpdateProductResponse {
+	return &productv1.UpdateProductResponse{Id: r.ID}
+}
+
+func (r *UpdateProductResponse) MarshalProto() ([]byte, error) {
+	return proto.Marshal(r.toProto())
+}
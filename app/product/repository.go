@@ -2,11 +2,43 @@ package product
 
 import (
 	"context"
+	"errors"
 	"golang-fiber-poc/domain"
+
+	"github.com/gofiber/fiber/v2"
 )
 
+// Repository writes the event of a change to the outbox in the same transaction as the product.
+// UpdateProduct sets the Before of the event to the product it replaced.
 type Repository interface {
 	GetProduct(ctx context.Context, id string) (*domain.Product, error)
-	CreateProduct(ctx context.Context, product *domain.Product) error
-	UpdateProduct(ctx context.Context, product *domain.Product) error
+	CreateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error
+	UpdateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error
+}
+
+// BatchRepository reads many products in one round trip. It returns one product or error per id, in input order.
+type BatchRepository interface {
+	GetProducts(ctx context.Context, ids []string) ([]*domain.Product, []error)
+}
+
+// BulkRepository writes many products at once. Every method returns one error per item, in input order.
+// With atomic set the items are written in a single transaction, so either all of them succeed or none do.
+// Events holds the event of every item, which is written to the outbox in the transaction of its item.
+// The events of updates and deletes get the Before of their product.
+type BulkRepository interface {
+	CreateProducts(ctx context.Context, products []*domain.Product, events []*domain.ProductEvent, atomic bool) []error
+	UpsertProducts(ctx context.Context, products []*domain.Product, events []*domain.ProductEvent, atomic bool) []error
+	DeleteProducts(ctx context.Context, ids []string, events []*domain.ProductEvent, atomic bool) []error
+}
+
+type SearchRepository interface {
+	SearchProducts(ctx context.Context, query domain.ProductSearchQuery) (*domain.ProductSearchResult, error)
+}
+
+// storeError answers with 503 while the store is unavailable, so that clients retry later
+func storeError(err error) error {
+	if errors.Is(err, domain.ErrStoreUnavailable) {
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	return err
 }
@@ -0,0 +1,151 @@
+package product
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/handler"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	sortableFields  = []string{"score", "id", "name", "category"}
+	facetableFields = []string{"category", "tags"}
+)
+
+type SearchProductRequest struct {
+	handler.PageRequest
+	Query      string   `query:"q"`
+	Categories []string `query:"category"`
+	Tags       []string `query:"tag"`
+	Facets     []string `query:"facet"`
+	Highlight  bool     `query:"highlight"`
+}
+
+type SearchProductHit struct {
+	Id         string              `json:"id"`
+	Name       string              `json:"name"`
+	Category   string              `json:"category,omitempty"`
+	Tags       []string            `json:"tags,omitempty"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+type SearchProductResponse struct {
+	handler.Page[SearchProductHit]
+	Total  int                            `json:"total"`
+	Facets map[string][]domain.FacetCount `json:"facets,omitempty"`
+}
+
+// searchCursor is the position encoded in search cursors. Query binds it to the search it was returned for.
+type searchCursor struct {
+	Offset int    `json:"o"`
+	Query  string `json:"q"`
+}
+
+type SearchProductHandler struct {
+	repository SearchRepository
+	cursors    *handler.CursorCodec
+}
+
+func NewSearchProductHandler(repository SearchRepository, cursors *handler.CursorCodec) *SearchProductHandler {
+	return &SearchProductHandler{repository: repository, cursors: cursors}
+}
+
+func (h *SearchProductHandler) Handle(ctx context.Context, req *SearchProductRequest) (*SearchProductResponse, error) {
+	query, err := h.toQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.repository.SearchProducts(ctx, query)
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	response := &SearchProductResponse{
+		Total:  result.Total,
+		Facets: result.Facets,
+	}
+	response.Items = make([]SearchProductHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		response.Items = append(response.Items, SearchProductHit{
+			Id:         hit.Product.ID,
+			Name:       hit.Product.Name,
+			Category:   hit.Product.Category,
+			Tags:       hit.Product.Tags,
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		})
+	}
+
+	if next := query.Offset + len(result.Hits); next < result.Total && len(result.Hits) > 0 {
+		if response.Next, err = h.cursors.Encode(searchCursor{Offset: next, Query: queryHash(query)}); err != nil {
+			return nil, err
+		}
+	}
+	if query.Offset > 0 {
+		if response.Prev, err = h.cursors.Encode(searchCursor{Offset: max(query.Offset-query.Limit, 0), Query: queryHash(query)}); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+func (h *SearchProductHandler) toQuery(req *SearchProductRequest) (domain.ProductSearchQuery, error) {
+	query := domain.ProductSearchQuery{
+		Text:       strings.TrimSpace(req.Query),
+		Categories: req.Categories,
+		Tags:       req.Tags,
+		Highlight:  req.Highlight,
+	}
+
+	var err error
+	if query.Limit, err = req.PageSize(handler.DefaultPageLimits); err != nil {
+		return query, err
+	}
+
+	for _, facet := range req.Facets {
+		if !slices.Contains(facetableFields, facet) {
+			return query, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("cannot facet on %q, allowed fields are %s", facet, strings.Join(facetableFields, ", ")))
+		}
+		query.Facets = append(query.Facets, facet)
+	}
+
+	sort, err := req.ParseSort(sortableFields)
+	if err != nil {
+		return query, err
+	}
+	for _, field := range sort {
+		query.Sort = append(query.Sort, domain.SortField{Field: field.Field, Descending: field.Descending})
+	}
+	if len(query.Sort) == 0 {
+		query.Sort = []domain.SortField{{Field: "score", Descending: true}, {Field: "id"}}
+	}
+
+	if req.Cursor != "" {
+		var cursor searchCursor
+		if err := h.cursors.Decode(req.Cursor, &cursor); err != nil {
+			return query, err
+		}
+		if cursor.Query != queryHash(query) {
+			return query, fiber.NewError(fiber.StatusBadRequest, handler.ErrInvalidCursor.Error()+", it belongs to another search")
+		}
+		query.Offset = cursor.Offset
+	}
+
+	return query, nil
+}
+
+// queryHash identifies the text, filters and sort of a search, which a cursor is only valid for
+func queryHash(query domain.ProductSearchQuery) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%q %q %q %v", query.Text, query.Categories, query.Tags, query.Sort)
+	return base64.RawURLEncoding.EncodeToString(hash.Sum(nil)[:12])
+}
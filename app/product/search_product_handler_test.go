@@ -0,0 +1,46 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/infra/memory"
+	"golang-fiber-poc/pkg/handler"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSearchCursor(t *testing.T) {
+	ctx := context.Background()
+	repository := memory.NewRepository()
+	for _, id := range []string{"1", "2", "3"} {
+		product := &domain.Product{ID: id, Name: "chair " + id, Category: "furniture"}
+		if err := repository.CreateProduct(ctx, product, domain.NewProductEvent(domain.ProductCreated, id, nil, product)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewSearchProductHandler(repository, handler.NewCursorCodec([]byte("secret")))
+
+	first, err := h.Handle(ctx, &SearchProductRequest{Query: "chair", PageRequest: handler.PageRequest{Limit: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Next == "" {
+		t.Fatal("no next cursor")
+	}
+
+	second, err := h.Handle(ctx, &SearchProductRequest{Query: "chair", PageRequest: handler.PageRequest{Limit: 2, Cursor: first.Next}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Items) != 1 {
+		t.Fatalf("second page has %d items, want 1", len(second.Items))
+	}
+
+	_, err = h.Handle(ctx, &SearchProductRequest{Query: "lamp", PageRequest: handler.PageRequest{Limit: 2, Cursor: first.Next}})
+	var fiberErr *fiber.Error
+	if !errors.As(err, &fiberErr) || fiberErr.Code != fiber.StatusBadRequest {
+		t.Fatalf("cursor of another search: %v, want 400", err)
+	}
+}
@@ -2,12 +2,16 @@ package product
 
 import (
 	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
 	"golang-fiber-poc/domain"
 )
 
 type UpdateProductRequest struct {
-	ID   string `json:"id" param:"id"`
-	Name string `json:"name"`
+	ID       string   `json:"id" param:"id" validate:"required"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
 }
 
 type UpdateProductResponse struct {
@@ -16,23 +20,32 @@ type UpdateProductResponse struct {
 
 type UpdateProductHandler struct {
 	repository Repository
+	events     EventPublisher
 }
 
-func NewUpdateProductHandler(repository Repository) *UpdateProductHandler {
-	return &UpdateProductHandler{repository: repository}
+func NewUpdateProductHandler(repository Repository, events EventPublisher) *UpdateProductHandler {
+	return &UpdateProductHandler{repository: repository, events: events}
 }
 
 func (h *UpdateProductHandler) Handle(ctx context.Context, req *UpdateProductRequest) (*UpdateProductResponse, error) {
 
 	product := domain.Product{
-		ID:   req.ID,
-		Name: req.Name,
+		ID:       req.ID,
+		Name:     req.Name,
+		Category: req.Category,
+		Tags:     req.Tags,
 	}
 
-	err := h.repository.CreateProduct(ctx, &product)
+	event := domain.NewProductEvent(domain.ProductUpdated, product.ID, nil, &product)
+	err := h.repository.UpdateProduct(ctx, &product, event)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, domain.ErrProductNotFound) {
+			return nil, fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return nil, storeError(err)
 	}
 
+	h.events.Publish(*event)
+
 	return &UpdateProductResponse{ID: product.ID}, nil
 }
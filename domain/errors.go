@@ -0,0 +1,16 @@
+package domain
+
+import "errors"
+
+var (
+	ErrProductNotFound      = errors.New("product not found")
+	ErrProductAlreadyExists = errors.New("product already exists")
+	// ErrReservedProductID is returned for ids that the store keeps for its own documents
+	ErrReservedProductID = errors.New("product id is reserved")
+	// ErrBulkRolledBack is reported for items of an all-or-nothing bulk operation that were
+	// rolled back because another item failed
+	ErrBulkRolledBack = errors.New("rolled back because another item failed")
+	// ErrStoreUnavailable is returned while the products cannot be reached, e.g. before the first
+	// connection to the database
+	ErrStoreUnavailable = errors.New("product store is unavailable")
+)
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ProductEventType string
+
+const (
+	ProductCreated ProductEventType = "product.created"
+	ProductUpdated ProductEventType = "product.updated"
+	ProductDeleted ProductEventType = "product.deleted"
+)
+
+// ProductEvent describes a change of a product. Before is not set on creation and After not on deletion.
+// Tenant is set for the products of a tenant, which only its own subscribers see.
+type ProductEvent struct {
+	ID         string           `json:"id"`
+	Type       ProductEventType `json:"type"`
+	ProductID  string           `json:"productId"`
+	Tenant     string           `json:"tenant,omitempty"`
+	Before     *Product         `json:"before,omitempty"`
+	After      *Product         `json:"after,omitempty"`
+	OccurredAt time.Time        `json:"occurredAt"`
+}
+
+func NewProductEvent(eventType ProductEventType, productID string, before, after *Product) *ProductEvent {
+	return &ProductEvent{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		ProductID:  productID,
+		Before:     before,
+		After:      after,
+		OccurredAt: time.Now().UTC(),
+	}
+}
+
+// Key orders the events of a product: the product id, prefixed with the tenant for the products of a tenant
+func (e *ProductEvent) Key() string {
+	if e.Tenant == "" {
+		return e.ProductID
+	}
+	return e.Tenant + "::" + e.ProductID
+}
+
+// Product is the latest known state of the product, which is Before for deletions
+func (e *ProductEvent) Product() *Product {
+	if e.After != nil {
+		return e.After
+	}
+	return e.Before
+}
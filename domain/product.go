@@ -1,6 +1,10 @@
 package domain
 
 type Product struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// Stale is set when the product was read from a replica, which may lag behind the active copy
+	Stale bool `json:"-"`
 }
@@ -0,0 +1,36 @@
+package domain
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ProductSearchQuery describes a product search. Filters of the same field are OR'ed, different fields are AND'ed.
+type ProductSearchQuery struct {
+	Text       string
+	Categories []string
+	Tags       []string
+	// Facets lists the fields to count values of over all matching products
+	Facets    []string
+	Sort      []SortField
+	Highlight bool
+	Limit     int
+	Offset    int
+}
+
+type ProductSearchHit struct {
+	Product    Product
+	Score      float64
+	Highlights map[string][]string
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type ProductSearchResult struct {
+	Hits   []ProductSearchHit
+	Total  int
+	Facets map[string][]FacetCount
+}
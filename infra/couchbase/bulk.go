@@ -0,0 +1,216 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"sync"
+
+	gocbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func (r *Repository) GetProducts(ctx context.Context, ids []string) ([]*domain.Product, []error) {
+	ctx, span := r.tracer.Wrapped().Start(ctx, "GetProducts")
+	defer span.End()
+
+	ops := make([]gocb.BulkOp, len(ids))
+	for i, id := range ids {
+		ops[i] = &gocb.GetOp{ID: id}
+	}
+
+	products := make([]*domain.Product, len(ids))
+	errs := r.do(ctx, span, ops, func(op gocb.BulkOp) error { return op.(*gocb.GetOp).Err })
+	for i, op := range ops {
+		if errs[i] != nil {
+			continue
+		}
+		var product domain.Product
+		if errs[i] = op.(*gocb.GetOp).Result.Content(&product); errs[i] == nil {
+			products[i] = &product
+		}
+	}
+
+	return products, errs
+}
+
+func (r *Repository) CreateProducts(ctx context.Context, products []*domain.Product, events []*domain.ProductEvent, atomic bool) []error {
+	ctx, span := r.tracer.Wrapped().Start(ctx, "CreateProducts")
+	defer span.End()
+	stampTenant(ctx, events...)
+
+	return r.runBulk(ctx, len(products), atomic, func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection, i int) error {
+		if _, err := tx.Insert(collection, products[i].ID, products[i]); err != nil {
+			return err
+		}
+		return r.insertOutbox(tx, events[i])
+	})
+}
+
+func (r *Repository) UpsertProducts(ctx context.Context, products []*domain.Product, events []*domain.ProductEvent, atomic bool) []error {
+	ctx, span := r.tracer.Wrapped().Start(ctx, "UpsertProducts")
+	defer span.End()
+	stampTenant(ctx, events...)
+
+	return r.runBulk(ctx, len(products), atomic, func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection, i int) error {
+		if r.internalKey(keyspace{scope: collection.ScopeName(), collection: collection.Name()}, products[i].ID) {
+			return domain.ErrReservedProductID
+		}
+		doc, err := tx.Get(collection, products[i].ID)
+		switch {
+		case errors.Is(err, gocb.ErrDocumentNotFound):
+			events[i].Type = domain.ProductCreated
+			events[i].Before = nil
+			_, err = tx.Insert(collection, products[i].ID, products[i])
+		case err == nil:
+			events[i].Type = domain.ProductUpdated
+			var before domain.Product
+			if err := doc.Content(&before); err != nil {
+				return err
+			}
+			events[i].Before = &before
+			_, err = tx.Replace(doc, products[i])
+		}
+		if err != nil {
+			return err
+		}
+		return r.insertOutbox(tx, events[i])
+	})
+}
+
+func (r *Repository) DeleteProducts(ctx context.Context, ids []string, events []*domain.ProductEvent, atomic bool) []error {
+	ctx, span := r.tracer.Wrapped().Start(ctx, "DeleteProducts")
+	defer span.End()
+	stampTenant(ctx, events...)
+
+	return r.runBulk(ctx, len(ids), atomic, func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection, i int) error {
+		doc, err := tx.Get(collection, ids[i])
+		if err != nil {
+			return err
+		}
+		var before domain.Product
+		if err := doc.Content(&before); err != nil {
+			return err
+		}
+		events[i].Before = &before
+		if err := tx.Remove(doc); err != nil {
+			return err
+		}
+		return r.insertOutbox(tx, events[i])
+	})
+}
+
+// runBulk writes every item with its outbox record in a transaction, one for all items when atomic and
+// one per item otherwise, so that no event is lost
+func (r *Repository) runBulk(ctx context.Context, count int, atomic bool, fn func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection, i int) error) []error {
+	if atomic {
+		return r.runAtomic(ctx, count, fn)
+	}
+	return r.runEach(ctx, count, fn)
+}
+
+// runEach applies fn to every item in a transaction of its own, the items run concurrently
+func (r *Repository) runEach(ctx context.Context, count int, fn func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection, i int) error) []error {
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.transact(ctx, r.operations.bulk, func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection) error {
+				return fn(tx, collection, i)
+			})
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// do sends the operations as one pipelined batch and collects the error of every operation
+func (r *Repository) do(ctx context.Context, span trace.Span, ops []gocb.BulkOp, opErr func(op gocb.BulkOp) error) []error {
+	errs := make([]error, len(ops))
+
+	collection, err := r.products(ctx)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	err = collection.Do(ops, &gocb.BulkOpOptions{
+		Timeout:    r.operations.bulk.timeout,
+		Context:    ctx,
+		ParentSpan: gocbopentelemetry.NewOpenTelemetryRequestSpan(ctx, span),
+	})
+	if err != nil {
+		zap.L().Error("Failed to run bulk operation", zap.Error(err))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	for i, op := range ops {
+		errs[i] = mapError(opErr(op))
+	}
+	return errs
+}
+
+// runAtomic applies fn to every item inside one transaction. The item that made the transaction fail
+// gets the cause, every other item is reported as rolled back.
+func (r *Repository) runAtomic(ctx context.Context, count int, fn func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection, i int) error) []error {
+	errs := make([]error, count)
+	failed := -1
+	collection, err := r.products(ctx)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	_, err = r.cluster.Transactions().Run(func(tx *gocb.TransactionAttemptContext) error {
+		failed = -1
+		for i := 0; i < count; i++ {
+			if err := fn(tx, collection, i); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	}, &gocb.TransactionOptions{
+		Timeout:         r.operations.bulk.timeout,
+		DurabilityLevel: r.operations.bulk.durability,
+	})
+	if err == nil {
+		return errs
+	}
+
+	zap.L().Error("Failed to run bulk transaction", zap.Error(err))
+	for i := range errs {
+		errs[i] = domain.ErrBulkRolledBack
+	}
+	if failed >= 0 {
+		errs[failed] = mapError(err)
+	} else {
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// mapError translates Couchbase errors into domain errors
+func mapError(err error) error {
+	switch {
+	case errors.Is(err, gocb.ErrDocumentNotFound):
+		return domain.ErrProductNotFound
+	case errors.Is(err, gocb.ErrDocumentExists):
+		return domain.ErrProductAlreadyExists
+	default:
+		return err
+	}
+}
@@ -0,0 +1,65 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/pkg/changefeed"
+	"golang-fiber-poc/pkg/config"
+	"os"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const checkpointKeyPrefix = "checkpoint::"
+
+// CheckpointStore keeps the change feed checkpoints of an instance of the service in one document next to
+// the products. Every instance streams the whole bucket, so each one has its own document and resumes from
+// its own position.
+type CheckpointStore struct {
+	collection *gocb.Collection
+	key        string
+}
+
+func NewCheckpointStore(repository *Repository, changeFeedConfig config.ChangeFeedConfig) (*CheckpointStore, error) {
+	instance := changeFeedConfig.Instance
+	if instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("changefeed.instance is not set and the hostname is unknown: %w", err)
+		}
+		instance = hostname
+	}
+	return &CheckpointStore{
+		collection: repository.collection(EntityCheckpoints),
+		key:        checkpointKeyPrefix + changeFeedConfig.Name + "::" + instance,
+	}, nil
+}
+
+func (s *CheckpointStore) Load(ctx context.Context) (changefeed.Checkpoints, error) {
+	data, err := s.collection.Get(s.key, &gocb.GetOptions{
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var checkpoints changefeed.Checkpoints
+	if err := data.Content(&checkpoints); err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+func (s *CheckpointStore) Save(ctx context.Context, checkpoints changefeed.Checkpoints) error {
+	_, err := s.collection.Upsert(s.key, checkpoints, &gocb.UpsertOptions{
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	return err
+}
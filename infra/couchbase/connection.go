@@ -0,0 +1,138 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/domain"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"go.uber.org/zap"
+)
+
+// connection tracks whether the bucket can be used. The repository starts before the bucket is
+// reachable and waits for it in the background, so a slow database does not stop the service.
+type connection struct {
+	mu    sync.RWMutex
+	ready bool
+	err   error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newConnection() *connection {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &connection{
+		err:    errors.New("connecting"),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *connection) set(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ready = err == nil
+	c.err = err
+}
+
+// connect waits until the bucket is ready, then pings it to notice when it is lost and found again.
+// gocb reconnects by itself, the pings only keep the status up to date.
+func (r *Repository) connect(autoCreate bool) {
+	defer close(r.connection.done)
+
+	for attempt := 1; ; attempt++ {
+		err := r.bucket.WaitUntilReady(20*time.Second, &gocb.WaitUntilReadyOptions{Context: r.connection.ctx})
+		if err == nil && autoCreate {
+			if err = r.setup(); err != nil {
+				err = fmt.Errorf("create collections and indexes: %w", err)
+			}
+		}
+		if err == nil {
+			break
+		}
+		if r.connection.ctx.Err() != nil {
+			return
+		}
+		r.connection.set(err)
+		zap.L().Error("Failed to connect to couchbase, retrying", zap.Error(err), zap.Int("attempt", attempt))
+
+		if !r.wait(min(time.Duration(attempt)*time.Second, 30*time.Second)) {
+			return
+		}
+	}
+	r.connection.set(nil)
+	zap.L().Info("Connected to couchbase")
+
+	for r.wait(10 * time.Second) {
+		err := r.ping()
+		r.connection.mu.RLock()
+		ready := r.connection.ready
+		r.connection.mu.RUnlock()
+
+		switch {
+		case err != nil && ready:
+			zap.L().Error("Lost connection to couchbase", zap.Error(err))
+		case err == nil && !ready:
+			zap.L().Info("Reconnected to couchbase")
+		}
+		r.connection.set(err)
+	}
+}
+
+// wait pauses for d and tells whether the repository is still open
+func (r *Repository) wait(d time.Duration) bool {
+	select {
+	case <-r.connection.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// ping succeeds when at least one data node answers
+func (r *Repository) ping() error {
+	result, err := r.bucket.Ping(&gocb.PingOptions{
+		ServiceTypes: []gocb.ServiceType{gocb.ServiceTypeKeyValue},
+		Timeout:      3 * time.Second,
+		Context:      r.connection.ctx,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = errors.New("no data node answered")
+	for _, report := range result.Services[gocb.ServiceTypeKeyValue] {
+		if report.State == gocb.PingStateOk {
+			return nil
+		}
+		if report.Error != "" {
+			err = fmt.Errorf("%s: %s", report.Remote, report.Error)
+		}
+	}
+	return err
+}
+
+// Ready returns domain.ErrStoreUnavailable with the cause until the bucket can be used
+func (r *Repository) Ready(_ context.Context) error {
+	r.connection.mu.RLock()
+	defer r.connection.mu.RUnlock()
+
+	if r.connection.ready {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", domain.ErrStoreUnavailable, r.connection.err)
+}
+
+// Close stops the reconnection and closes the cluster
+func (r *Repository) Close() error {
+	r.connection.cancel()
+	<-r.connection.done
+	return r.cluster.Close(nil)
+}
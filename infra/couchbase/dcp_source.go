@@ -0,0 +1,300 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/pkg/changefeed"
+	"golang-fiber-poc/pkg/config"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+	"github.com/google/uuid"
+)
+
+// DCPSource streams the mutations and deletions of the shared products collection over DCP, including
+// the writes made by other tools. The collections of the tenants are not streamed.
+type DCPSource struct {
+	repository      *Repository
+	couchbaseConfig config.CouchbaseConfig
+	name            string
+	fromBeginning   bool
+}
+
+func NewDCPSource(repository *Repository, couchbaseConfig config.CouchbaseConfig, changeFeedConfig config.ChangeFeedConfig) *DCPSource {
+	return &DCPSource{
+		repository:      repository,
+		couchbaseConfig: couchbaseConfig,
+		name:            changeFeedConfig.Name,
+		fromBeginning:   changeFeedConfig.From == "beginning",
+	}
+}
+
+// Internal tells whether a streamed document is kept by the service next to the products, e.g. an outbox
+// record in the products collection. Documents of other collections are not streamed.
+func (s *DCPSource) Internal(key string) bool {
+	return s.repository.internalKey(s.repository.keyspaces[EntityProducts], key)
+}
+
+func (s *DCPSource) Stream(ctx context.Context, from changefeed.Checkpoints, handle func(changefeed.Change)) error {
+	agentConfig := gocbcore.DCPAgentConfig{
+		UserAgent:  "golang-fiber-poc",
+		BucketName: s.couchbaseConfig.Bucket,
+	}
+	if err := agentConfig.FromConnStr(s.couchbaseConfig.URL); err != nil {
+		return err
+	}
+	agentConfig.SecurityConfig.Auth = gocbcore.PasswordAuthProvider{
+		Username: s.couchbaseConfig.Username,
+		Password: s.couchbaseConfig.Password.Value(),
+	}
+	agentConfig.DCPConfig.BufferSize = 8 * 1024 * 1024
+
+	// Without collections the stream has the default collection only
+	var streamOptions gocbcore.OpenStreamOptions
+	if space := s.repository.keyspaces[EntityProducts]; !space.isDefault() {
+		collectionID, err := s.repository.collectionID(space)
+		if err != nil {
+			return err
+		}
+		agentConfig.IoConfig.UseCollections = true
+		streamOptions.FilterOptions = &gocbcore.OpenStreamFilterOptions{CollectionIDs: []uint32{collectionID}}
+	}
+
+	// Every connection needs its own stream name
+	agent, err := gocbcore.CreateDcpAgent(&agentConfig, s.name+"-"+uuid.New().String(), memd.DcpOpenFlagProducer)
+	if err != nil {
+		return err
+	}
+	defer agent.Close()
+
+	if err := await(func(cb func(error)) (gocbcore.PendingOp, error) {
+		return agent.WaitUntilReady(time.Now().Add(20*time.Second), gocbcore.WaitUntilReadyOptions{}, func(_ *gocbcore.WaitUntilReadyResult, err error) {
+			cb(err)
+		})
+	}); err != nil {
+		return err
+	}
+
+	snapshot, err := agent.ConfigSnapshot()
+	if err != nil {
+		return err
+	}
+	partitions, err := snapshot.NumVbuckets()
+	if err != nil {
+		return err
+	}
+
+	var latest map[uint16]uint64
+	if !s.fromBeginning {
+		if latest, err = latestSeqNos(agent, snapshot); err != nil {
+			return err
+		}
+	}
+
+	stream := &dcpStream{
+		agent:     agent,
+		options:   streamOptions,
+		handle:    handle,
+		positions: make([]changefeed.Checkpoint, partitions),
+		failed:    make(chan error, 1),
+	}
+	for partition := range uint16(partitions) {
+		checkpoint, ok := from[partition]
+		if !ok && !s.fromBeginning {
+			if checkpoint, err = stream.latest(partition, latest[partition]); err != nil {
+				return err
+			}
+		}
+		if err := stream.open(partition, checkpoint); err != nil {
+			return fmt.Errorf("open stream of partition %d: %w", partition, err)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-stream.failed:
+		return err
+	}
+}
+
+// latestSeqNos returns the current sequence number of every partition
+func latestSeqNos(agent *gocbcore.DCPAgent, snapshot *gocbcore.ConfigSnapshot) (map[uint16]uint64, error) {
+	servers, err := snapshot.NumServers()
+	if err != nil {
+		return nil, err
+	}
+
+	seqNos := make(map[uint16]uint64)
+	for server := range servers {
+		var entries []gocbcore.VbSeqNoEntry
+		err := await(func(cb func(error)) (gocbcore.PendingOp, error) {
+			return agent.GetVbucketSeqnos(server, memd.VbucketStateActive, gocbcore.GetVbucketSeqnoOptions{}, func(result []gocbcore.VbSeqNoEntry, err error) {
+				entries = result
+				cb(err)
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			seqNos[entry.VbID] = uint64(entry.SeqNo)
+		}
+	}
+	return seqNos, nil
+}
+
+// dcpStream observes the streams of all partitions and keeps the position of each one
+type dcpStream struct {
+	agent   *gocbcore.DCPAgent
+	options gocbcore.OpenStreamOptions
+	handle  func(changefeed.Change)
+	failed  chan error
+
+	mu        sync.Mutex
+	positions []changefeed.Checkpoint
+}
+
+// latest returns the checkpoint of the current end of a partition
+func (s *dcpStream) latest(partition uint16, seqNo uint64) (changefeed.Checkpoint, error) {
+	failoverLog, err := s.failoverLog(partition)
+	if err != nil {
+		return changefeed.Checkpoint{}, err
+	}
+	return changefeed.Checkpoint{UUID: uint64(failoverLog[0].VbUUID), SeqNo: seqNo, SnapshotStart: seqNo, SnapshotEnd: seqNo}, nil
+}
+
+func (s *dcpStream) open(partition uint16, checkpoint changefeed.Checkpoint) error {
+	snapshotStart := min(checkpoint.SnapshotStart, checkpoint.SeqNo)
+	snapshotEnd := max(checkpoint.SnapshotEnd, checkpoint.SeqNo)
+
+	// Events may arrive before OpenStream returns, the position must be in place
+	s.mu.Lock()
+	s.positions[partition] = changefeed.Checkpoint{UUID: checkpoint.UUID, SeqNo: checkpoint.SeqNo, SnapshotStart: snapshotStart, SnapshotEnd: snapshotEnd}
+	s.mu.Unlock()
+
+	err := await(func(cb func(error)) (gocbcore.PendingOp, error) {
+		return s.agent.OpenStream(partition, 0, gocbcore.VbUUID(checkpoint.UUID), gocbcore.SeqNo(checkpoint.SeqNo),
+			gocbcore.SeqNo(math.MaxUint64), gocbcore.SeqNo(snapshotStart), gocbcore.SeqNo(snapshotEnd), s, s.options,
+			func(entries []gocbcore.FailoverEntry, err error) {
+				// The latest entry identifies the history the stream follows
+				if err == nil && len(entries) > 0 {
+					s.mu.Lock()
+					s.positions[partition].UUID = uint64(entries[0].VbUUID)
+					s.mu.Unlock()
+				}
+				cb(err)
+			})
+	})
+
+	// The partition lost the changes after the checkpoint in a failover, resume from where it was rolled back to
+	var rollback gocbcore.DCPRollbackError
+	if errors.As(err, &rollback) {
+		entries, err := s.failoverLog(partition)
+		if err != nil {
+			return err
+		}
+		resume := changefeed.Checkpoint{SeqNo: uint64(rollback.SeqNo), SnapshotStart: uint64(rollback.SeqNo), SnapshotEnd: uint64(rollback.SeqNo)}
+		for _, entry := range entries {
+			if uint64(entry.SeqNo) <= resume.SeqNo {
+				resume.UUID = uint64(entry.VbUUID)
+				break
+			}
+		}
+		return s.open(partition, resume)
+	}
+	return err
+}
+
+func (s *dcpStream) failoverLog(partition uint16) ([]gocbcore.FailoverEntry, error) {
+	var failoverLog []gocbcore.FailoverEntry
+	err := await(func(cb func(error)) (gocbcore.PendingOp, error) {
+		return s.agent.GetFailoverLog(partition, func(entries []gocbcore.FailoverEntry, err error) {
+			failoverLog = entries
+			cb(err)
+		})
+	})
+	if err == nil && len(failoverLog) == 0 {
+		err = fmt.Errorf("empty failover log of partition %d", partition)
+	}
+	return failoverLog, err
+}
+
+func (s *dcpStream) change(partition uint16, seqNo uint64, change changefeed.Change) {
+	s.mu.Lock()
+	s.positions[partition].SeqNo = seqNo
+	change.Partition = partition
+	change.Checkpoint = s.positions[partition]
+	s.mu.Unlock()
+
+	s.handle(change)
+}
+
+func (s *dcpStream) SnapshotMarker(marker gocbcore.DcpSnapshotMarker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.positions[marker.VbID].SnapshotStart = marker.StartSeqNo
+	s.positions[marker.VbID].SnapshotEnd = marker.EndSeqNo
+}
+
+func (s *dcpStream) Mutation(mutation gocbcore.DcpMutation) {
+	s.change(mutation.VbID, mutation.SeqNo, changefeed.Change{
+		Key:      string(mutation.Key),
+		Value:    mutation.Value,
+		Cas:      mutation.Cas,
+		Revision: mutation.RevNo,
+	})
+}
+
+func (s *dcpStream) Deletion(deletion gocbcore.DcpDeletion) {
+	s.change(deletion.VbID, deletion.SeqNo, changefeed.Change{
+		Key:      string(deletion.Key),
+		Deleted:  true,
+		Cas:      deletion.Cas,
+		Revision: deletion.RevNo,
+	})
+}
+
+func (s *dcpStream) Expiration(expiration gocbcore.DcpExpiration) {
+	s.change(expiration.VbID, expiration.SeqNo, changefeed.Change{
+		Key:      string(expiration.Key),
+		Deleted:  true,
+		Cas:      expiration.Cas,
+		Revision: expiration.RevNo,
+	})
+}
+
+// End is called when a partition moves to another node or the connection drops. The whole source is
+// restarted from the checkpoints.
+func (s *dcpStream) End(end gocbcore.DcpStreamEnd, err error) {
+	if err == nil {
+		err = fmt.Errorf("stream of partition %d ended", end.VbID)
+	}
+	select {
+	case s.failed <- err:
+	default:
+	}
+}
+
+func (s *dcpStream) CreateCollection(gocbcore.DcpCollectionCreation)     {}
+func (s *dcpStream) DeleteCollection(gocbcore.DcpCollectionDeletion)     {}
+func (s *dcpStream) FlushCollection(gocbcore.DcpCollectionFlush)         {}
+func (s *dcpStream) CreateScope(gocbcore.DcpScopeCreation)               {}
+func (s *dcpStream) DeleteScope(gocbcore.DcpScopeDeletion)               {}
+func (s *dcpStream) ModifyCollection(gocbcore.DcpCollectionModification) {}
+func (s *dcpStream) OSOSnapshot(gocbcore.DcpOSOSnapshot)                 {}
+func (s *dcpStream) SeqNoAdvanced(gocbcore.DcpSeqNoAdvanced)             {}
+
+// await turns a gocbcore operation with a callback into a blocking call
+func await(op func(cb func(error)) (gocbcore.PendingOp, error)) error {
+	done := make(chan error, 1)
+	if _, err := op(func(err error) { done <- err }); err != nil {
+		return err
+	}
+	return <-done
+}
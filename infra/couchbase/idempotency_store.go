@@ -0,0 +1,104 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/pkg/middlewares/idempotency"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const idempotencyKeyPrefix = "idempotency::"
+
+// IdempotencyStore keeps idempotency records next to the products so that
+// every instance of the service sees the same keys. It fails with domain.ErrStoreUnavailable until the
+// bucket is ready.
+type IdempotencyStore struct {
+	collection *gocb.Collection
+	ready      func(ctx context.Context) error
+}
+
+func NewIdempotencyStore(repository *Repository) *IdempotencyStore {
+	return &IdempotencyStore{collection: repository.collection(EntityIdempotency), ready: repository.Ready}
+}
+
+// acquireAttempts bounds the retries of a key that expires or is released between the insert and the get
+const acquireAttempts = 3
+
+func (s *IdempotencyStore) Acquire(ctx context.Context, key string, record *idempotency.Record, lifetime time.Duration) (*idempotency.Record, bool, error) {
+	if err := s.ready(ctx); err != nil {
+		return nil, false, err
+	}
+	for range acquireAttempts {
+		_, err := s.collection.Insert(idempotencyKeyPrefix+key, record, &gocb.InsertOptions{
+			Expiry:  lifetime,
+			Timeout: 3 * time.Second,
+			Context: ctx,
+		})
+		if err == nil {
+			return nil, true, nil
+		}
+		if !errors.Is(err, gocb.ErrDocumentExists) {
+			return nil, false, err
+		}
+
+		existing, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil {
+			return existing, false, nil
+		}
+	}
+	return nil, false, errors.New("idempotency key " + key + " keeps changing")
+}
+
+func (s *IdempotencyStore) Get(ctx context.Context, key string) (*idempotency.Record, error) {
+	if err := s.ready(ctx); err != nil {
+		return nil, err
+	}
+	data, err := s.collection.Get(idempotencyKeyPrefix+key, &gocb.GetOptions{
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var record idempotency.Record
+	if err := data.Content(&record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (s *IdempotencyStore) Save(ctx context.Context, key string, record *idempotency.Record, lifetime time.Duration) error {
+	if err := s.ready(ctx); err != nil {
+		return err
+	}
+	_, err := s.collection.Upsert(idempotencyKeyPrefix+key, record, &gocb.UpsertOptions{
+		Expiry:  lifetime,
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	return err
+}
+
+func (s *IdempotencyStore) Delete(ctx context.Context, key string) error {
+	if err := s.ready(ctx); err != nil {
+		return err
+	}
+	_, err := s.collection.Remove(idempotencyKeyPrefix+key, &gocb.RemoveOptions{
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return nil
+	}
+	return err
+}
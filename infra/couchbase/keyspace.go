@@ -0,0 +1,176 @@
+package couchbase
+
+import (
+	"context"
+	"fmt"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/tenant"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocbcore/v10"
+)
+
+// Entities stored in the bucket, the keys of couchbase.collections. The config validates them too.
+const (
+	EntityProducts    = config.EntityProducts
+	EntityOutbox      = config.EntityOutbox
+	EntityIdempotency = config.EntityIdempotency
+	EntityProcessed   = config.EntityProcessed
+	EntityCheckpoints = config.EntityCheckpoints
+)
+
+var entities = config.Entities
+
+// keyspace is the scope and collection of an entity
+type keyspace struct {
+	scope      string
+	collection string
+}
+
+// parseKeyspace reads "scope.collection". A bare collection is in the default scope, and an empty
+// keyspace is the default collection.
+func parseKeyspace(value string) (keyspace, error) {
+	if value == "" {
+		return keyspace{scope: "_default", collection: "_default"}, nil
+	}
+
+	scope, collection, found := strings.Cut(value, ".")
+	if !found {
+		scope, collection = "_default", value
+	}
+	if scope == "" || collection == "" || strings.Contains(collection, ".") {
+		return keyspace{}, fmt.Errorf("invalid keyspace %q, expected scope.collection", value)
+	}
+	return keyspace{scope: scope, collection: collection}, nil
+}
+
+func (k keyspace) isDefault() bool {
+	return k.scope == "_default" && k.collection == "_default"
+}
+
+func (k keyspace) String() string {
+	return k.scope + "." + k.collection
+}
+
+// parseKeyspaces reads the keyspace of every entity from the config
+func parseKeyspaces(collections map[string]string) (map[string]keyspace, error) {
+	for entity := range collections {
+		if !isEntity(entity) {
+			return nil, fmt.Errorf("unknown entity %q in couchbase.collections", entity)
+		}
+	}
+
+	keyspaces := make(map[string]keyspace, len(entities))
+	for _, entity := range entities {
+		space, err := parseKeyspace(collections[entity])
+		if err != nil {
+			return nil, fmt.Errorf("couchbase.collections.%s: %w", entity, err)
+		}
+		keyspaces[entity] = space
+	}
+	return keyspaces, nil
+}
+
+func isEntity(name string) bool {
+	for _, entity := range entities {
+		if entity == name {
+			return true
+		}
+	}
+	return false
+}
+
+// keyPrefixes are the prefixes of the keys of the documents of every entity but the products
+var keyPrefixes = map[string][]string{
+	EntityOutbox:      {outboxKeyPrefix, outboxSequencePrefix, outboxLockKey},
+	EntityIdempotency: {idempotencyKeyPrefix},
+	EntityProcessed:   {processedKeyPrefix},
+	EntityCheckpoints: {checkpointKeyPrefix},
+}
+
+// internalKey tells whether key, in the keyspace of products, is that of a document of an entity kept in
+// the same keyspace. Products cannot have these keys.
+func (r *Repository) internalKey(space keyspace, key string) bool {
+	for entity, prefixes := range keyPrefixes {
+		if r.keyspaces[entity] != space {
+			continue
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Repository) collection(entity string) *gocb.Collection {
+	space := r.keyspaces[entity]
+	return r.bucket.Scope(space.scope).Collection(space.collection)
+}
+
+// productKeyspace returns where the products of the tenant of ctx are. Every tenant has the products
+// collection in its own scope, requests without a tenant use the configured keyspace. It fails until the
+// bucket is ready.
+func (r *Repository) productKeyspace(ctx context.Context) (keyspace, error) {
+	if err := r.Ready(ctx); err != nil {
+		return keyspace{}, err
+	}
+
+	space := r.keyspaces[EntityProducts]
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return space, nil
+	}
+
+	scope, ok := r.tenants[tenantID]
+	if !ok {
+		return keyspace{}, fmt.Errorf("%w: %s", tenant.ErrUnknownTenant, tenantID)
+	}
+	return keyspace{scope: scope, collection: space.collection}, nil
+}
+
+func (r *Repository) products(ctx context.Context) (*gocb.Collection, error) {
+	space, err := r.productKeyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.bucket.Scope(space.scope).Collection(space.collection), nil
+}
+
+// stampTenant marks the events of the products of a tenant
+func stampTenant(ctx context.Context, events ...*domain.ProductEvent) {
+	tenantID, _ := tenant.FromContext(ctx)
+	for _, event := range events {
+		event.Tenant = tenantID
+	}
+}
+
+// collectionID looks up the id of a collection, which DCP streams are filtered by
+func (r *Repository) collectionID(space keyspace) (uint32, error) {
+	agent, err := r.bucket.Internal().IORouter()
+	if err != nil {
+		return 0, err
+	}
+
+	var collectionID uint32
+	err = await(func(cb func(error)) (gocbcore.PendingOp, error) {
+		return agent.GetCollectionID(space.scope, space.collection, gocbcore.GetCollectionIDOptions{Deadline: time.Now().Add(10 * time.Second)},
+			func(result *gocbcore.GetCollectionIDResult, err error) {
+				if err == nil {
+					collectionID = result.CollectionID
+				}
+				cb(err)
+			})
+	})
+	return collectionID, err
+}
+
+// path returns the N1QL path of the keyspace of an entity
+func (r *Repository) path(entity string) string {
+	space := r.keyspaces[entity]
+	return fmt.Sprintf("`%s`.`%s`.`%s`", r.bucket.Name(), space.scope, space.collection)
+}
@@ -0,0 +1,31 @@
+package couchbase
+
+import "testing"
+
+func TestInternalKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		collections map[string]string
+		key         string
+		want        bool
+	}{
+		{name: "outbox record in the shared collection", key: "outbox::1", want: true},
+		{name: "checkpoint in the shared collection", key: "checkpoint::golang-fiber-poc::host", want: true},
+		{name: "product with a separator", key: "brand::42", want: false},
+		{name: "outbox in its own collection", collections: map[string]string{"outbox": "app.outbox"}, key: "outbox::1", want: false},
+		{name: "idempotency still shared", collections: map[string]string{"outbox": "app.outbox"}, key: "idempotency::a:b", want: true},
+		{name: "products in their own collection", collections: map[string]string{"products": "app.products"}, key: "outbox::1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyspaces, err := parseKeyspaces(tt.collections)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &Repository{keyspaces: keyspaces}
+			if got := r.internalKey(keyspaces[EntityProducts], tt.key); got != tt.want {
+				t.Errorf("internalKey(%s) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package couchbase
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/di"
+	"golang-fiber-poc/pkg/lifecycle"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Module provides the repository and the stores kept in the bucket. The repository is closed when it
+// stops, after the components that were built with it.
+func Module(c *di.Container) {
+	di.Provide(c, func(c *di.Container) (*Repository, error) {
+		repository := NewRepository(di.MustGet[*sdktrace.TracerProvider](c), di.MustGet[*config.AppConfig](c).Couchbase)
+		di.MustGet[*lifecycle.Manager](c).Append(lifecycle.Hook{
+			Name: "couchbase",
+			OnStop: func(context.Context) error {
+				return repository.Close()
+			},
+		})
+		return repository, nil
+	})
+	di.Provide(c, func(c *di.Container) (*OutboxStore, error) {
+		return NewOutboxStore(di.MustGet[*Repository](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*IdempotencyStore, error) {
+		return NewIdempotencyStore(di.MustGet[*Repository](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*ProcessedStore, error) {
+		return NewProcessedStore(di.MustGet[*Repository](c)), nil
+	})
+	di.Provide(c, func(c *di.Container) (*CheckpointStore, error) {
+		return NewCheckpointStore(di.MustGet[*Repository](c), di.MustGet[*config.AppConfig](c).ChangeFeed)
+	})
+	di.Provide(c, func(c *di.Container) (*DCPSource, error) {
+		appConfig := di.MustGet[*config.AppConfig](c)
+		return NewDCPSource(di.MustGet[*Repository](c), appConfig.Couchbase, appConfig.ChangeFeed), nil
+	})
+}
@@ -0,0 +1,109 @@
+package couchbase
+
+import (
+	"fmt"
+	"golang-fiber-poc/pkg/config"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// operation holds how the repository runs one kind of operation
+type operation struct {
+	timeout         time.Duration
+	durability      gocb.DurabilityLevel
+	replicaFallback bool
+	scanConsistency gocb.QueryScanConsistency
+}
+
+type operations struct {
+	get    operation
+	create operation
+	update operation
+	bulk   operation
+	search operation
+	outbox operation
+}
+
+var durabilityLevels = map[string]gocb.DurabilityLevel{
+	"none":                     gocb.DurabilityLevelNone,
+	"majority":                 gocb.DurabilityLevelMajority,
+	"majorityandpersistactive": gocb.DurabilityLevelMajorityAndPersistOnMaster,
+	"persisttomajority":        gocb.DurabilityLevelPersistToMajority,
+}
+
+var scanConsistencies = map[string]gocb.QueryScanConsistency{
+	"notbounded":  gocb.QueryScanConsistencyNotBounded,
+	"requestplus": gocb.QueryScanConsistencyRequestPlus,
+}
+
+// parseOperations reads couchbase.operations over the defaults
+func parseOperations(operationsConfig config.CouchbaseOperationsConfig) (operations, error) {
+	var ops operations
+	var err error
+	if ops.get, err = parseOperation("get", operationsConfig.Get, operation{timeout: 3 * time.Second}); err != nil {
+		return ops, err
+	}
+	if ops.create, err = parseOperation("create", operationsConfig.Create, operation{timeout: 5 * time.Second}); err != nil {
+		return ops, err
+	}
+	if ops.update, err = parseOperation("update", operationsConfig.Update, operation{timeout: 5 * time.Second}); err != nil {
+		return ops, err
+	}
+	if ops.bulk, err = parseOperation("bulk", operationsConfig.Bulk, operation{timeout: 15 * time.Second}); err != nil {
+		return ops, err
+	}
+	if ops.search, err = parseOperation("search", operationsConfig.Search, operation{timeout: 3 * time.Second}); err != nil {
+		return ops, err
+	}
+	// Records written just before must be seen, or a later record of the same product could overtake them
+	outboxDefaults := operation{timeout: 5 * time.Second, scanConsistency: gocb.QueryScanConsistencyRequestPlus}
+	if ops.outbox, err = parseOperation("outbox", operationsConfig.Outbox, outboxDefaults); err != nil {
+		return ops, err
+	}
+	return ops, nil
+}
+
+func parseOperation(name string, operationConfig config.CouchbaseOperationConfig, defaults operation) (operation, error) {
+	op := defaults
+	if operationConfig.Timeout > 0 {
+		op.timeout = operationConfig.Timeout
+	}
+
+	if operationConfig.Durability != "" {
+		if !writes(name) {
+			return op, fmt.Errorf("couchbase.operations.%s: durability only applies to writes", name)
+		}
+		durability, ok := durabilityLevels[strings.ToLower(operationConfig.Durability)]
+		if !ok {
+			return op, fmt.Errorf("couchbase.operations.%s: unknown durability %q", name, operationConfig.Durability)
+		}
+		op.durability = durability
+	}
+
+	if operationConfig.ReplicaFallback && name != "get" {
+		return op, fmt.Errorf("couchbase.operations.%s: replica fallback only applies to get", name)
+	}
+	op.replicaFallback = operationConfig.ReplicaFallback
+
+	if operationConfig.ScanConsistency != "" {
+		if name != "outbox" && name != "search" {
+			return op, fmt.Errorf("couchbase.operations.%s: scan consistency only applies to queries", name)
+		}
+		consistency, ok := scanConsistencies[strings.ToLower(operationConfig.ScanConsistency)]
+		if !ok {
+			return op, fmt.Errorf("couchbase.operations.%s: unknown scan consistency %q", name, operationConfig.ScanConsistency)
+		}
+		// Full text search is not bounded only
+		if name == "search" && consistency != gocb.QueryScanConsistencyNotBounded {
+			return op, fmt.Errorf("couchbase.operations.search: search supports notbounded scan consistency only")
+		}
+		op.scanConsistency = consistency
+	}
+	return op, nil
+}
+
+func writes(name string) bool {
+	return name == "create" || name == "update" || name == "bulk"
+}
@@ -0,0 +1,246 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/outbox"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	outboxKeyPrefix      = "outbox::"
+	outboxSequencePrefix = "outbox-sequence::"
+	outboxLockKey        = "lock::outbox-relay"
+)
+
+// lockAttempts bounds the retries of a lock that expires between the insert and the get
+const lockAttempts = 3
+
+// outboxDocument is an outbox record as stored next to the products. Sequence orders the records of a
+// stream, the events of one product of one tenant. RetryAt is NextAttemptAt in milliseconds, for queries.
+type outboxDocument struct {
+	outbox.Record
+	Stream   string `json:"stream"`
+	Sequence int64  `json:"sequence"`
+	RetryAt  int64  `json:"retryAt,omitempty"`
+}
+
+// outboxSequence is the last sequence of a stream
+type outboxSequence struct {
+	Value int64 `json:"value"`
+}
+
+type outboxLock struct {
+	Owner string `json:"owner"`
+}
+
+// insertOutbox adds the event to the outbox within the transaction that writes the product
+func (r *Repository) insertOutbox(tx *gocb.TransactionAttemptContext, event *domain.ProductEvent) error {
+	record, err := outbox.NewRecord(event.ID, event.Key(), string(event.Type), event.OccurredAt, event)
+	if err != nil {
+		return err
+	}
+	document := &outboxDocument{Record: record, Stream: event.Tenant + "::" + event.ProductID}
+	if document.Sequence, err = r.nextSequence(tx, document.Stream); err != nil {
+		return err
+	}
+	_, err = tx.Insert(r.collection(EntityOutbox), outboxKeyPrefix+event.ID, document)
+	return err
+}
+
+// nextSequence increments the counter of a stream within the transaction, so that the sequences follow the
+// order the transactions commit in. The counter outlives the product, a product created again continues it.
+func (r *Repository) nextSequence(tx *gocb.TransactionAttemptContext, stream string) (int64, error) {
+	collection := r.collection(EntityOutbox)
+	current, err := tx.Get(collection, outboxSequencePrefix+stream)
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		_, err = tx.Insert(collection, outboxSequencePrefix+stream, outboxSequence{Value: 1})
+		return 1, err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var sequence outboxSequence
+	if err := current.Content(&sequence); err != nil {
+		return 0, err
+	}
+	sequence.Value++
+	_, err = tx.Replace(current, sequence)
+	return sequence.Value, err
+}
+
+// transact runs fn in a transaction on the products collection of the tenant of ctx. The outbox records
+// written by fn are committed with the products, with the durability of op.
+func (r *Repository) transact(ctx context.Context, op operation, fn func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection) error) error {
+	collection, err := r.products(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = r.cluster.Transactions().Run(func(tx *gocb.TransactionAttemptContext) error {
+		return fn(tx, collection)
+	}, &gocb.TransactionOptions{
+		Timeout:         op.timeout,
+		DurabilityLevel: op.durability,
+	})
+	return mapError(err)
+}
+
+// OutboxStore reads the outbox records that the repository writes with the products
+type OutboxStore struct {
+	cluster      *gocb.Cluster
+	collection   *gocb.Collection
+	query        string
+	blockedQuery string
+	operation    operation
+}
+
+func NewOutboxStore(repository *Repository) *OutboxStore {
+	path := repository.path(EntityOutbox)
+	return &OutboxStore{
+		cluster:    repository.cluster,
+		collection: repository.collection(EntityOutbox),
+		// Match the partial indexes in .deploy/couchbase/outbox-index.n1ql
+		query: fmt.Sprintf("SELECT o.* FROM %s AS o WHERE META(o).id LIKE \"%s%%\" AND o.stream IS NOT MISSING AND o.`key` NOT IN $blocked ORDER BY o.stream, o.`sequence` LIMIT $limit",
+			path, outboxKeyPrefix),
+		blockedQuery: fmt.Sprintf("SELECT DISTINCT RAW o.`key` FROM %s AS o WHERE META(o).id LIKE \"%s%%\" AND o.retryAt > $now",
+			path, outboxKeyPrefix),
+		operation: repository.operations.outbox,
+	}
+}
+
+// Pending returns the records of the streams in order. The keys with a record waiting for a retry are
+// skipped, so that they do not fill the batch.
+func (s *OutboxStore) Pending(ctx context.Context, limit int) ([]outbox.Record, error) {
+	blocked, err := s.blocked(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.cluster.Query(s.query, &gocb.QueryOptions{
+		NamedParameters: map[string]interface{}{"limit": limit, "blocked": blocked},
+		ScanConsistency: s.operation.scanConsistency,
+		Readonly:        true,
+		Timeout:         s.operation.timeout,
+		Context:         ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	var records []outbox.Record
+	for result.Next() {
+		var document outboxDocument
+		if err := result.Row(&document); err != nil {
+			return nil, err
+		}
+		records = append(records, document.Record)
+	}
+
+	return records, result.Err()
+}
+
+// blocked returns the keys that have a record waiting for a retry
+func (s *OutboxStore) blocked(ctx context.Context) ([]string, error) {
+	result, err := s.cluster.Query(s.blockedQuery, &gocb.QueryOptions{
+		NamedParameters: map[string]interface{}{"now": time.Now().UnixMilli()},
+		ScanConsistency: s.operation.scanConsistency,
+		Readonly:        true,
+		Timeout:         s.operation.timeout,
+		Context:         ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	keys := []string{}
+	for result.Next() {
+		var key string
+		if err := result.Row(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, result.Err()
+}
+
+func (s *OutboxStore) Delete(ctx context.Context, id string) error {
+	_, err := s.collection.Remove(outboxKeyPrefix+id, &gocb.RemoveOptions{
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (s *OutboxStore) Retry(ctx context.Context, id string, attempts int, next time.Time) error {
+	_, err := s.collection.MutateIn(outboxKeyPrefix+id, []gocb.MutateInSpec{
+		gocb.ReplaceSpec("attempts", attempts, nil),
+		gocb.ReplaceSpec("nextAttemptAt", next, nil),
+		gocb.UpsertSpec("retryAt", next.UnixMilli(), nil),
+	}, &gocb.MutateInOptions{
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	return err
+}
+
+// Lock holds a document that expires after ttl. The owner renews it on every poll and every batch.
+func (s *OutboxStore) Lock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	for range lockAttempts {
+		_, err := s.collection.Insert(outboxLockKey, outboxLock{Owner: owner}, &gocb.InsertOptions{
+			Expiry:  ttl,
+			Timeout: 3 * time.Second,
+			Context: ctx,
+		})
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, gocb.ErrDocumentExists) {
+			return false, err
+		}
+
+		current, err := s.collection.Get(outboxLockKey, &gocb.GetOptions{
+			Timeout: 3 * time.Second,
+			Context: ctx,
+		})
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return s.renew(ctx, owner, ttl, current)
+	}
+	return false, nil
+}
+
+// renew extends the lock when owner holds it
+func (s *OutboxStore) renew(ctx context.Context, owner string, ttl time.Duration, current *gocb.GetResult) (bool, error) {
+	var lock outboxLock
+	if err := current.Content(&lock); err != nil {
+		return false, err
+	}
+	if lock.Owner != owner {
+		return false, nil
+	}
+
+	_, err := s.collection.Replace(outboxLockKey, lock, &gocb.ReplaceOptions{
+		Cas:     current.Cas(),
+		Expiry:  ttl,
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrCasMismatch) || errors.Is(err, gocb.ErrDocumentNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
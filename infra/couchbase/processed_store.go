@@ -0,0 +1,40 @@
+package couchbase
+
+import (
+	"context"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const processedKeyPrefix = "processed::"
+
+// ProcessedStore keeps the messages consumed by a group next to the products so that
+// every instance of the service skips the same redelivered messages
+type ProcessedStore struct {
+	collection *gocb.Collection
+}
+
+func NewProcessedStore(repository *Repository) *ProcessedStore {
+	return &ProcessedStore{collection: repository.collection(EntityProcessed)}
+}
+
+func (s *ProcessedStore) Processed(ctx context.Context, key string) (bool, error) {
+	result, err := s.collection.Exists(processedKeyPrefix+key, &gocb.ExistsOptions{
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.Exists(), nil
+}
+
+func (s *ProcessedStore) MarkProcessed(ctx context.Context, key string, lifetime time.Duration) error {
+	_, err := s.collection.Upsert(processedKeyPrefix+key, struct{}{}, &gocb.UpsertOptions{
+		Expiry:  lifetime,
+		Timeout: 3 * time.Second,
+		Context: ctx,
+	})
+	return err
+}
@@ -10,13 +10,20 @@ import (
 	gocbopentelemetry "github.com/couchbase/gocb-opentelemetry"
 	"github.com/couchbase/gocb/v2"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type Repository struct {
-	cluster *gocb.Cluster
-	bucket  *gocb.Bucket
-	tracer  *gocbopentelemetry.OpenTelemetryRequestTracer
+	cluster     *gocb.Cluster
+	bucket      *gocb.Bucket
+	tracer      *gocbopentelemetry.OpenTelemetryRequestTracer
+	searchIndex string
+	keyspaces   map[string]keyspace
+	// tenants maps a tenant to its scope
+	tenants    map[string]string
+	operations operations
+	connection *connection
 }
 
 func NewRepository(tp *sdktrace.TracerProvider, couchbaseConfig config.CouchbaseConfig) *Repository {
@@ -29,40 +36,69 @@ func NewRepository(tp *sdktrace.TracerProvider, couchbaseConfig config.Couchbase
 		},
 		Authenticator: gocb.PasswordAuthenticator{
 			Username: couchbaseConfig.Username,
-			Password: couchbaseConfig.Password,
+			Password: couchbaseConfig.Password.Value(),
 		},
 		Transcoder: gocb.NewJSONTranscoder(),
 		Tracer:     tracer,
 	})
 
+	// Connect only fails for an invalid config, the nodes are reached in the background
 	if err != nil {
 		zap.L().Fatal("Failed to connect to couchbase", zap.Error(err))
 	}
 
 	bucket := cluster.Bucket(couchbaseConfig.Bucket)
-	err = bucket.WaitUntilReady(20*time.Second, &gocb.WaitUntilReadyOptions{})
+
+	searchIndex := couchbaseConfig.SearchIndex
+	if searchIndex == "" {
+		searchIndex = "products-search"
+	}
+
+	keyspaces, err := parseKeyspaces(couchbaseConfig.Collections)
+	if err != nil {
+		zap.L().Fatal("Invalid couchbase collections", zap.Error(err))
+	}
+
+	operations, err := parseOperations(couchbaseConfig.Operations)
 	if err != nil {
-		zap.L().Fatal("Failed to connect to bucket", zap.Error(err))
+		zap.L().Fatal("Invalid couchbase operations", zap.Error(err))
 	}
 
-	return &Repository{
-		cluster: cluster,
-		bucket:  bucket,
-		tracer:  tracer,
+	repository := &Repository{
+		cluster:     cluster,
+		bucket:      bucket,
+		tracer:      tracer,
+		searchIndex: searchIndex,
+		keyspaces:   keyspaces,
+		tenants:     couchbaseConfig.Tenants,
+		operations:  operations,
+		connection:  newConnection(),
 	}
+	go repository.connect(couchbaseConfig.AutoCreate)
+
+	return repository
 }
 
 func (r *Repository) GetProduct(ctx context.Context, id string) (*domain.Product, error) {
 	ctx, span := r.tracer.Wrapped().Start(ctx, "GetProduct")
 	defer span.End()
-	data, err := r.bucket.DefaultCollection().Get(id, &gocb.GetOptions{
-		Timeout:    3 * time.Second,
+
+	collection, err := r.products(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := collection.Get(id, &gocb.GetOptions{
+		Timeout:    r.operations.get.timeout,
 		Context:    ctx,
 		ParentSpan: gocbopentelemetry.NewOpenTelemetryRequestSpan(ctx, span),
 	})
+	if errors.Is(err, gocb.ErrTimeout) && r.operations.get.replicaFallback {
+		zap.L().Warn("Timed out getting product, reading a replica", zap.Error(err), zap.String("id", id))
+		return r.getReplica(ctx, span, collection, id)
+	}
 	if err != nil {
 		if errors.Is(err, gocb.ErrDocumentNotFound) {
-			return nil, errors.New("product not found")
+			return nil, domain.ErrProductNotFound
 		}
 		zap.L().Error("Failed to get product", zap.Error(err))
 		return nil, err
@@ -78,24 +114,62 @@ func (r *Repository) GetProduct(ctx context.Context, id string) (*domain.Product
 
 }
 
-func (r *Repository) CreateProduct(ctx context.Context, product *domain.Product) error {
-	ctx, span := r.tracer.Wrapped().Start(ctx, "CreateProduct")
-	defer span.End()
-	_, err := r.bucket.DefaultCollection().Insert(product.ID, product, &gocb.InsertOptions{
-		Timeout:    3 * time.Second,
+// getReplica reads the product from whichever copy answers first, the product is stale when that is a replica
+func (r *Repository) getReplica(ctx context.Context, span trace.Span, collection *gocb.Collection, id string) (*domain.Product, error) {
+	data, err := collection.GetAnyReplica(id, &gocb.GetAnyReplicaOptions{
+		Timeout:    r.operations.get.timeout,
 		Context:    ctx,
 		ParentSpan: gocbopentelemetry.NewOpenTelemetryRequestSpan(ctx, span),
 	})
-	return err
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentUnretrievable) || errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, domain.ErrProductNotFound
+		}
+		zap.L().Error("Failed to get product from replicas", zap.Error(err))
+		return nil, err
+	}
+
+	var product domain.Product
+	if err := data.Content(&product); err != nil {
+		return nil, err
+	}
+	product.Stale = data.IsReplica()
+	return &product, nil
 }
 
-func (r *Repository) UpdateProduct(ctx context.Context, product *domain.Product) error {
+func (r *Repository) CreateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error {
+	ctx, span := r.tracer.Wrapped().Start(ctx, "CreateProduct")
+	defer span.End()
+	stampTenant(ctx, event)
+
+	return r.transact(ctx, r.operations.create, func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection) error {
+		if _, err := tx.Insert(collection, product.ID, product); err != nil {
+			return err
+		}
+		return r.insertOutbox(tx, event)
+	})
+}
+
+func (r *Repository) UpdateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error {
 	ctx, span := r.tracer.Wrapped().Start(ctx, "UpdateProduct")
 	defer span.End()
-	_, err := r.bucket.DefaultCollection().Replace(product.ID, product, &gocb.ReplaceOptions{
-		Timeout:    3 * time.Second,
-		Context:    ctx,
-		ParentSpan: gocbopentelemetry.NewOpenTelemetryRequestSpan(ctx, span),
+	stampTenant(ctx, event)
+
+	return r.transact(ctx, r.operations.update, func(tx *gocb.TransactionAttemptContext, collection *gocb.Collection) error {
+		current, err := tx.Get(collection, product.ID)
+		if err != nil {
+			return err
+		}
+
+		var before domain.Product
+		if err := current.Content(&before); err != nil {
+			return err
+		}
+		event.Before = &before
+
+		if _, err := tx.Replace(current, product); err != nil {
+			return err
+		}
+		return r.insertOutbox(tx, event)
 	})
-	return err
 }
@@ -0,0 +1,188 @@
+package couchbase
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"strings"
+	"time"
+
+	gocbopentelemetry "github.com/couchbase/gocb-opentelemetry"
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocb/v2/search"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const facetSize = 20
+
+// SearchProducts runs the query against the full text search index. Hits are loaded from the
+// bucket with a single batched get so the index does not have to store document fields.
+func (r *Repository) SearchProducts(ctx context.Context, query domain.ProductSearchQuery) (*domain.ProductSearchResult, error) {
+	ctx, span := r.tracer.Wrapped().Start(ctx, "SearchProducts")
+	defer span.End()
+
+	options := &gocb.SearchOptions{
+		Limit:      uint32(query.Limit),
+		Skip:       uint32(query.Offset),
+		Sort:       searchSort(query.Sort),
+		Timeout:    r.operations.search.timeout,
+		Context:    ctx,
+		ParentSpan: gocbopentelemetry.NewOpenTelemetryRequestSpan(ctx, span),
+	}
+	if len(query.Facets) > 0 {
+		options.Facets = make(map[string]search.Facet, len(query.Facets))
+		for _, field := range query.Facets {
+			options.Facets[field] = search.NewTermFacet(field, facetSize)
+		}
+	}
+	if query.Highlight && query.Text != "" {
+		options.Highlight = &gocb.SearchHighlightOptions{Style: gocb.HTMLHighlightStyle, Fields: []string{"name"}}
+	}
+
+	space, err := r.productKeyspace(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Products outside the default collection are searched with the index of their scope
+	var result *gocb.SearchResult
+	if space.isDefault() {
+		result, err = r.cluster.SearchQuery(r.searchIndex, searchQuery(query), options)
+	} else {
+		result, err = r.bucket.Scope(space.scope).Search(r.searchIndex, gocb.SearchRequest{SearchQuery: searchQuery(query)}, options)
+	}
+	if err != nil {
+		zap.L().Error("Failed to search products", zap.Error(err))
+		return nil, err
+	}
+
+	var rows []gocb.SearchRow
+	for result.Next() {
+		rows = append(rows, result.Row())
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	metadata, err := result.MetaData()
+	if err != nil {
+		return nil, err
+	}
+	facets, err := result.Facets()
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := r.loadHits(ctx, span, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	searchResult := &domain.ProductSearchResult{
+		Hits:  hits,
+		Total: int(metadata.Metrics.TotalRows),
+	}
+	if len(facets) > 0 {
+		searchResult.Facets = make(map[string][]domain.FacetCount, len(facets))
+		for name, facet := range facets {
+			counts := make([]domain.FacetCount, 0, len(facet.Terms))
+			for _, term := range facet.Terms {
+				counts = append(counts, domain.FacetCount{Value: term.Term, Count: term.Count})
+			}
+			searchResult.Facets[name] = counts
+		}
+	}
+
+	return searchResult, nil
+}
+
+// loadHits fetches the documents of the rows, skipping the ones deleted since they were indexed
+func (r *Repository) loadHits(ctx context.Context, span trace.Span, rows []gocb.SearchRow) ([]domain.ProductSearchHit, error) {
+	ops := make([]gocb.BulkOp, len(rows))
+	for i, row := range rows {
+		ops[i] = &gocb.GetOp{ID: row.ID}
+	}
+
+	if len(ops) > 0 {
+		collection, err := r.products(ctx)
+		if err != nil {
+			return nil, err
+		}
+		err = collection.Do(ops, &gocb.BulkOpOptions{
+			Timeout:    3 * time.Second,
+			Context:    ctx,
+			ParentSpan: gocbopentelemetry.NewOpenTelemetryRequestSpan(ctx, span),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hits := make([]domain.ProductSearchHit, 0, len(rows))
+	for i, row := range rows {
+		op := ops[i].(*gocb.GetOp)
+		if op.Err != nil {
+			if errors.Is(op.Err, gocb.ErrDocumentNotFound) {
+				continue
+			}
+			return nil, op.Err
+		}
+
+		hit := domain.ProductSearchHit{Score: row.Score, Highlights: row.Fragments}
+		if err := op.Result.Content(&hit.Product); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
+
+// wildcardEscaper keeps the wildcard characters of the search text literal
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+func searchQuery(query domain.ProductSearchQuery) search.Query {
+	var text search.Query = search.NewMatchAllQuery()
+	if query.Text != "" {
+		// Name fragments are matched with a wildcard, whole words also match categories and tags
+		text = search.NewDisjunctionQuery(
+			search.NewMatchQuery(query.Text).Field("name").Fuzziness(1).Boost(2),
+			search.NewWildcardQuery("*"+wildcardEscaper.Replace(query.Text)+"*").Field("name"),
+			search.NewMatchQuery(query.Text).Field("category"),
+			search.NewMatchQuery(query.Text).Field("tags"),
+		)
+	}
+
+	conjunction := search.NewConjunctionQuery(text)
+	if len(query.Categories) > 0 {
+		conjunction.And(termsQuery("category", query.Categories))
+	}
+	if len(query.Tags) > 0 {
+		conjunction.And(termsQuery("tags", query.Tags))
+	}
+	return conjunction
+}
+
+func termsQuery(field string, terms []string) search.Query {
+	disjunction := search.NewDisjunctionQuery()
+	for _, term := range terms {
+		disjunction.Or(search.NewTermQuery(term).Field(field))
+	}
+	return disjunction
+}
+
+func searchSort(fields []domain.SortField) []search.Sort {
+	sort := make([]search.Sort, 0, len(fields))
+	for _, field := range fields {
+		switch field.Field {
+		case "score":
+			sort = append(sort, search.NewSearchSortScore().Descending(field.Descending))
+		case "id":
+			sort = append(sort, search.NewSearchSortID().Descending(field.Descending))
+		default:
+			sort = append(sort, search.NewSearchSortField(field.Field).Descending(field.Descending))
+		}
+	}
+	return sort
+}
@@ -0,0 +1,18 @@
+package couchbase
+
+import (
+	"encoding/json"
+	"golang-fiber-poc/domain"
+	"strings"
+	"testing"
+)
+
+func TestSearchQueryEscapesWildcards(t *testing.T) {
+	data, err := json.Marshal(searchQuery(domain.ProductSearchQuery{Text: `a*b?c\`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"wildcard":"*a\\*b\\?c\\\\*"`; !strings.Contains(string(data), want) {
+		t.Fatalf("query %s does not contain %s", data, want)
+	}
+}
@@ -0,0 +1,127 @@
+package couchbase
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/couchbase/gocb/v2"
+	"go.uber.org/zap"
+)
+
+// setup creates the scopes and collections of the entities and the tenants, and the indexes the
+// repository relies on. Existing ones are left as they are.
+func (r *Repository) setup() error {
+	spaces := make([]keyspace, 0, len(r.keyspaces)+len(r.tenants))
+	for _, entity := range entities {
+		spaces = append(spaces, r.keyspaces[entity])
+	}
+	productSpaces := []keyspace{r.keyspaces[EntityProducts]}
+	for _, scope := range r.tenants {
+		space := keyspace{scope: scope, collection: r.keyspaces[EntityProducts].collection}
+		spaces = append(spaces, space)
+		productSpaces = append(productSpaces, space)
+	}
+
+	manager := r.bucket.CollectionsV2()
+	created := make(map[keyspace]bool)
+	for _, space := range spaces {
+		if created[space] || space.isDefault() {
+			continue
+		}
+		created[space] = true
+
+		if space.scope != "_default" {
+			if err := manager.CreateScope(space.scope, nil); err != nil && !errors.Is(err, gocb.ErrScopeExists) {
+				return fmt.Errorf("create scope %s: %w", space.scope, err)
+			}
+		}
+		if err := manager.CreateCollection(space.scope, space.collection, nil, nil); err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+			return fmt.Errorf("create collection %s: %w", space, err)
+		}
+		zap.L().Info("Created couchbase collection", zap.String("keyspace", space.String()))
+	}
+
+	// The same indexes as .deploy/couchbase/outbox-index.n1ql
+	for name, keys := range map[string]string{"products-outbox-streams": "`stream`, `sequence`, `key`", "products-outbox-retries": "`retryAt`, `key`"} {
+		_, err := r.cluster.Query(fmt.Sprintf("CREATE INDEX `%s` IF NOT EXISTS ON %s(%s) WHERE META().id LIKE \"%s%%\"",
+			name, r.path(EntityOutbox), keys, outboxKeyPrefix), nil)
+		if err != nil {
+			return fmt.Errorf("create outbox index %s: %w", name, err)
+		}
+	}
+
+	created = make(map[keyspace]bool)
+	for _, space := range productSpaces {
+		if created[space] {
+			continue
+		}
+		created[space] = true
+		if err := r.createSearchIndex(space); err != nil {
+			return fmt.Errorf("create search index of %s: %w", space, err)
+		}
+	}
+	return nil
+}
+
+// createSearchIndex creates the product search index of a keyspace when it is missing. Products in the
+// default collection have a cluster index, like .deploy/couchbase/products-search-index.json, the others
+// have an index in their scope.
+func (r *Repository) createSearchIndex(space keyspace) error {
+	var getIndex func() error
+	var upsertIndex func(gocb.SearchIndex) error
+	if space.isDefault() {
+		manager := r.cluster.SearchIndexes()
+		getIndex = func() error { _, err := manager.GetIndex(r.searchIndex, nil); return err }
+		upsertIndex = func(index gocb.SearchIndex) error { return manager.UpsertIndex(index, nil) }
+	} else {
+		manager := r.bucket.Scope(space.scope).SearchIndexes()
+		getIndex = func() error { _, err := manager.GetIndex(r.searchIndex, nil); return err }
+		upsertIndex = func(index gocb.SearchIndex) error { return manager.UpsertIndex(index, nil) }
+	}
+
+	err := getIndex()
+	if err == nil || !errors.Is(err, gocb.ErrIndexNotFound) {
+		return err
+	}
+	return upsertIndex(r.searchIndexDefinition(space))
+}
+
+func (r *Repository) searchIndexDefinition(space keyspace) gocb.SearchIndex {
+	field := func(name, analyzer string, store bool) map[string]interface{} {
+		options := map[string]interface{}{"name": name, "type": "text", "analyzer": analyzer, "index": true, "docvalues": true}
+		if store {
+			options["store"] = true
+			options["include_term_vectors"] = true
+		}
+		return map[string]interface{}{"enabled": true, "fields": []interface{}{options}}
+	}
+	productMapping := map[string]interface{}{
+		"enabled": true,
+		"dynamic": false,
+		"properties": map[string]interface{}{
+			"name":     field("name", "standard", true),
+			"category": field("category", "keyword", false),
+			"tags":     field("tags", "keyword", false),
+		},
+	}
+
+	mapping := map[string]interface{}{"default_analyzer": "standard"}
+	docConfig := map[string]interface{}{"type_field": "type"}
+	if space.isDefault() {
+		docConfig["mode"] = "type_field"
+		mapping["default_mapping"] = productMapping
+	} else {
+		docConfig["mode"] = "scope.collection.type_field"
+		mapping["default_mapping"] = map[string]interface{}{"enabled": false}
+		mapping["types"] = map[string]interface{}{space.String(): productMapping}
+	}
+
+	return gocb.SearchIndex{
+		Name:       r.searchIndex,
+		Type:       "fulltext-index",
+		SourceType: "gocbcore",
+		SourceName: r.bucket.Name(),
+		PlanParams: map[string]interface{}{"indexPartitions": 1},
+		Params:     map[string]interface{}{"doc_config": docConfig, "mapping": mapping},
+	}
+}
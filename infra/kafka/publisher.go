@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/config"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Publisher writes messages to Kafka. Messages are partitioned by key, which keeps the order per key.
+type Publisher struct {
+	writer *kafkago.Writer
+}
+
+func NewPublisher(kafkaConfig config.KafkaConfig) *Publisher {
+	return &Publisher{writer: &kafkago.Writer{
+		Addr:         kafkago.TCP(kafkaConfig.Brokers...),
+		Balancer:     &kafkago.Hash{},
+		RequiredAcks: kafkago.RequireAll,
+		// The outbox relay publishes one message at a time and waits for it
+		BatchSize: 1,
+	}}
+}
+
+func (p *Publisher) Publish(ctx context.Context, message broker.Message) error {
+	headers := make([]kafkago.Header, 0, len(message.Headers)+1)
+	headers = append(headers, kafkago.Header{Key: "id", Value: []byte(message.ID)})
+	for key, value := range message.Headers {
+		headers = append(headers, kafkago.Header{Key: key, Value: []byte(value)})
+	}
+
+	return p.writer.WriteMessages(ctx, kafkago.Message{
+		Topic:   message.Topic,
+		Key:     []byte(message.Key),
+		Value:   message.Value,
+		Headers: headers,
+	})
+}
+
+func (p *Publisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/config"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Subscriber reads messages with a Kafka consumer group. Offsets are committed after each message is handled.
+type Subscriber struct {
+	brokers []string
+}
+
+func NewSubscriber(kafkaConfig config.KafkaConfig) *Subscriber {
+	return &Subscriber{brokers: kafkaConfig.Brokers}
+}
+
+func (s *Subscriber) Subscribe(ctx context.Context, topic, group string, handle broker.Handler) error {
+	for {
+		until, err := s.consume(ctx, topic, group, handle)
+		if err != nil || until.IsZero() {
+			return err
+		}
+		// The reader was closed without committing the paused message, the group reads it again from the
+		// committed offset once it is due
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Until(until)):
+		}
+	}
+}
+
+// consume reads the topic until ctx is done, handle fails or pauses a message, in which case it returns
+// when the message is due
+func (s *Subscriber) consume(ctx context.Context, topic, group string, handle broker.Handler) (time.Time, error) {
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers: s.brokers,
+		GroupID: group,
+		Topic:   topic,
+	})
+	defer reader.Close()
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return time.Time{}, nil
+			}
+			return time.Time{}, err
+		}
+
+		message := broker.Message{
+			ID:      fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset),
+			Topic:   msg.Topic,
+			Key:     string(msg.Key),
+			Headers: make(map[string]string, len(msg.Headers)),
+			Value:   msg.Value,
+		}
+		for _, header := range msg.Headers {
+			if header.Key == "id" {
+				message.ID = string(header.Value)
+				continue
+			}
+			message.Headers[header.Key] = string(header.Value)
+		}
+
+		if err := handle(ctx, message); err != nil {
+			if until, paused := broker.PausedUntil(err); paused {
+				return until, nil
+			}
+			return time.Time{}, err
+		}
+		// The handled message is committed even when ctx is done, or it would be handled again
+		if err := reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
+			return time.Time{}, err
+		}
+	}
+}
+
+func (s *Subscriber) Close() error {
+	return nil
+}
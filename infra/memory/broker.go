@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/broker"
+	"sync"
+	"time"
+)
+
+// brokerRetention is the number of messages a topic keeps for groups that have not received them yet
+const brokerRetention = 10000
+
+// Broker keeps published messages in process memory. It stands in for Kafka or NATS in tests and local runs.
+// Every group has one offset per topic, so a group should have a single subscriber per topic.
+// A message is dropped once every group subscribed to its topic received it, and the oldest messages are
+// dropped when a topic holds more than brokerRetention of them.
+type Broker struct {
+	mu     sync.Mutex
+	topics map[string]*topicLog
+	// offsets are the positions of the groups, counted from the first message ever published to the topic
+	offsets map[string]int
+	// published is closed and replaced on every publish, to wake up the subscribers
+	published chan struct{}
+}
+
+type topicLog struct {
+	// dropped is the number of messages removed from the front of messages
+	dropped  int
+	messages []broker.Message
+	groups   map[string]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		topics:    make(map[string]*topicLog),
+		offsets:   make(map[string]int),
+		published: make(chan struct{}),
+	}
+}
+
+// topic returns the log of topic, it must be called with mu held
+func (b *Broker) topic(name string) *topicLog {
+	log, ok := b.topics[name]
+	if !ok {
+		log = &topicLog{groups: make(map[string]bool)}
+		b.topics[name] = log
+	}
+	return log
+}
+
+func (b *Broker) Publish(_ context.Context, message broker.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	log := b.topic(message.Topic)
+	log.messages = append(log.messages, message)
+	if excess := len(log.messages) - brokerRetention; excess > 0 {
+		log.drop(excess)
+	}
+	close(b.published)
+	b.published = make(chan struct{})
+	return nil
+}
+
+// Messages returns the messages of topic that are still kept, in order
+func (b *Broker) Messages(topic string) []broker.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]broker.Message(nil), b.topic(topic).messages...)
+}
+
+func (b *Broker) Subscribe(ctx context.Context, topic, group string, handle broker.Handler) error {
+	offsetKey := group + "/" + topic
+	b.mu.Lock()
+	b.topic(topic).groups[offsetKey] = true
+	b.mu.Unlock()
+
+	for ctx.Err() == nil {
+		b.mu.Lock()
+		log := b.topic(topic)
+		// The messages the group missed were dropped by the retention
+		offset := max(b.offsets[offsetKey], log.dropped)
+		if offset == log.dropped+len(log.messages) {
+			published := b.published
+			b.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+			case <-published:
+			}
+			continue
+		}
+		message := log.messages[offset-log.dropped]
+		b.mu.Unlock()
+
+		if err := handle(ctx, message); err != nil {
+			until, paused := broker.PausedUntil(err)
+			if !paused {
+				return err
+			}
+			// The log keeps its order, the group reads the message again once it is due
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Until(until)):
+			}
+			continue
+		}
+
+		b.mu.Lock()
+		b.offsets[offsetKey] = offset + 1
+		b.trim(log)
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// trim drops the messages that every group subscribed to the topic received, it must be called with mu held
+func (b *Broker) trim(log *topicLog) {
+	delivered := -1
+	for offsetKey := range log.groups {
+		if offset := b.offsets[offsetKey]; delivered < 0 || offset < delivered {
+			delivered = offset
+		}
+	}
+	if delivered > log.dropped {
+		log.drop(delivered - log.dropped)
+	}
+}
+
+func (l *topicLog) drop(count int) {
+	count = min(count, len(l.messages))
+	clear(l.messages[:count])
+	l.messages = l.messages[count:]
+	l.dropped += count
+}
+
+func (b *Broker) Close() error {
+	return nil
+}
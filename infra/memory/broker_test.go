@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/broker"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBrokerDropsDeliveredMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewBroker()
+
+	received := make(chan string, 3)
+	go b.Subscribe(ctx, "products", "group", func(_ context.Context, message broker.Message) error {
+		received <- message.ID
+		return nil
+	})
+	for i := range 3 {
+		if err := b.Publish(ctx, broker.Message{ID: strconv.Itoa(i), Topic: "products"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 3 {
+		if id := <-received; id != strconv.Itoa(i) {
+			t.Fatalf("received %s, want %d", id, i)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(b.Messages("products")) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d delivered messages kept", len(b.Messages("products")))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBrokerBoundsUndeliveredMessages(t *testing.T) {
+	b := NewBroker()
+	for i := range brokerRetention + 10 {
+		if err := b.Publish(context.Background(), broker.Message{ID: strconv.Itoa(i), Topic: "products"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := b.Messages("products")
+	if len(messages) != brokerRetention || messages[0].ID != "10" {
+		t.Fatalf("kept %d messages from %s, want %d from 10", len(messages), messages[0].ID, brokerRetention)
+	}
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/changefeed"
+	"hash/crc32"
+	"sync"
+)
+
+// ChangeSource stands in for the Couchbase DCP feed in tests and local runs. Changes are added with
+// Mutate and Delete, and hashed to partitions like Couchbase does with its keys.
+type ChangeSource struct {
+	mu         sync.Mutex
+	partitions int
+	changes    []changefeed.Change
+	revisions  map[string]uint64
+	seqNos     []uint64
+	// added is closed and replaced on every change, to wake up the streams
+	added chan struct{}
+}
+
+func NewChangeSource(partitions int) *ChangeSource {
+	return &ChangeSource{
+		partitions: partitions,
+		revisions:  make(map[string]uint64),
+		seqNos:     make([]uint64, partitions),
+		added:      make(chan struct{}),
+	}
+}
+
+func (s *ChangeSource) Mutate(key string, value []byte) {
+	s.add(changefeed.Change{Key: key, Value: value})
+}
+
+func (s *ChangeSource) Delete(key string) {
+	s.add(changefeed.Change{Key: key, Deleted: true})
+}
+
+func (s *ChangeSource) add(change changefeed.Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change.Partition = uint16(crc32.ChecksumIEEE([]byte(change.Key)) % uint32(s.partitions))
+	s.seqNos[change.Partition]++
+	s.revisions[change.Key]++
+	seqNo := s.seqNos[change.Partition]
+	change.Revision = s.revisions[change.Key]
+	change.Cas = uint64(len(s.changes) + 1)
+	change.Checkpoint = changefeed.Checkpoint{SeqNo: seqNo, SnapshotStart: seqNo, SnapshotEnd: seqNo}
+	s.changes = append(s.changes, change)
+
+	close(s.added)
+	s.added = make(chan struct{})
+}
+
+// Stream sends the changes after the checkpoints, from the beginning for partitions without one
+func (s *ChangeSource) Stream(ctx context.Context, from changefeed.Checkpoints, handle func(changefeed.Change)) error {
+	next := 0
+	for {
+		s.mu.Lock()
+		pending := s.changes[next:]
+		next = len(s.changes)
+		added := s.added
+		s.mu.Unlock()
+
+		for _, change := range pending {
+			if change.Checkpoint.SeqNo > from[change.Partition].SeqNo {
+				handle(change)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-added:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/outbox"
+	"slices"
+	"time"
+)
+
+// OutboxStore reads the outbox records that the repository writes with the products
+type OutboxStore struct {
+	repository *Repository
+}
+
+func NewOutboxStore(repository *Repository) *OutboxStore {
+	return &OutboxStore{repository: repository}
+}
+
+// Pending returns the records in the order they were written, skipping the keys that wait for a retry
+func (s *OutboxStore) Pending(_ context.Context, limit int) ([]outbox.Record, error) {
+	s.repository.mu.RLock()
+	defer s.repository.mu.RUnlock()
+
+	now := time.Now()
+	blocked := make(map[string]bool)
+	for _, record := range s.repository.outbox {
+		if record.NextAttemptAt.After(now) {
+			blocked[record.Key] = true
+		}
+	}
+
+	var records []outbox.Record
+	for _, record := range s.repository.outbox {
+		if len(records) == limit {
+			break
+		}
+		if !blocked[record.Key] {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (s *OutboxStore) Delete(_ context.Context, id string) error {
+	s.repository.mu.Lock()
+	defer s.repository.mu.Unlock()
+
+	s.repository.outbox = slices.DeleteFunc(s.repository.outbox, func(record outbox.Record) bool { return record.ID == id })
+	return nil
+}
+
+func (s *OutboxStore) Retry(_ context.Context, id string, attempts int, next time.Time) error {
+	s.repository.mu.Lock()
+	defer s.repository.mu.Unlock()
+
+	for i := range s.repository.outbox {
+		if s.repository.outbox[i].ID == id {
+			s.repository.outbox[i].Attempts = attempts
+			s.repository.outbox[i].NextAttemptAt = next
+		}
+	}
+	return nil
+}
+
+// Lock always succeeds, the outbox belongs to this process
+func (s *OutboxStore) Lock(context.Context, string, time.Duration) (bool, error) {
+	return true, nil
+}
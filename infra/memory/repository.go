@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/pkg/outbox"
+	"golang-fiber-poc/pkg/tenant"
+	"slices"
+	"sync"
+)
+
+// Repository keeps products in process memory. It backs tests and local runs without Couchbase.
+// It is also the outbox store of the events it writes with the products.
+// The products of every tenant are kept apart, requests without a tenant see the shared products.
+type Repository struct {
+	mu       sync.RWMutex
+	products map[string]map[string]domain.Product
+	outbox   []outbox.Record
+}
+
+func NewRepository() *Repository {
+	return &Repository{products: make(map[string]map[string]domain.Product)}
+}
+
+// tenantProducts returns the products of the tenant of ctx, it must be called with mu held
+func (r *Repository) tenantProducts(ctx context.Context) map[string]domain.Product {
+	tenantID, _ := tenant.FromContext(ctx)
+	return r.products[tenantID]
+}
+
+// write stores a product of the tenant of ctx with its event, it must be called with mu held
+func (r *Repository) write(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error {
+	tenantID, _ := tenant.FromContext(ctx)
+	event.Tenant = tenantID
+
+	record, err := outbox.NewRecord(event.ID, event.Key(), string(event.Type), event.OccurredAt, event)
+	if err != nil {
+		return err
+	}
+
+	if r.products[tenantID] == nil {
+		r.products[tenantID] = make(map[string]domain.Product)
+	}
+	r.products[tenantID][product.ID] = *clone(*product)
+	r.outbox = append(r.outbox, record)
+	return nil
+}
+
+func (r *Repository) GetProduct(ctx context.Context, id string) (*domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.tenantProducts(ctx)[id]
+	if !ok {
+		return nil, domain.ErrProductNotFound
+	}
+
+	return clone(product), nil
+}
+
+func (r *Repository) GetProducts(ctx context.Context, ids []string) ([]*domain.Product, []error) {
+	products := make([]*domain.Product, len(ids))
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		products[i], errs[i] = r.GetProduct(ctx, id)
+	}
+	return products, errs
+}
+
+func (r *Repository) CreateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenantProducts(ctx)[product.ID]; ok {
+		return domain.ErrProductAlreadyExists
+	}
+
+	return r.write(ctx, product, event)
+}
+
+func (r *Repository) UpdateProduct(ctx context.Context, product *domain.Product, event *domain.ProductEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.tenantProducts(ctx)[product.ID]
+	if !ok {
+		return domain.ErrProductNotFound
+	}
+	event.Before = clone(before)
+
+	return r.write(ctx, product, event)
+}
+
+func clone(product domain.Product) *domain.Product {
+	product.Tags = slices.Clone(product.Tags)
+	return &product
+}
@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"golang-fiber-poc/domain"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// SearchProducts scans every product of the tenant. Text matches name fragments, categories and tags
+// case-insensitively.
+func (r *Repository) SearchProducts(ctx context.Context, query domain.ProductSearchQuery) (*domain.ProductSearchResult, error) {
+	r.mu.RLock()
+	hits := make([]domain.ProductSearchHit, 0)
+	for _, product := range r.tenantProducts(ctx) {
+		if !matchesFilters(product, query) {
+			continue
+		}
+		score := score(product, query.Text)
+		if query.Text != "" && score == 0 {
+			continue
+		}
+		hits = append(hits, domain.ProductSearchHit{Product: *clone(product), Score: score})
+	}
+	r.mu.RUnlock()
+
+	result := &domain.ProductSearchResult{
+		Total:  len(hits),
+		Facets: facets(hits, query.Facets),
+	}
+
+	slices.SortFunc(hits, func(a, b domain.ProductSearchHit) int {
+		return compareHits(a, b, query.Sort)
+	})
+
+	offset := min(query.Offset, len(hits))
+	result.Hits = hits[offset:min(offset+query.Limit, len(hits))]
+
+	if query.Highlight && query.Text != "" {
+		for i := range result.Hits {
+			result.Hits[i].Highlights = highlight(result.Hits[i].Product, query.Text)
+		}
+	}
+
+	return result, nil
+}
+
+func matchesFilters(product domain.Product, query domain.ProductSearchQuery) bool {
+	if len(query.Categories) > 0 && !slices.Contains(query.Categories, product.Category) {
+		return false
+	}
+	if len(query.Tags) > 0 && !slices.ContainsFunc(query.Tags, func(tag string) bool { return slices.Contains(product.Tags, tag) }) {
+		return false
+	}
+	return true
+}
+
+// score counts the query terms found in the product, name matches weigh more than category and tag matches
+func score(product domain.Product, text string) float64 {
+	var score float64
+	for _, term := range strings.Fields(text) {
+		if containsFold(product.Name, term) {
+			score += 2
+		}
+		if strings.EqualFold(product.Category, term) {
+			score++
+		}
+		if slices.ContainsFunc(product.Tags, func(tag string) bool { return strings.EqualFold(tag, term) }) {
+			score++
+		}
+	}
+	return score
+}
+
+func facets(hits []domain.ProductSearchHit, fields []string) map[string][]domain.FacetCount {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	result := make(map[string][]domain.FacetCount, len(fields))
+	for _, field := range fields {
+		counts := make(map[string]int)
+		for _, hit := range hits {
+			switch field {
+			case "category":
+				if hit.Product.Category != "" {
+					counts[hit.Product.Category]++
+				}
+			case "tags":
+				for _, tag := range hit.Product.Tags {
+					counts[tag]++
+				}
+			}
+		}
+
+		facet := make([]domain.FacetCount, 0, len(counts))
+		for value, count := range counts {
+			facet = append(facet, domain.FacetCount{Value: value, Count: count})
+		}
+		slices.SortFunc(facet, func(a, b domain.FacetCount) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Value, b.Value))
+		})
+		result[field] = facet
+	}
+	return result
+}
+
+func compareHits(a, b domain.ProductSearchHit, sort []domain.SortField) int {
+	for _, field := range sort {
+		var c int
+		switch field.Field {
+		case "score":
+			c = cmp.Compare(a.Score, b.Score)
+		case "id":
+			c = cmp.Compare(a.Product.ID, b.Product.ID)
+		case "name":
+			c = cmp.Compare(a.Product.Name, b.Product.Name)
+		case "category":
+			c = cmp.Compare(a.Product.Category, b.Product.Category)
+		}
+		if field.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	// Keep pages stable when the requested fields tie
+	return cmp.Compare(a.Product.ID, b.Product.ID)
+}
+
+// highlight wraps the matches of the terms in the name in <mark>, the name is escaped as HTML around them
+func highlight(product domain.Product, text string) map[string][]string {
+	name := product.Name
+	marked := make([]bool, len(name))
+	for _, term := range strings.Fields(text) {
+		for i := 0; i < len(name); {
+			if n := foldPrefix(name[i:], term); n > 0 {
+				for k := i; k < i+n; k++ {
+					marked[k] = true
+				}
+				i += n
+				continue
+			}
+			_, size := utf8.DecodeRuneInString(name[i:])
+			i += size
+		}
+	}
+	if !slices.Contains(marked, true) {
+		return nil
+	}
+
+	var highlighted strings.Builder
+	for start := 0; start < len(name); {
+		end := start
+		for end < len(name) && marked[end] == marked[start] {
+			end++
+		}
+		if marked[start] {
+			highlighted.WriteString("<mark>" + html.EscapeString(name[start:end]) + "</mark>")
+		} else {
+			highlighted.WriteString(html.EscapeString(name[start:end]))
+		}
+		start = end
+	}
+	return map[string][]string{"name": {highlighted.String()}}
+}
+
+// containsFold tells whether s contains term, ignoring case
+func containsFold(s, term string) bool {
+	for i := 0; i < len(s); {
+		if foldPrefix(s[i:], term) > 0 {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return false
+}
+
+// foldPrefix returns the length in bytes of the prefix of s that equals term ignoring case, or 0. The
+// lengths of the prefix and of term differ when case folding changes the size of a rune, e.g. for the
+// Kelvin sign.
+func foldPrefix(s, term string) int {
+	n := 0
+	for term != "" {
+		if n == len(s) {
+			return 0
+		}
+		r, size := utf8.DecodeRuneInString(s[n:])
+		t, termSize := utf8.DecodeRuneInString(term)
+		if r != t && !strings.EqualFold(string(r), string(t)) {
+			return 0
+		}
+		n += size
+		term = term[termSize:]
+	}
+	return n
+}
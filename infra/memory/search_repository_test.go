@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"golang-fiber-poc/domain"
+	"strings"
+	"testing"
+)
+
+func TestSearchProducts(t *testing.T) {
+	ctx := context.Background()
+	repository := NewRepository()
+	for _, product := range []domain.Product{
+		{ID: "1", Name: "Red chair", Category: "furniture", Tags: []string{"red"}},
+		{ID: "2", Name: "Blue chair", Category: "furniture", Tags: []string{"blue"}},
+		{ID: "3", Name: "Red lamp", Category: "lighting", Tags: []string{"red"}},
+	} {
+		if err := repository.CreateProduct(ctx, &product, domain.NewProductEvent(domain.ProductCreated, product.ID, nil, &product)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := repository.SearchProducts(ctx, domain.ProductSearchQuery{
+		Text:   "chair",
+		Tags:   []string{"red", "blue"},
+		Facets: []string{"tags"},
+		Sort:   []domain.SortField{{Field: "name"}},
+		Limit:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 2 {
+		t.Fatalf("total %d, want 2", result.Total)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].Product.ID != "2" {
+		t.Fatalf("hits %+v, want the blue chair", result.Hits)
+	}
+	if tags := result.Facets["tags"]; len(tags) != 2 {
+		t.Fatalf("tag facets %+v, want blue and red", tags)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "Red chair", text: "CHAIR red", want: "<mark>Red</mark> <mark>chair</mark>"},
+		{name: "Kelvin lamp", text: "kelvin", want: "<mark>Kelvin</mark> lamp"},
+		{name: "KK chair", text: "chair", want: "KK <mark>chair</mark>"},
+		{name: "<b>chair</b> & co", text: "chair", want: "&lt;b&gt;<mark>chair</mark>&lt;/b&gt; &amp; co"},
+		{name: "Red chair", text: "lamp", want: ""},
+	}
+	for _, tt := range tests {
+		highlights := highlight(domain.Product{Name: tt.name}, tt.text)
+		if got := strings.Join(highlights["name"], ""); got != tt.want {
+			t.Errorf("highlight of %q in %q is %q, want %q", tt.text, tt.name, got, tt.want)
+		}
+	}
+}
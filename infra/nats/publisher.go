@@ -0,0 +1,47 @@
+package nats
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/config"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Publisher publishes messages to a JetStream stream that captures the topic subjects.
+// The message id is used as Nats-Msg-Id, so JetStream drops duplicates of a message.
+type Publisher struct {
+	conn      *nats.Conn
+	jetStream nats.JetStreamContext
+}
+
+func NewPublisher(natsConfig config.NATSConfig) (*Publisher, error) {
+	conn, err := nats.Connect(natsConfig.URL, nats.Name("golang-fiber-poc"))
+	if err != nil {
+		return nil, err
+	}
+
+	jetStream, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Publisher{conn: conn, jetStream: jetStream}, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, message broker.Message) error {
+	msg := nats.NewMsg(message.Topic)
+	msg.Data = message.Value
+	msg.Header.Set("key", message.Key)
+	for key, value := range message.Headers {
+		msg.Header.Set(key, value)
+	}
+
+	_, err := p.jetStream.PublishMsg(msg, nats.MsgId(message.ID), nats.Context(ctx))
+	return err
+}
+
+func (p *Publisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,98 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Subscriber pulls messages with a durable JetStream consumer per group and topic. Messages are acknowledged
+// after they are handled.
+type Subscriber struct {
+	conn      *nats.Conn
+	jetStream nats.JetStreamContext
+}
+
+func NewSubscriber(natsConfig config.NATSConfig) (*Subscriber, error) {
+	conn, err := nats.Connect(natsConfig.URL, nats.Name("golang-fiber-poc"))
+	if err != nil {
+		return nil, err
+	}
+
+	jetStream, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Subscriber{conn: conn, jetStream: jetStream}, nil
+}
+
+func (s *Subscriber) Subscribe(ctx context.Context, topic, group string, handle broker.Handler) error {
+	// Durable names cannot contain dots. The subscription is not unsubscribed, that would delete the durable consumer.
+	durable := strings.ReplaceAll(group+"_"+topic, ".", "_")
+	subscription, err := s.jetStream.PullSubscribe(topic, durable, nats.ManualAck())
+	if err != nil {
+		return err
+	}
+
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		msgs, err := subscription.Fetch(1, nats.Context(fetchCtx))
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			message := broker.Message{
+				ID:      msg.Header.Get(nats.MsgIdHdr),
+				Topic:   msg.Subject,
+				Key:     msg.Header.Get("key"),
+				Headers: make(map[string]string, len(msg.Header)),
+				Value:   msg.Data,
+			}
+			for key := range msg.Header {
+				if key != nats.MsgIdHdr && key != "key" {
+					message.Headers[key] = msg.Header.Get(key)
+				}
+			}
+			if message.ID == "" {
+				if metadata, err := msg.Metadata(); err == nil {
+					message.ID = topic + "/" + strconv.FormatUint(metadata.Sequence.Stream, 10)
+				}
+			}
+
+			if err := handle(ctx, message); err != nil {
+				// JetStream redelivers a paused message after the delay and goes on with the next ones
+				if until, paused := broker.PausedUntil(err); paused {
+					if err := msg.NakWithDelay(time.Until(until)); err != nil {
+						return err
+					}
+					continue
+				}
+				msg.Nak()
+				return err
+			}
+			if err := msg.AckSync(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Subscriber) Close() error {
+	return s.conn.Drain()
+}
@@ -1,60 +1,248 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"golang-fiber-poc/app/client"
-	"golang-fiber-poc/app/healthcheck"
 	"golang-fiber-poc/app/product"
+	"golang-fiber-poc/domain"
 	"golang-fiber-poc/infra/couchbase"
+	"golang-fiber-poc/pkg/auth"
+	"golang-fiber-poc/pkg/changefeed"
+	"golang-fiber-poc/pkg/codec"
 	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/consumer"
+	"golang-fiber-poc/pkg/di"
+	"golang-fiber-poc/pkg/eventbus"
+	"golang-fiber-poc/pkg/feature"
+	"golang-fiber-poc/pkg/gqlserver"
 	"golang-fiber-poc/pkg/handler"
-	_ "golang-fiber-poc/pkg/log"
+	"golang-fiber-poc/pkg/health"
+	"golang-fiber-poc/pkg/lifecycle"
+	"golang-fiber-poc/pkg/log"
+	"golang-fiber-poc/pkg/middlewares/accesslog"
+	"golang-fiber-poc/pkg/middlewares/idempotency"
+	"golang-fiber-poc/pkg/middlewares/prometheus"
+	"golang-fiber-poc/pkg/middlewares/ratelimit"
+	"golang-fiber-poc/pkg/outbox"
+	"golang-fiber-poc/pkg/tenant"
 	"golang-fiber-poc/pkg/tracer"
+	"net"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/gofiber/contrib/otelfiber/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
-	"github.com/gofiber/fiber/v2/middleware/basicauth"
 	recover "github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/pflag"
 	"go.uber.org/zap"
 )
 
 func main() {
-	appConfig := config.Read()
-	defer zap.L().Sync()
+	watcher := readConfig(os.Args[1:])
+	appConfig := watcher.Current()
 
 	zap.L().Info("Starting server...")
+	manager := lifecycle.New(lifecycle.Config{})
+	container := newContainer(watcher, manager)
 
-	transport := client.NewTransport()
-	noRetryClient := client.NewHttpClient(transport)
-	retryableClient := client.NewRetryableClient(transport)
+	if err := build(container, appConfig, manager); err != nil {
+		zap.L().Fatal("Failed to build the application", zap.Error(err))
+	}
+
+	err := manager.Run()
+	zap.L().Info("Server shutdown", zap.Error(err))
+	// Logging stops last, so that the components log until they are stopped
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_ = log.Shutdown(ctx)
+	cancel()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// readConfig loads the configuration, printing it for --print-config, and exits when it is invalid
+func readConfig(args []string) *config.Watcher {
+	options, err := config.ParseFlags(args)
+	if errors.Is(err, pflag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	appConfig, err := config.Load(options)
+	if options.PrintConfig && appConfig != nil {
+		if printErr := config.Print(os.Stdout, appConfig); printErr != nil {
+			fmt.Fprintln(os.Stderr, printErr)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if options.PrintConfig {
+		os.Exit(0)
+	}
+
+	if err = log.Configure(logConfig(appConfig)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	watcher := config.NewWatcher(options, appConfig)
+	config.Subscribe(watcher, "log", logConfig, func(logConfig log.Config) {
+		if err := log.Configure(logConfig); err != nil {
+			zap.L().Error("Failed to reconfigure logging", zap.Error(err))
+			return
+		}
+		zap.L().Info("Reconfigured logging", zap.String("level", logConfig.Level))
+	})
+	return watcher
+}
 
-	tp := tracer.InitTracer(appConfig.Jaeger)
-	couchbaseRepository := couchbase.NewRepository(tp, appConfig.Couchbase)
+// logConfig picks the settings of the global logger, the access log has its own subscriber
+func logConfig(appConfig *config.AppConfig) log.Config {
+	logConfig := log.Config{
+		Level:   appConfig.Log.Level,
+		Format:  appConfig.Log.Format,
+		Outputs: appConfig.Log.Outputs,
+		Rotation: log.Rotation{
+			MaxSize:    appConfig.Log.Rotation.MaxSize,
+			MaxAge:     appConfig.Log.Rotation.MaxAge,
+			MaxBackups: appConfig.Log.Rotation.MaxBackups,
+			Compress:   appConfig.Log.Rotation.Compress,
+		},
+		Sampling: log.Sampling{Initial: appConfig.Log.Sampling.Initial, Thereafter: appConfig.Log.Sampling.Thereafter},
+		Packages: appConfig.Log.Packages,
+		Redact:   appConfig.Log.Redact,
+	}
+	if appConfig.Log.OTel {
+		logConfig.OTLPEndpoint = appConfig.Jaeger.URL
+	}
+	return logConfig
+}
 
-	healthcheckHandler := healthcheck.NewHealthCheckHandler()
-	getProductHandler := product.NewGetProductHandler(couchbaseRepository, retryableClient, noRetryClient)
-	createProductHandler := product.NewCreateProductHandler(couchbaseRepository)
-	updateProductHandler := product.NewUpdateProductHandler(couchbaseRepository)
+// newContainer assembles the modules of the application, the overrides replace their providers
+func newContainer(watcher *config.Watcher, manager *lifecycle.Manager, overrides ...di.Module) *di.Container {
+	container := di.New(
+		client.Module,
+		tracer.Module,
+		couchbase.Module,
+		product.Module,
+		appModule,
+	)
+	di.Supply(container, watcher)
+	di.Supply(container, watcher.Current())
+	di.Supply(container, manager)
+	container.Install(overrides...)
+	return container
+}
+
+// build creates the components that run on their own, which register their lifecycle hooks on the way
+func build(container *di.Container, appConfig *config.AppConfig, manager *lifecycle.Manager) error {
+	if _, err := di.Get[*outbox.Relay](container); err != nil {
+		return err
+	}
+	if appConfig.ChangeFeed.Enabled {
+		if _, err := di.Get[*changefeed.Listener](container); err != nil {
+			return err
+		}
+	}
+	if appConfig.Consumer.Topic != "" {
+		if _, err := di.Get[*consumer.Consumer](container); err != nil {
+			return err
+		}
+	}
+	if _, err := di.Get[*fiber.App](container); err != nil {
+		return err
+	}
+	server, err := di.Get[*grpcServer](container)
+	if err != nil {
+		return err
+	}
+	healthRegistry, err := di.Get[*health.Registry](container)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := di.Get[*config.Watcher](container)
+	if err != nil {
+		return err
+	}
+	manager.Append(lifecycle.Background(lifecycle.Hook{Name: "config"}, watcher.Run))
+
+	// Stopping starts with withdrawing readiness, load balancers stop sending traffic before the servers stop
+	manager.Append(lifecycle.Hook{
+		Name:      "health",
+		DependsOn: []string{"http-server", "grpc-server"},
+		OnStart: func(context.Context) error {
+			healthRegistry.Started()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			healthRegistry.ShuttingDown()
+			server.health.Shutdown()
+			select {
+			case <-ctx.Done():
+			case <-time.After(appConfig.Shutdown.DrainDelay):
+			}
+			return nil
+		},
+		StopTimeout: appConfig.Shutdown.DrainDelay + time.Second,
+	})
+	return nil
+}
+
+func newHTTPServer(c *di.Container) (*fiber.App, error) {
+	appConfig := di.MustGet[*config.AppConfig](c)
+	manager := di.MustGet[*lifecycle.Manager](c)
+	healthRegistry := di.MustGet[*health.Registry](c)
+	users := di.MustGet[*auth.Provider](c)
+	rateLimiter := di.MustGet[*ratelimit.Limiter](c)
+	accessLog := di.MustGet[*accesslog.Logger](c)
+	features := di.MustGet[*feature.Evaluator](c)
+	productEvents := di.MustGet[*eventbus.Bus[domain.ProductEvent]](c)
+	getProductHandler := di.MustGet[*product.GetProductHandler](c)
+	createProductHandler := di.MustGet[*product.CreateProductHandler](c)
+	updateProductHandler := di.MustGet[*product.UpdateProductHandler](c)
+	bulkCreateProductHandler := di.MustGet[*product.BulkCreateProductHandler](c)
+	bulkUpsertProductHandler := di.MustGet[*product.BulkUpsertProductHandler](c)
+	bulkDeleteProductHandler := di.MustGet[*product.BulkDeleteProductHandler](c)
+	searchProductHandler := di.MustGet[*product.SearchProductHandler](c)
+	changeStreamHandler := di.MustGet[*product.ChangeStreamHandler](c)
+	graphQLServer := di.MustGet[*gqlserver.Server](c)
+	idempotencyStore := di.MustGet[idempotency.Store](c)
+
+	jsonEncoder, jsonDecoder := codec.JSONEncoder(appConfig.Server.JSON)
 
 	app := fiber.New(fiber.Config{
 		IdleTimeout:  5 * time.Second,
 		ReadTimeout:  3 * time.Second,
 		WriteTimeout: 3 * time.Second,
 		Concurrency:  256 * 1024,
+		// Lets NDJSON bulk imports be read while they are written instead of buffering the whole body
+		StreamRequestBody: true,
+		JSONEncoder:       jsonEncoder,
+		JSONDecoder:       jsonDecoder,
 	})
 
 	app.Use(recover.New())
 	app.Use(otelfiber.Middleware())
-	//app.Use(prometheus.RequestDurationMiddleware())
+	app.Use(accessLog.Handler())
+	app.Use(prometheus.RequestDurationMiddleware())
 
-	app.Get("/healthcheck", handler.Handle[healthcheck.Request, healthcheck.Response](healthcheckHandler))
+	app.Get("/livez", health.Handler(healthRegistry, health.Liveness))
+	app.Get("/readyz", health.Handler(healthRegistry, health.Readiness))
+	app.Get("/startupz", health.Handler(healthRegistry, health.Startup))
+	app.Get("/healthcheck", health.Handler(healthRegistry, health.Readiness))
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+	app.Get("/log/level", auth.BasicAuth(users), log.LevelHandler())
+	app.Put("/log/level", auth.BasicAuth(users), auth.RequireAdmin(users), log.LevelHandler())
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Hello, World 👋!")
 	})
@@ -66,38 +254,62 @@ func main() {
 	mainRouter := app.Group("/api")
 	v1Group := mainRouter.Group("/v1")
 
-	productGroup := v1Group.Group("/product", basicauth.New(basicauth.Config{
-		Users: map[string]string{
-			"admin": "password",
+	tenants := tenant.New(tenantResolver(appConfig, users))
+
+	productGroup := v1Group.Group("/product", auth.BasicAuth(users), tenants, rateLimiter.Handler(), feature.Middleware(features), idempotency.New(idempotency.Config{
+		Store:       idempotencyStore,
+		Lifetime:    appConfig.Idempotency.Lifetime,
+		WaitTimeout: appConfig.Idempotency.Wait,
+		KeyScope: func(c *fiber.Ctx) string {
+			username, _ := c.Locals("username").(string)
+			if tenantID, ok := tenant.FromContext(c.UserContext()); ok {
+				return tenantID + "/" + username
+			}
+			return username
+		},
+		StoreErrorStatus: func(err error) int {
+			if errors.Is(err, domain.ErrStoreUnavailable) {
+				return fiber.StatusServiceUnavailable
+			}
+			return fiber.StatusInternalServerError
 		},
 	}))
 
+	productGroup.Get("/changes", changeStreamHandler.SSE)
+	productGroup.Get("/changes/ws", changeStreamHandler.WebSocket())
+	productGroup.Get("/search", handler.Handle[product.SearchProductRequest, product.SearchProductResponse](searchProductHandler))
+	productGroup.Post("/bulk", handler.Handle[product.BulkCreateProductRequest, product.BulkResponse](bulkCreateProductHandler))
+	productGroup.Put("/bulk", handler.Handle[product.BulkUpsertProductRequest, product.BulkResponse](bulkUpsertProductHandler))
+	productGroup.Delete("/bulk", handler.Handle[product.BulkDeleteProductRequest, product.BulkResponse](bulkDeleteProductHandler))
 	productGroup.Get("/:id", handler.Handle[product.GetProductRequest, product.GetProductResponse](getProductHandler))
 	productGroup.Post("/", handler.Handle[product.CreateProductRequest, product.CreateProductResponse](createProductHandler))
 	productGroup.Put("/:id", handler.Handle[product.UpdateProductRequest, product.UpdateProductResponse](updateProductHandler))
 
-	go func() {
-		if err := app.Listen(fmt.Sprintf(":%s", appConfig.Port)); err != nil {
-			zap.L().Error("Failed to start server", zap.Error(err))
-			os.Exit(1)
-		}
-	}()
-
-	zap.L().Info("Server started on port", zap.String("port", appConfig.Port))
-
-	gracefulShutdown(app)
-}
-
-func gracefulShutdown(app *fiber.App) {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	graphQLMiddlewares := []fiber.Handler{auth.BasicAuth(users), tenants, rateLimiter.Handler(), feature.Middleware(features)}
+	mainRouter.Get("/graphql", append(graphQLMiddlewares, handler.Handle[gqlserver.GetRequest, gqlserver.Response](gqlserver.GetServer{Server: graphQLServer}))...)
+	mainRouter.Post("/graphql", append(graphQLMiddlewares, handler.Handle[gqlserver.Request, gqlserver.Response](graphQLServer))...)
 
-	<-sigChan
-	zap.L().Info("Shutting down server...")
-
-	if err := app.ShutdownWithTimeout(5 * time.Second); err != nil {
-		zap.L().Error("Failed to shutdown server", zap.Error(err))
-	}
-
-	zap.L().Info("Server shutdown successfully")
+	manager.Append(lifecycle.Hook{
+		Name:      "http-server",
+		DependsOn: serverDependencies(appConfig),
+		OnStart: func(context.Context) error {
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%s", appConfig.Port))
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := app.Listener(listener); err != nil {
+					manager.Fail(fmt.Errorf("http server: %w", err))
+				}
+			}()
+			zap.L().Info("Server started on port", zap.String("port", appConfig.Port))
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			// Change streams own their connections, which the server does not wait for
+			productEvents.Close()
+			return app.ShutdownWithContext(ctx)
+		},
+	})
+	return app, nil
 }
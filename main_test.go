@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"golang-fiber-poc/app/product"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/infra/memory"
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/di"
+	"golang-fiber-poc/pkg/lifecycle"
+	"testing"
+)
+
+type recordedEvents []domain.ProductEvent
+
+func (r *recordedEvents) Publish(event domain.ProductEvent) {
+	*r = append(*r, event)
+}
+
+func TestContainerOverrides(t *testing.T) {
+	appConfig := config.Defaults()
+	repository := memory.NewRepository()
+	events := &recordedEvents{}
+	container := newContainer(config.NewWatcher(config.Options{}, &appConfig), lifecycle.New(lifecycle.Config{}), func(c *di.Container) {
+		di.Supply[product.Repository](c, repository)
+		di.Supply[product.EventPublisher](c, events)
+	})
+
+	createProductHandler, err := di.Get[*product.CreateProductHandler](container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := createProductHandler.Handle(context.Background(), &product.CreateProductRequest{Name: "chair"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = repository.GetProduct(context.Background(), res.ID); err != nil {
+		t.Fatalf("the product is not in the fake repository: %v", err)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("%d events, want the one of the creation in the fake publisher", len(*events))
+	}
+}
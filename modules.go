@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/app/product"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/infra/couchbase"
+	"golang-fiber-poc/infra/kafka"
+	"golang-fiber-poc/infra/memory"
+	"golang-fiber-poc/infra/nats"
+	"golang-fiber-poc/pkg/auth"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/changefeed"
+	"golang-fiber-poc/pkg/circuitbreaker"
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/consumer"
+	"golang-fiber-poc/pkg/di"
+	"golang-fiber-poc/pkg/eventbus"
+	"golang-fiber-poc/pkg/feature"
+	"golang-fiber-poc/pkg/gqlserver"
+	"golang-fiber-poc/pkg/grpcserver"
+	"golang-fiber-poc/pkg/handler"
+	"golang-fiber-poc/pkg/health"
+	"golang-fiber-poc/pkg/lifecycle"
+	"golang-fiber-poc/pkg/middlewares/accesslog"
+	"golang-fiber-poc/pkg/middlewares/idempotency"
+	"golang-fiber-poc/pkg/middlewares/ratelimit"
+	"golang-fiber-poc/pkg/outbox"
+	"golang-fiber-poc/pkg/tenant"
+	"golang-fiber-poc/pkg/tracer"
+	productv1 "golang-fiber-poc/proto/product/v1"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// appModule picks the implementations from the config and provides the background components and servers
+func appModule(c *di.Container) {
+	di.Provide(c, func(c *di.Container) (*auth.Provider, error) {
+		watcher := di.MustGet[*config.Watcher](c)
+		provider := auth.NewProvider(authUsers(watcher.Current().Auth))
+		config.Subscribe(watcher, "auth", func(c *config.AppConfig) config.AuthConfig { return c.Auth }, func(authConfig config.AuthConfig) {
+			provider.SetUsers(authUsers(authConfig))
+			zap.L().Info("Reloaded auth users", zap.Int("users", len(authConfig.Users)))
+		})
+		return provider, nil
+	})
+	di.Provide(c, func(c *di.Container) (*ratelimit.Limiter, error) {
+		watcher := di.MustGet[*config.Watcher](c)
+		rateLimit := watcher.Current().RateLimit
+		limiter := ratelimit.New(ratelimit.Config{
+			Max:        rateLimit.Max,
+			Expiration: rateLimit.Expiration,
+			Groups:     tenantRateLimits(watcher.Current().Tenancy.Tenants),
+		})
+		config.Subscribe(watcher, "ratelimit", func(c *config.AppConfig) config.RateLimitConfig { return c.RateLimit }, func(rateLimit config.RateLimitConfig) {
+			limiter.SetLimit(rateLimit.Max, rateLimit.Expiration)
+			zap.L().Info("Changed rate limit", zap.Int("max", rateLimit.Max), zap.Duration("expiration", rateLimit.Expiration))
+		})
+		config.Subscribe(watcher, "tenancy", func(c *config.AppConfig) map[string]ratelimit.Limit { return tenantRateLimits(c.Tenancy.Tenants) }, func(limits map[string]ratelimit.Limit) {
+			limiter.SetGroups(limits)
+			zap.L().Info("Changed tenant rate limits", zap.Int("tenants", len(limits)))
+		})
+		return limiter, nil
+	})
+	di.Provide(c, func(c *di.Container) (*accesslog.Logger, error) {
+		watcher := di.MustGet[*config.Watcher](c)
+		access := watcher.Current().Log.Access
+		logger := accesslog.New(accesslog.Config{
+			// Probes and scrapes would drown the requests of clients
+			Next: func(c *fiber.Ctx) bool {
+				return slices.Contains([]string{"/livez", "/readyz", "/startupz", "/healthcheck", "/metrics"}, c.Path())
+			},
+			SampleRate:    access.SampleRate,
+			SlowThreshold: access.SlowThreshold,
+		})
+		config.Subscribe(watcher, "log", func(c *config.AppConfig) config.AccessLogConfig { return c.Log.Access }, func(access config.AccessLogConfig) {
+			logger.SetSampling(access.SampleRate, access.SlowThreshold)
+			zap.L().Info("Changed access log sampling", zap.Float64("samplerate", access.SampleRate), zap.Duration("slowthreshold", access.SlowThreshold))
+		})
+		return logger, nil
+	})
+	di.Provide(c, func(c *di.Container) (*circuitbreaker.Registry, error) {
+		watcher := di.MustGet[*config.Watcher](c)
+		registry := circuitbreaker.NewRegistry()
+		registry.Update(circuitBreakerOverrides(watcher.Current().CircuitBreakers))
+		config.Subscribe(watcher, "circuitbreakers", func(c *config.AppConfig) map[string]config.CircuitBreakerConfig { return c.CircuitBreakers }, func(breakers map[string]config.CircuitBreakerConfig) {
+			registry.Update(circuitBreakerOverrides(breakers))
+		})
+		return registry, nil
+	})
+
+	di.Provide(c, func(c *di.Container) (*feature.Evaluator, error) {
+		watcher := di.MustGet[*config.Watcher](c)
+		features := watcher.Current().Features
+		evaluator := feature.NewEvaluator(featureFlags(features.Flags))
+		product.DefineFlags(evaluator)
+		config.Subscribe(watcher, "features", func(c *config.AppConfig) map[string]config.FeatureFlagConfig { return c.Features.Flags }, func(flags map[string]config.FeatureFlagConfig) {
+			evaluator.SetFlags(featureFlags(flags))
+			zap.L().Info("Reloaded feature flags", zap.Int("flags", len(flags)))
+		})
+		evaluator.SetTenantFlags(tenantFeatureFlags(watcher.Current().Tenancy.Tenants))
+		config.Subscribe(watcher, "tenancy", func(c *config.AppConfig) map[string]map[string]feature.Flag {
+			return tenantFeatureFlags(c.Tenancy.Tenants)
+		}, func(flags map[string]map[string]feature.Flag) {
+			evaluator.SetTenantFlags(flags)
+			zap.L().Info("Reloaded tenant feature flags", zap.Int("tenants", len(flags)))
+		})
+		if features.Remote.URL != "" {
+			provider := feature.HTTPProvider{URL: features.Remote.URL}
+			di.MustGet[*lifecycle.Manager](c).Append(lifecycle.Background(lifecycle.Hook{Name: "feature-flags"}, func(ctx context.Context) {
+				evaluator.Poll(ctx, provider, features.Remote.Interval)
+			}))
+		}
+		return evaluator, nil
+	})
+
+	di.Provide(c, func(c *di.Container) (*brokerConnection, error) {
+		publisher, subscriber, err := newBroker(di.MustGet[*config.AppConfig](c).Broker)
+		if err != nil {
+			return nil, fmt.Errorf("connect to broker: %w", err)
+		}
+		di.MustGet[*lifecycle.Manager](c).Append(lifecycle.Hook{
+			Name: "broker",
+			OnStop: func(context.Context) error {
+				return errors.Join(subscriber.Close(), publisher.Close())
+			},
+		})
+		return &brokerConnection{publisher: publisher, subscriber: subscriber}, nil
+	})
+	di.Provide(c, func(c *di.Container) (broker.Publisher, error) {
+		return di.MustGet[*brokerConnection](c).publisher, nil
+	})
+	di.Provide(c, func(c *di.Container) (broker.Subscriber, error) {
+		return di.MustGet[*brokerConnection](c).subscriber, nil
+	})
+
+	di.Provide(c, func(c *di.Container) (*outbox.Relay, error) {
+		outboxConfig := di.MustGet[*config.AppConfig](c).Outbox
+		relay := outbox.NewRelay(di.MustGet[*couchbase.OutboxStore](c), di.MustGet[broker.Publisher](c), outbox.Config{
+			Topic:      outboxConfig.Topic,
+			BatchSize:  outboxConfig.BatchSize,
+			Interval:   outboxConfig.Interval,
+			MaxBackoff: outboxConfig.MaxBackoff,
+		})
+		// Events that are not published yet stay in the outbox for the next instance
+		di.MustGet[*lifecycle.Manager](c).Append(lifecycle.Background(lifecycle.Hook{
+			Name:      "outbox-relay",
+			DependsOn: []string{"couchbase", "broker"},
+		}, relay.Run))
+		return relay, nil
+	})
+
+	di.Provide(c, func(c *di.Container) (*eventbus.Bus[domain.ProductEvent], error) {
+		return eventbus.New[domain.ProductEvent](di.MustGet[*config.AppConfig](c).Stream.WithDefaults().History), nil
+	})
+
+	// With the change feed the events of the handlers are published together with the changes of other tools
+	di.Provide(c, func(c *di.Container) (product.EventPublisher, error) {
+		if di.MustGet[*config.AppConfig](c).ChangeFeed.Enabled {
+			return di.MustGet[*product.FeedEvents](c), nil
+		}
+		return di.MustGet[*eventbus.Bus[domain.ProductEvent]](c), nil
+	})
+	di.Provide(c, func(c *di.Container) (*product.FeedEvents, error) {
+		return product.NewFeedEvents(di.MustGet[*eventbus.Bus[domain.ProductEvent]](c)), nil
+	})
+
+	// Products are cached when the change feed evicts the ones that change
+	di.Provide(c, func(c *di.Container) (product.Repository, error) {
+		appConfig := di.MustGet[*config.AppConfig](c)
+		if appConfig.ChangeFeed.Enabled && appConfig.Cache.Size > 0 {
+			return di.MustGet[*product.CachedRepository](c), nil
+		}
+		return di.MustGet[*couchbase.Repository](c), nil
+	})
+	di.Provide(c, func(c *di.Container) (*product.CachedRepository, error) {
+		return product.NewCachedRepository(di.MustGet[*couchbase.Repository](c), di.MustGet[*config.AppConfig](c).Cache, di.MustGet[*feature.Evaluator](c)), nil
+	})
+	di.Bind[product.BatchRepository, *couchbase.Repository](c)
+	di.Bind[product.BulkRepository, *couchbase.Repository](c)
+	di.Bind[product.SearchRepository, *couchbase.Repository](c)
+
+	di.Provide(c, func(c *di.Container) (changefeed.CheckpointStore, error) {
+		if di.MustGet[*config.AppConfig](c).ChangeFeed.Checkpoints == "couchbase" {
+			return di.MustGet[*couchbase.CheckpointStore](c), nil
+		}
+		return changefeed.NewMemoryCheckpointStore(), nil
+	})
+	di.Provide(c, func(c *di.Container) (*changefeed.Listener, error) {
+		appConfig := di.MustGet[*config.AppConfig](c)
+		listener := changefeed.NewListener(di.MustGet[*couchbase.DCPSource](c), changefeed.Config{
+			Store:              di.MustGet[changefeed.CheckpointStore](c),
+			Skip:               di.MustGet[*couchbase.DCPSource](c).Internal,
+			CheckpointInterval: appConfig.ChangeFeed.CheckpointInterval,
+		})
+		// Search needs no subscriber, the full text search index follows the bucket by itself
+		listener.Subscribe(di.MustGet[*product.FeedEvents](c).Changes)
+		if appConfig.Cache.Size > 0 {
+			listener.Subscribe(di.MustGet[*product.CachedRepository](c).Changes)
+		}
+
+		// The change feed saves its checkpoints once more when it stops, after the change streams are closed
+		di.MustGet[*lifecycle.Manager](c).Append(lifecycle.Background(lifecycle.Hook{
+			Name:      "change-feed",
+			DependsOn: []string{"couchbase"},
+		}, listener.Run))
+		return listener, nil
+	})
+
+	di.Provide(c, func(c *di.Container) (consumer.Store, error) {
+		if di.MustGet[*config.AppConfig](c).Consumer.Store == "couchbase" {
+			return di.MustGet[*couchbase.ProcessedStore](c), nil
+		}
+		return consumer.NewMemoryStore(), nil
+	})
+	di.Provide(c, func(c *di.Container) (*consumer.Consumer, error) {
+		consumerConfig := di.MustGet[*config.AppConfig](c).Consumer
+		productConsumer := consumer.New(di.MustGet[broker.Subscriber](c), di.MustGet[broker.Publisher](c), consumer.Router{
+			"product.created": handler.Consume[product.CreateProductRequest, product.CreateProductResponse](di.MustGet[*product.CreateProductHandler](c)),
+			"product.updated": handler.Consume[product.UpdateProductRequest, product.UpdateProductResponse](di.MustGet[*product.UpdateProductHandler](c)),
+		}.Handle, consumer.Config{
+			Topic:             consumerConfig.Topic,
+			Group:             consumerConfig.Group,
+			RetryDelays:       consumerConfig.RetryDelays,
+			DeadLetterTopic:   consumerConfig.DeadLetterTopic,
+			Store:             di.MustGet[consumer.Store](c),
+			ProcessedLifetime: consumerConfig.ProcessedLifetime,
+		})
+
+		di.MustGet[*health.Registry](c).Register("consumer", productConsumer.Health, health.Config{Probes: health.Readiness})
+		// Consumed messages that are being handled are finished and committed, the others are left to the group
+		di.MustGet[*lifecycle.Manager](c).Append(lifecycle.Background(lifecycle.Hook{
+			Name:        "consumer",
+			DependsOn:   []string{"couchbase", "broker"},
+			StopTimeout: 30 * time.Second,
+		}, productConsumer.Run))
+		return productConsumer, nil
+	})
+
+	di.Provide(c, func(c *di.Container) (*health.Registry, error) {
+		registry := health.NewRegistry()
+		registry.Register("couchbase", di.MustGet[*couchbase.Repository](c).Ready, health.Config{
+			Probes:        health.Readiness,
+			Critical:      true,
+			ComponentType: "datastore",
+		})
+		registry.Register("get-product-circuit", di.MustGet[*product.GetProductHandler](c).Health, health.Config{Probes: health.Readiness})
+		registry.Register("tracer-exporter", tracer.Check(di.MustGet[*config.AppConfig](c).Jaeger), health.Config{
+			Probes:   health.Readiness,
+			Timeout:  time.Second,
+			CacheTTL: 30 * time.Second,
+		})
+		return registry, nil
+	})
+
+	di.Provide(c, func(c *di.Container) (idempotency.Store, error) {
+		if di.MustGet[*config.AppConfig](c).Idempotency.Store == "couchbase" {
+			return di.MustGet[*couchbase.IdempotencyStore](c), nil
+		}
+		return idempotency.NewMemoryStore(), nil
+	})
+
+	di.Provide(c, func(c *di.Container) (*gqlserver.Server, error) {
+		graphQLConfig := di.MustGet[*config.AppConfig](c).GraphQL
+		graphQLResolver := di.MustGet[*product.GraphQLResolver](c)
+		graphQLSchema, err := graphQLResolver.Schema()
+		if err != nil {
+			return nil, fmt.Errorf("build GraphQL schema: %w", err)
+		}
+		return gqlserver.New(gqlserver.Config{
+			Schema:           graphQLSchema,
+			Context:          graphQLResolver.Context,
+			MaxDepth:         graphQLConfig.MaxDepth,
+			MaxComplexity:    graphQLConfig.MaxComplexity,
+			PersistedQueries: graphQLConfig.PersistedQueries,
+		}), nil
+	})
+
+	di.Provide(c, newHTTPServer)
+	di.Provide(c, newGRPCServer)
+}
+
+func authUsers(authConfig config.AuthConfig) auth.Users {
+	users := make(auth.Users, len(authConfig.Users))
+	for _, user := range authConfig.Users {
+		users[user.Username] = auth.User{Password: user.Password.Value(), Tenants: user.Tenants, Admin: user.Admin}
+	}
+	return users
+}
+
+func circuitBreakerOverrides(breakers map[string]config.CircuitBreakerConfig) map[string]circuitbreaker.CircuitBreakerConfig {
+	overrides := make(map[string]circuitbreaker.CircuitBreakerConfig, len(breakers))
+	for name, breaker := range breakers {
+		overrides[name] = circuitbreaker.CircuitBreakerConfig{
+			Name:                    name,
+			MaxRequests:             breaker.MaxRequests,
+			Interval:                breaker.Interval,
+			Timeout:                 breaker.Timeout,
+			RequestsVolumeThreshold: breaker.RequestsVolumeThreshold,
+			FailureThreshold:        breaker.FailureThreshold,
+		}
+	}
+	return overrides
+}
+
+func featureFlags(flags map[string]config.FeatureFlagConfig) map[string]feature.Flag {
+	featureFlags := make(map[string]feature.Flag, len(flags))
+	for name, flag := range flags {
+		rules := make([]feature.Rule, len(flag.Rules))
+		for i, rule := range flag.Rules {
+			rules[i] = feature.Rule{
+				Principals: rule.Principals,
+				Tenants:    rule.Tenants,
+				Header:     rule.Header,
+				Values:     rule.Values,
+				Enabled:    rule.Enabled,
+				Rollout:    rollout(rule.Rollout),
+			}
+		}
+		featureFlags[name] = feature.Flag{Enabled: flag.Enabled, Rollout: rollout(flag.Rollout), Rules: rules}
+	}
+	return featureFlags
+}
+
+// rollout is the percentage of a flag or rule, all subjects when it is not configured
+func rollout(percentage *int) int {
+	if percentage == nil {
+		return 100
+	}
+	return *percentage
+}
+
+// tenantRateLimits returns the rate limits of the tenants that override it
+func tenantRateLimits(tenants map[string]config.TenantConfig) map[string]ratelimit.Limit {
+	limits := make(map[string]ratelimit.Limit)
+	for id, overrides := range tenants {
+		if overrides.RateLimit != nil {
+			limits[id] = ratelimit.Limit{Max: overrides.RateLimit.Max, Expiration: overrides.RateLimit.Expiration}
+		}
+	}
+	return limits
+}
+
+// tenantFeatureFlags returns the flags of the tenants that override them
+func tenantFeatureFlags(tenants map[string]config.TenantConfig) map[string]map[string]feature.Flag {
+	flags := make(map[string]map[string]feature.Flag)
+	for id, overrides := range tenants {
+		if len(overrides.Features) > 0 {
+			flags[id] = featureFlags(overrides.Features)
+		}
+	}
+	return flags
+}
+
+// tenantResolver resolves the tenants of couchbase.tenants as tenancy configures it, for the users allowed to
+func tenantResolver(appConfig *config.AppConfig, users *auth.Provider) tenant.Config {
+	return tenant.Config{
+		Header:      appConfig.Tenancy.Header,
+		Domain:      appConfig.Tenancy.Domain,
+		Claim:       appConfig.Tenancy.Claim,
+		Secret:      []byte(appConfig.Tenancy.Secret.Value()),
+		TokenHeader: appConfig.Tenancy.TokenHeader,
+		Required:    appConfig.Tenancy.Required,
+		Known: func(id string) bool {
+			_, ok := appConfig.Couchbase.Tenants[id]
+			return ok
+		},
+		Allowed: func(ctx context.Context, id string) bool {
+			principal, _ := auth.PrincipalFromContext(ctx)
+			return users.Allows(principal, id)
+		},
+	}
+}
+
+type brokerConnection struct {
+	publisher  broker.Publisher
+	subscriber broker.Subscriber
+}
+
+func newBroker(brokerConfig config.BrokerConfig) (broker.Publisher, broker.Subscriber, error) {
+	switch brokerConfig.Type {
+	case "kafka":
+		return kafka.NewPublisher(brokerConfig.Kafka), kafka.NewSubscriber(brokerConfig.Kafka), nil
+	case "nats":
+		publisher, err := nats.NewPublisher(brokerConfig.NATS)
+		if err != nil {
+			return nil, nil, err
+		}
+		subscriber, err := nats.NewSubscriber(brokerConfig.NATS)
+		if err != nil {
+			publisher.Close()
+			return nil, nil, err
+		}
+		return publisher, subscriber, nil
+	default:
+		memoryBroker := memory.NewBroker()
+		return memoryBroker, memoryBroker, nil
+	}
+}
+
+type grpcServer struct {
+	server *grpc.Server
+	health *grpchealth.Server
+}
+
+func newGRPCServer(c *di.Container) (*grpcServer, error) {
+	appConfig := di.MustGet[*config.AppConfig](c)
+	manager := di.MustGet[*lifecycle.Manager](c)
+
+	server, healthServer := grpcserver.New(di.MustGet[*auth.Provider](c), tenantResolver(appConfig, di.MustGet[*auth.Provider](c)), di.MustGet[*ratelimit.Limiter](c), di.MustGet[*feature.Evaluator](c))
+	productv1.RegisterProductServiceServer(server, di.MustGet[*product.GRPCService](c))
+	healthServer.SetServingStatus(productv1.ProductService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	manager.Append(lifecycle.Hook{
+		Name:      "grpc-server",
+		DependsOn: serverDependencies(appConfig),
+		OnStart: func(context.Context) error {
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%s", appConfig.GRPC.Port))
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := server.Serve(listener); err != nil {
+					manager.Fail(fmt.Errorf("gRPC server: %w", err))
+				}
+			}()
+			zap.L().Info("gRPC server started on port", zap.String("port", appConfig.GRPC.Port))
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				server.Stop()
+			}
+			return nil
+		},
+	})
+	return &grpcServer{server: server, health: healthServer}, nil
+}
+
+// serverDependencies are the components that stop after the servers
+func serverDependencies(appConfig *config.AppConfig) []string {
+	dependencies := []string{"couchbase", "broker"}
+	if appConfig.ChangeFeed.Enabled {
+		dependencies = append(dependencies, "change-feed")
+	}
+	return dependencies
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+type principalKey struct{}
+
+// AnyTenant lets a user act for every tenant
+const AnyTenant = "*"
+
+type User struct {
+	Password string
+	// Tenants are the tenants the user may act for, the user may only use the shared storage without them
+	Tenants []string
+	// Admin lets the user change the service itself, e.g. its log levels
+	Admin bool
+}
+
+// Users maps basic auth usernames to users. It is shared by the HTTP and gRPC transports.
+type Users map[string]User
+
+func (u Users) Authenticate(username, password string) bool {
+	user, ok := u[username]
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1
+}
+
+// Allows tells whether a user may act for a tenant. Tenant ids are case-insensitive.
+func (u Users) Allows(username, tenantID string) bool {
+	return slices.ContainsFunc(u[username].Tenants, func(allowed string) bool {
+		return allowed == AnyTenant || strings.EqualFold(allowed, tenantID)
+	})
+}
+
+// IsAdmin tells whether a user is an admin
+func (u Users) IsAdmin(username string) bool {
+	return u[username].Admin
+}
+
+// Authenticator checks the credentials of a user
+type Authenticator interface {
+	Authenticate(username, password string) bool
+}
+
+// Provider authenticates against users that can be replaced while it is used
+type Provider struct {
+	users atomic.Pointer[Users]
+}
+
+func NewProvider(users Users) *Provider {
+	provider := &Provider{}
+	provider.SetUsers(users)
+	return provider
+}
+
+func (p *Provider) Authenticate(username, password string) bool {
+	return p.users.Load().Authenticate(username, password)
+}
+
+func (p *Provider) Allows(username, tenantID string) bool {
+	return p.users.Load().Allows(username, tenantID)
+}
+
+func (p *Provider) IsAdmin(username string) bool {
+	return p.users.Load().IsAdmin(username)
+}
+
+// SetUsers replaces the users, requests being authenticated use either the old or the new ones
+func (p *Provider) SetUsers(users Users) {
+	p.users.Store(&users)
+}
+
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated username
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BasicAuth checks the credentials against users. The username is stored in the "username" local,
+// like fiber's basicauth middleware does, and on the user context for PrincipalFromContext.
+func BasicAuth(users Authenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username, password, ok := parseBasicAuth(c.Get(fiber.HeaderAuthorization))
+		if !ok || !users.Authenticate(username, password) {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Restricted"`)
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+
+		c.Locals("username", username)
+		c.SetUserContext(WithPrincipal(c.UserContext(), username))
+		return c.Next()
+	}
+}
+
+// RequireAdmin answers 403 to the users authenticated by BasicAuth that are not admins
+func RequireAdmin(users *Provider) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username, _ := c.Locals("username").(string)
+		if !users.IsAdmin(username) {
+			return c.SendStatus(fiber.StatusForbidden)
+		}
+		return c.Next()
+	}
+}
+
+func parseBasicAuth(header string) (string, string, bool) {
+	encoded, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	users := NewProvider(Users{
+		"admin":   {Password: "secret", Tenants: []string{AnyTenant}, Admin: true},
+		"acme":    {Password: "secret", Tenants: []string{"acme"}},
+		"support": {Password: "secret", Tenants: []string{AnyTenant}},
+	})
+	app := fiber.New()
+	app.Put("/log/level", BasicAuth(users), RequireAdmin(users), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	tests := []struct {
+		username string
+		password string
+		status   int
+	}{
+		{username: "admin", password: "secret", status: fiber.StatusNoContent},
+		{username: "acme", password: "secret", status: fiber.StatusForbidden},
+		{username: "support", password: "secret", status: fiber.StatusForbidden},
+		{username: "admin", password: "wrong", status: fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodPut, "/log/level", nil)
+		req.SetBasicAuth(tt.username, tt.password)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.username, resp.StatusCode, tt.status)
+		}
+	}
+}
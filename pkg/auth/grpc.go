@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor checks basic auth credentials sent in the "authorization" metadata.
+// Methods for which skip returns true, such as health checks, are not authenticated.
+func UnaryServerInterceptor(users Authenticator, skip func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if skip != nil && skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "missing credentials")
+		}
+
+		username, password, ok := parseBasicAuth(values[0])
+		if !ok || !users.Authenticate(username, password) {
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+
+		return handler(WithPrincipal(ctx, username), req)
+	}
+}
@@ -0,0 +1,59 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Message is published to and consumed from a broker. Messages with the same key keep their order.
+type Message struct {
+	// ID identifies the message, so that brokers and consumers can drop duplicates
+	ID      string
+	Topic   string
+	Key     string
+	Headers map[string]string
+	Value   []byte
+}
+
+type Publisher interface {
+	// Publish returns once the broker has accepted the message
+	Publish(ctx context.Context, message Message) error
+	Close() error
+}
+
+// Handler processes one consumed message. The message is committed when it returns nil.
+type Handler func(ctx context.Context, message Message) error
+
+type pause struct {
+	until time.Time
+}
+
+func (p *pause) Error() string {
+	return "message paused until " + p.until.Format(time.RFC3339Nano)
+}
+
+// Pause is returned by a handler for a message that cannot be handled before until. The message is not
+// committed, the subscriber delivers it again at until.
+func Pause(until time.Time) error {
+	return &pause{until: until}
+}
+
+// PausedUntil returns the time of an error made by Pause
+func PausedUntil(err error) (time.Time, bool) {
+	var p *pause
+	if errors.As(err, &p) {
+		return p.until, true
+	}
+	return time.Time{}, false
+}
+
+type Subscriber interface {
+	// Subscribe consumes topic as a member of group until ctx is done or handle fails. Messages are handled
+	// one at a time and committed for the group once handled, so a restart resumes after the last one.
+	// A message that is being handled when ctx is done is finished and committed first. When handle returns
+	// a Pause, the subscriber stops fetching the messages it would have to hold back, instead of waiting in
+	// the handler.
+	Subscribe(ctx context.Context, topic, group string, handle Handler) error
+	Close() error
+}
@@ -0,0 +1,51 @@
+package changefeed
+
+import (
+	"context"
+	"time"
+)
+
+// Change is a mutation or deletion of a document, in the order of its partition
+type Change struct {
+	Key string
+	// Deleted is set for deletions and expirations, which carry no value
+	Deleted bool
+	Value   []byte
+	Cas     uint64
+	// Revision counts the mutations of the document, it is 1 when the document is created
+	Revision   uint64
+	Partition  uint16
+	Checkpoint Checkpoint
+}
+
+// Time returns when the change was made. Couchbase CAS values are hybrid logical clocks in nanoseconds.
+func (c Change) Time() time.Time {
+	return time.Unix(0, int64(c.Cas)).UTC()
+}
+
+// Checkpoint is the position of a partition. UUID identifies the history of the partition, so that
+// the source notices when the partition was rolled back after a failover.
+type Checkpoint struct {
+	UUID          uint64 `json:"uuid"`
+	SeqNo         uint64 `json:"seqNo"`
+	SnapshotStart uint64 `json:"snapshotStart"`
+	SnapshotEnd   uint64 `json:"snapshotEnd"`
+}
+
+// Checkpoints has the position of every partition
+type Checkpoints map[uint16]Checkpoint
+
+type Source interface {
+	// Stream sends the changes after the checkpoints to handle until ctx is done or the stream fails.
+	// Partitions without a checkpoint start from the beginning or from now, depending on the source.
+	// handle is called concurrently for different partitions, and in order within a partition.
+	Stream(ctx context.Context, from Checkpoints, handle func(Change)) error
+}
+
+type CheckpointStore interface {
+	Load(ctx context.Context) (Checkpoints, error)
+	Save(ctx context.Context, checkpoints Checkpoints) error
+}
+
+// Subscriber receives the changes of the feed. It must not block for long, it holds back its partition.
+type Subscriber func(ctx context.Context, change Change)
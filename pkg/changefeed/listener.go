@@ -0,0 +1,173 @@
+package changefeed
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Config defines the config for a listener
+type Config struct {
+	// Store keeps the checkpoints, so that the listener resumes where it stopped
+	//
+	// Optional. Default: NewMemoryCheckpointStore()
+	Store CheckpointStore
+
+	// Skip filters out changes that subscribers are not interested in. Skipped changes advance the
+	// checkpoints but do not cause them to be saved, so that saving a checkpoint to the bucket does not
+	// cause another save.
+	//
+	// Optional. Default: nil
+	Skip func(key string) bool
+
+	// CheckpointInterval is the pause between saves of the checkpoints
+	//
+	// Optional. Default: 5 * time.Second
+	CheckpointInterval time.Duration
+}
+
+// Listener fans the changes of a source out to in-process subscribers. The source is restarted from
+// the last checkpoints when it fails, so subscribers may see a change again.
+type Listener struct {
+	source      Source
+	config      Config
+	subscribers []Subscriber
+
+	mu          sync.Mutex
+	checkpoints Checkpoints
+	dirty       bool
+}
+
+func NewListener(source Source, config Config) *Listener {
+	if config.Store == nil {
+		config.Store = NewMemoryCheckpointStore()
+	}
+	if config.CheckpointInterval <= 0 {
+		config.CheckpointInterval = 5 * time.Second
+	}
+
+	return &Listener{source: source, config: config}
+}
+
+// Subscribe adds a subscriber. Subscribers must be added before Run.
+func (l *Listener) Subscribe(subscriber Subscriber) {
+	l.subscribers = append(l.subscribers, subscriber)
+}
+
+// Run streams the changes until ctx is done and saves the checkpoints on the way
+func (l *Listener) Run(ctx context.Context) {
+	checkpoints, err := l.config.Store.Load(ctx)
+	if err != nil {
+		zap.L().Error("Failed to load change feed checkpoints, starting over", zap.Error(err))
+	}
+	if checkpoints == nil {
+		checkpoints = make(Checkpoints)
+	}
+	l.checkpoints = checkpoints
+
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		l.checkpoint(ctx)
+	}()
+
+	for attempt := 1; ctx.Err() == nil; attempt++ {
+		err := l.source.Stream(ctx, l.snapshot(), func(change Change) {
+			l.handle(ctx, change)
+		})
+		if ctx.Err() != nil {
+			break
+		}
+		zap.L().Error("Change feed failed", zap.Error(err), zap.Int("attempt", attempt))
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(min(time.Duration(attempt)*time.Second, 30*time.Second)):
+		}
+	}
+
+	<-saved
+}
+
+func (l *Listener) handle(ctx context.Context, change Change) {
+	skip := l.config.Skip != nil && l.config.Skip(change.Key)
+	if !skip {
+		for _, subscriber := range l.subscribers {
+			subscriber(ctx, change)
+		}
+	}
+
+	l.mu.Lock()
+	l.checkpoints[change.Partition] = change.Checkpoint
+	l.dirty = l.dirty || !skip
+	l.mu.Unlock()
+}
+
+// checkpoint saves the checkpoints every interval and once more when ctx is done
+func (l *Listener) checkpoint(ctx context.Context) {
+	ticker := time.NewTicker(l.config.CheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.save(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			l.save(ctx)
+		}
+	}
+}
+
+func (l *Listener) save(ctx context.Context) {
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return
+	}
+	checkpoints := maps.Clone(l.checkpoints)
+	l.dirty = false
+	l.mu.Unlock()
+
+	if err := l.config.Store.Save(ctx, checkpoints); err != nil {
+		zap.L().Error("Failed to save change feed checkpoints", zap.Error(err))
+		l.mu.Lock()
+		l.dirty = true
+		l.mu.Unlock()
+	}
+}
+
+func (l *Listener) snapshot() Checkpoints {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return maps.Clone(l.checkpoints)
+}
+
+// MemoryCheckpointStore keeps the checkpoints in process memory, the listener starts over on every start
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints Checkpoints
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{}
+}
+
+func (s *MemoryCheckpointStore) Load(_ context.Context) (Checkpoints, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return maps.Clone(s.checkpoints), nil
+}
+
+func (s *MemoryCheckpointStore) Save(_ context.Context, checkpoints Checkpoints) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints = maps.Clone(checkpoints)
+	return nil
+}
@@ -0,0 +1,103 @@
+package changefeed_test
+
+import (
+	"context"
+	"golang-fiber-poc/infra/memory"
+	"golang-fiber-poc/pkg/changefeed"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// received collects the keys of the changes a subscriber receives
+type received struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *received) subscriber(_ context.Context, change changefeed.Change) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, change.Key)
+}
+
+// wait waits until count keys were received and returns them
+func (r *received) wait(t *testing.T, count int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		keys := append([]string(nil), r.keys...)
+		r.mu.Unlock()
+		if len(keys) >= count {
+			return keys
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("received %d changes, want %d", len(r.keys), count)
+	return nil
+}
+
+// listen runs a listener on source until the returned function is called
+func listen(source changefeed.Source, store changefeed.CheckpointStore, subscribers ...changefeed.Subscriber) func() {
+	listener := changefeed.NewListener(source, changefeed.Config{
+		Store: store,
+		Skip: func(key string) bool {
+			return strings.HasPrefix(key, "outbox::")
+		},
+		CheckpointInterval: time.Hour,
+	})
+	for _, subscriber := range subscribers {
+		listener.Subscribe(subscriber)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		listener.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	source := memory.NewChangeSource(4)
+	source.Mutate("1", []byte(`{"name":"chair"}`))
+	source.Mutate("outbox::1", []byte(`{}`))
+	source.Delete("1")
+
+	var first, second received
+	stop := listen(source, changefeed.NewMemoryCheckpointStore(), first.subscriber, second.subscriber)
+	defer stop()
+
+	for _, r := range []*received{&first, &second} {
+		if keys := r.wait(t, 2); len(keys) != 2 || keys[0] != "1" || keys[1] != "1" {
+			t.Fatalf("keys %v, want the mutation and the deletion of 1 without the skipped key", keys)
+		}
+	}
+}
+
+func TestResumeFromCheckpoints(t *testing.T) {
+	source := memory.NewChangeSource(4)
+	store := changefeed.NewMemoryCheckpointStore()
+	source.Mutate("1", []byte(`{}`))
+
+	var before received
+	stop := listen(source, store, before.subscriber)
+	before.wait(t, 1)
+	// Stopping saves the checkpoints
+	stop()
+
+	source.Mutate("2", []byte(`{}`))
+	var after received
+	stop = listen(source, store, after.subscriber)
+	defer stop()
+
+	if keys := after.wait(t, 1); len(keys) != 1 || keys[0] != "2" {
+		t.Fatalf("keys %v, want only the change after the checkpoints", keys)
+	}
+}
@@ -0,0 +1,98 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+// Breaker is a circuit breaker whose settings can be replaced. Replacing them closes the circuit and clears
+// its counts.
+type Breaker struct {
+	defaults CircuitBreakerConfig
+	current  atomic.Pointer[gobreaker.CircuitBreaker]
+	config   CircuitBreakerConfig
+}
+
+func (b *Breaker) Execute(req func() (interface{}, error)) (interface{}, error) {
+	return b.current.Load().Execute(req)
+}
+
+func (b *Breaker) State() gobreaker.State {
+	return b.current.Load().State()
+}
+
+func (b *Breaker) Name() string {
+	return b.defaults.Name
+}
+
+// Registry holds the circuit breakers by name so that their settings can be changed while they are used
+type Registry struct {
+	mu        sync.Mutex
+	breakers  map[string]*Breaker
+	overrides map[string]CircuitBreakerConfig
+}
+
+func NewRegistry() *Registry {
+	return &Registry{breakers: make(map[string]*Breaker)}
+}
+
+// Register adds a breaker with its default settings, the ones given to Update for its name override them.
+// Registering a name again returns the same breaker.
+func (r *Registry) Register(defaults CircuitBreakerConfig) *Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if breaker, ok := r.breakers[defaults.Name]; ok {
+		return breaker
+	}
+	breaker := &Breaker{defaults: defaults}
+	r.apply(breaker)
+	r.breakers[defaults.Name] = breaker
+	return breaker
+}
+
+// Update overrides the settings of the breakers by name, fields that are zero keep the defaults. Breakers
+// whose settings do not change keep their state.
+func (r *Registry) Update(overrides map[string]CircuitBreakerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.overrides = overrides
+	for _, breaker := range r.breakers {
+		r.apply(breaker)
+	}
+}
+
+func (r *Registry) apply(breaker *Breaker) {
+	config := merge(breaker.defaults, r.overrides[breaker.defaults.Name])
+	if breaker.current.Load() != nil && config == breaker.config {
+		return
+	}
+	if breaker.current.Load() != nil {
+		zap.L().Info("CircuitBreaker settings changed", zap.String("name", config.Name))
+	}
+	breaker.config = config
+	breaker.current.Store(NewCircuitBreaker(config))
+}
+
+func merge(config CircuitBreakerConfig, override CircuitBreakerConfig) CircuitBreakerConfig {
+	if override.MaxRequests > 0 {
+		config.MaxRequests = override.MaxRequests
+	}
+	if override.Interval > 0 {
+		config.Interval = override.Interval
+	}
+	if override.Timeout > 0 {
+		config.Timeout = override.Timeout
+	}
+	if override.RequestsVolumeThreshold > 0 {
+		config.RequestsVolumeThreshold = override.RequestsVolumeThreshold
+	}
+	if override.FailureThreshold > 0 {
+		config.FailureThreshold = override.FailureThreshold
+	}
+	return config
+}
@@ -0,0 +1,18 @@
+package codec
+
+import "github.com/fxamacker/cbor/v2"
+
+// CBOR falls back to the json struct tags when a field has no cbor tag
+type CBOR struct{}
+
+func (CBOR) ContentType() string {
+	return "application/cbor"
+}
+
+func (CBOR) Marshal(v any) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+func (CBOR) Unmarshal(data []byte, v any) error {
+	return cbor.Unmarshal(data, v)
+}
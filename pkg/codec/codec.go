@@ -0,0 +1,71 @@
+package codec
+
+import (
+	"errors"
+	"mime"
+	"strings"
+)
+
+// ErrUnsupportedType is returned by codecs that cannot encode or decode the given value
+var ErrUnsupportedType = errors.New("type is not supported by this codec")
+
+type Codec interface {
+	// ContentType is the media type the codec reads and writes
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Selective is implemented by codecs that only encode some types
+type Selective interface {
+	// Supports reports whether values of the type of v can be marshaled, v may be a nil pointer
+	Supports(v any) bool
+}
+
+type Registry struct {
+	codecs []Codec
+}
+
+// NewRegistry creates a registry of codecs. The first codec is the default for requests without Accept.
+func NewRegistry(codecs ...Codec) *Registry {
+	return &Registry{codecs: codecs}
+}
+
+// Default serves JSON first, then the binary formats
+var Default = NewRegistry(JSON{}, MessagePack{}, CBOR{}, Protobuf{})
+
+// ContentTypes lists the media types in order of preference
+func (r *Registry) ContentTypes() []string {
+	contentTypes := make([]string, len(r.codecs))
+	for i, codec := range r.codecs {
+		contentTypes[i] = codec.ContentType()
+	}
+	return contentTypes
+}
+
+// ContentTypesFor lists the media types, in order of preference, whose codec can marshal values of the type of v
+func (r *Registry) ContentTypesFor(v any) []string {
+	contentTypes := make([]string, 0, len(r.codecs))
+	for _, codec := range r.codecs {
+		if selective, ok := codec.(Selective); ok && !selective.Supports(v) {
+			continue
+		}
+		contentTypes = append(contentTypes, codec.ContentType())
+	}
+	return contentTypes
+}
+
+// ForContentType returns the codec of a Content-Type header, ignoring its parameters
+func (r *Registry) ForContentType(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, codec := range r.codecs {
+		if strings.EqualFold(codec.ContentType(), mediaType) {
+			return codec, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,32 @@
+package codec
+
+import (
+	"encoding/json"
+
+	gojson "github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// JSON uses encoding/json. Handlers write JSON through fiber, which uses the encoder from fiber.Config.
+type JSON struct{}
+
+func (JSON) ContentType() string {
+	return "application/json"
+}
+
+func (JSON) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// JSONEncoder returns the encoder and decoder for fiber.Config. "goccy" selects github.com/goccy/go-json,
+// a drop-in replacement that is noticeably faster, anything else keeps encoding/json.
+func JSONEncoder(name string) (utils.JSONMarshal, utils.JSONUnmarshal) {
+	if name == "goccy" {
+		return gojson.Marshal, gojson.Unmarshal
+	}
+	return json.Marshal, json.Unmarshal
+}
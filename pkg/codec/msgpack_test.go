@@ -0,0 +1,31 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMessagePackKeepsZeroValues(t *testing.T) {
+	type product struct {
+		ID       string `json:"id"`
+		Stock    int    `json:"stock"`
+		Active   bool   `json:"active"`
+		Category string `json:"category,omitempty"`
+	}
+
+	data, err := MessagePack{}.Marshal(product{ID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err = msgpack.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{"id": "1", "stock": int8(0), "active": false}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("fields %#v, want %#v like JSON", fields, want)
+	}
+}
@@ -0,0 +1,52 @@
+package codec
+
+import "google.golang.org/protobuf/proto"
+
+// ProtoMarshaler is implemented by types that convert themselves to a generated protobuf message
+type ProtoMarshaler interface {
+	MarshalProto() ([]byte, error)
+}
+
+// ProtoUnmarshaler is implemented by types that fill themselves from a generated protobuf message
+type ProtoUnmarshaler interface {
+	UnmarshalProto(data []byte) error
+}
+
+// Protobuf only handles generated messages and types implementing ProtoMarshaler or ProtoUnmarshaler,
+// everything else is ErrUnsupportedType
+type Protobuf struct{}
+
+func (Protobuf) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (Protobuf) Supports(v any) bool {
+	switch v.(type) {
+	case ProtoMarshaler, proto.Message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (Protobuf) Marshal(v any) ([]byte, error) {
+	switch m := v.(type) {
+	case ProtoMarshaler:
+		return m.MarshalProto()
+	case proto.Message:
+		return proto.Marshal(m)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+func (Protobuf) Unmarshal(data []byte, v any) error {
+	switch m := v.(type) {
+	case ProtoUnmarshaler:
+		return m.UnmarshalProto(data)
+	case proto.Message:
+		return proto.Unmarshal(data, m)
+	default:
+		return ErrUnsupportedType
+	}
+}
@@ -1,44 +1,366 @@
 package config
 
 import (
-	"fmt"
-	"github.com/spf13/viper"
+	"time"
 )
 
 type AppConfig struct {
-	Port      string          `yaml:"port"`
-	Couchbase CouchbaseConfig `yaml:"couchbase"`
-	Jaeger    JaegerConfig    `yaml:"jaeger"`
+	Port        string            `yaml:"port" mapstructure:"port"`
+	Server      ServerConfig      `yaml:"server" mapstructure:"server"`
+	GRPC        GRPCConfig        `yaml:"grpc" mapstructure:"grpc"`
+	Couchbase   CouchbaseConfig   `yaml:"couchbase" mapstructure:"couchbase"`
+	Jaeger      JaegerConfig      `yaml:"jaeger" mapstructure:"jaeger"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" mapstructure:"idempotency"`
+	Bulk        BulkConfig        `yaml:"bulk" mapstructure:"bulk"`
+	Pagination  PaginationConfig  `yaml:"pagination" mapstructure:"pagination"`
+	GraphQL     GraphQLConfig     `yaml:"graphql" mapstructure:"graphql"`
+	Stream      StreamConfig      `yaml:"stream" mapstructure:"stream"`
+	Broker      BrokerConfig      `yaml:"broker" mapstructure:"broker"`
+	Outbox      OutboxConfig      `yaml:"outbox" mapstructure:"outbox"`
+	Consumer    ConsumerConfig    `yaml:"consumer" mapstructure:"consumer"`
+	ChangeFeed  ChangeFeedConfig  `yaml:"changefeed" mapstructure:"changefeed"`
+	Cache       CacheConfig       `yaml:"cache" mapstructure:"cache"`
+	Shutdown    ShutdownConfig    `yaml:"shutdown" mapstructure:"shutdown"`
+	// The settings below are reloaded while running, see Watcher
+	Log             LogConfig                       `yaml:"log" mapstructure:"log"`
+	Auth            AuthConfig                      `yaml:"auth" mapstructure:"auth"`
+	RateLimit       RateLimitConfig                 `yaml:"ratelimit" mapstructure:"ratelimit"`
+	CircuitBreakers map[string]CircuitBreakerConfig `yaml:"circuitbreakers" mapstructure:"circuitbreakers"`
+	Secrets         SecretsConfig                   `yaml:"secrets" mapstructure:"secrets"`
+	Features        FeaturesConfig                  `yaml:"features" mapstructure:"features"`
+	Tenancy         TenancyConfig                   `yaml:"tenancy" mapstructure:"tenancy"`
 }
 
+// TenancyConfig resolves the tenant of the product API requests, the tenants are those of couchbase.tenants.
+// Only the tenant overrides are reloaded.
+type TenancyConfig struct {
+	// Header carries the tenant id, "" disables it
+	Header string `yaml:"header" mapstructure:"header"`
+	// Domain resolves the tenant of the requests to <tenant>.<domain>, "" disables it
+	Domain string `yaml:"domain" mapstructure:"domain"`
+	// Claim of the bearer JWT in TokenHeader carries the tenant, the JWT is signed with HS256 and Secret
+	Claim       string `yaml:"claim" mapstructure:"claim"`
+	TokenHeader string `yaml:"tokenheader" mapstructure:"tokenheader"`
+	Secret      Secret `yaml:"secret" mapstructure:"secret"`
+	// Required rejects the requests without a tenant
+	Required bool `yaml:"required" mapstructure:"required"`
+	// Tenants overrides settings for the requests of a tenant
+	Tenants map[string]TenantConfig `yaml:"tenants" mapstructure:"tenants"`
+}
+
+type TenantConfig struct {
+	// RateLimit replaces ratelimit for the clients of the tenant, counted apart from other tenants
+	RateLimit *RateLimitConfig `yaml:"ratelimit" mapstructure:"ratelimit"`
+	// Features replaces the flags of features by name
+	Features map[string]FeatureFlagConfig `yaml:"features" mapstructure:"features"`
+}
+
+type FeaturesConfig struct {
+	// Flags are defined by name, the flags of Remote replace them
+	Flags  map[string]FeatureFlagConfig `yaml:"flags" mapstructure:"flags"`
+	Remote RemoteFeaturesConfig         `yaml:"remote" mapstructure:"remote"`
+}
+
+type FeatureFlagConfig struct {
+	// Enabled is the value for the subjects that no rule matches
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Rollout limits Enabled to a percentage of the subjects, 0 turns it off for all of them
+	//
+	// Optional. Default: 100
+	Rollout *int                `yaml:"rollout" mapstructure:"rollout"`
+	Rules   []FeatureRuleConfig `yaml:"rules" mapstructure:"rules"`
+}
+
+// FeatureRuleConfig matches the subjects that meet all of its conditions
+type FeatureRuleConfig struct {
+	Principals []string `yaml:"principals" mapstructure:"principals"`
+	Tenants    []string `yaml:"tenants" mapstructure:"tenants"`
+	Header     string   `yaml:"header" mapstructure:"header"`
+	Values     []string `yaml:"values" mapstructure:"values"`
+	Enabled    bool     `yaml:"enabled" mapstructure:"enabled"`
+	// Optional. Default: 100
+	Rollout *int `yaml:"rollout" mapstructure:"rollout"`
+}
+
+type RemoteFeaturesConfig struct {
+	// URL answers the flags as a JSON object, remote flags are disabled when it is empty
+	URL      string        `yaml:"url" mapstructure:"url"`
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+}
+
+type LogConfig struct {
+	// Level is "debug", "info", "warn" or "error"
+	Level string `yaml:"level" mapstructure:"level"`
+	// Format is "json" or "console"
+	Format string `yaml:"format" mapstructure:"format"`
+	// Outputs are "stdout", "stderr" or the paths of files, which are rotated
+	Outputs  []string          `yaml:"outputs" mapstructure:"outputs"`
+	Rotation LogRotationConfig `yaml:"rotation" mapstructure:"rotation"`
+	Sampling LogSamplingConfig `yaml:"sampling" mapstructure:"sampling"`
+	// Packages sets the level of packages, like "infra/couchbase" or "outbox"
+	Packages map[string]string `yaml:"packages" mapstructure:"packages"`
+	// Redact replaces the values of the fields with these keys
+	Redact []string `yaml:"redact" mapstructure:"redact"`
+	// OTel exports the logs to the OpenTelemetry collector of jaeger.url, next to the traces
+	OTel   bool            `yaml:"otel" mapstructure:"otel"`
+	Access AccessLogConfig `yaml:"access" mapstructure:"access"`
+}
+
+type LogRotationConfig struct {
+	// MaxSize is the size in megabytes a file is rotated at
+	MaxSize int `yaml:"maxsize" mapstructure:"maxsize"`
+	// MaxAge is the number of days rotated files are kept, 0 keeps them
+	MaxAge int `yaml:"maxage" mapstructure:"maxage"`
+	// MaxBackups is the number of rotated files kept, 0 keeps them
+	MaxBackups int  `yaml:"maxbackups" mapstructure:"maxbackups"`
+	Compress   bool `yaml:"compress" mapstructure:"compress"`
+}
+
+// LogSamplingConfig logs the first Initial entries with the same level and message every second, then every
+// Thereafter-th of them. 0 disables sampling.
+type LogSamplingConfig struct {
+	Initial    int `yaml:"initial" mapstructure:"initial"`
+	Thereafter int `yaml:"thereafter" mapstructure:"thereafter"`
+}
+
+// AccessLogConfig logs a line per HTTP request, failed and slow requests are always logged
+type AccessLogConfig struct {
+	// SampleRate is the share of the successful requests that are logged, from 0 to 1
+	SampleRate float64 `yaml:"samplerate" mapstructure:"samplerate"`
+	// SlowThreshold makes requests that take longer slow, 0 disables it
+	SlowThreshold time.Duration `yaml:"slowthreshold" mapstructure:"slowthreshold"`
+}
+
+type AuthConfig struct {
+	// Users may call the product API with basic auth
+	Users []UserConfig `yaml:"users" mapstructure:"users"`
+}
+
+type UserConfig struct {
+	Username string `yaml:"username" mapstructure:"username"`
+	Password Secret `yaml:"password" mapstructure:"password"`
+	// Tenants are the tenants of couchbase.tenants the user may act for, "*" for all of them. Users without
+	// tenants only use the shared storage.
+	Tenants []string `yaml:"tenants" mapstructure:"tenants"`
+	// Admin lets the user change the log levels
+	Admin bool `yaml:"admin" mapstructure:"admin"`
+}
+
+type RateLimitConfig struct {
+	// Max is the number of requests a client may send to the product API per Expiration, 0 disables the limit
+	Max        int           `yaml:"max" mapstructure:"max"`
+	Expiration time.Duration `yaml:"expiration" mapstructure:"expiration"`
+}
+
+// CircuitBreakerConfig overrides the settings of the circuit breaker with the same name, fields that are not set
+// keep the defaults of the breaker
+type CircuitBreakerConfig struct {
+	MaxRequests             uint32        `yaml:"maxrequests" mapstructure:"maxrequests"`
+	Interval                time.Duration `yaml:"interval" mapstructure:"interval"`
+	Timeout                 time.Duration `yaml:"timeout" mapstructure:"timeout"`
+	RequestsVolumeThreshold uint32        `yaml:"requestsvolumethreshold" mapstructure:"requestsvolumethreshold"`
+	FailureThreshold        float64       `yaml:"failurethreshold" mapstructure:"failurethreshold"`
+}
+
+type ShutdownConfig struct {
+	// DrainDelay is waited between withdrawing readiness and closing the listeners, so that load
+	// balancers stop sending requests first
+	DrainDelay time.Duration `yaml:"draindelay" mapstructure:"draindelay"`
+}
+
+type ServerConfig struct {
+	// JSON selects the JSON library, "goccy" for github.com/goccy/go-json or "std" for encoding/json
+	JSON string `yaml:"json" mapstructure:"json"`
+}
+
+type GRPCConfig struct {
+	Port string `yaml:"port" mapstructure:"port"`
+}
+
+// Entities stored in the bucket, the keys of couchbase.collections
+const (
+	EntityProducts    = "products"
+	EntityOutbox      = "outbox"
+	EntityIdempotency = "idempotency"
+	EntityProcessed   = "processed"
+	EntityCheckpoints = "checkpoints"
+)
+
+var Entities = []string{EntityProducts, EntityOutbox, EntityIdempotency, EntityProcessed, EntityCheckpoints}
+
 type CouchbaseConfig struct {
-	URL      string `yaml:"url"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Bucket   string `yaml:"bucket"`
+	URL      string `yaml:"url" mapstructure:"url"`
+	Username string `yaml:"username" mapstructure:"username"`
+	Password Secret `yaml:"password" mapstructure:"password"`
+	Bucket   string `yaml:"bucket" mapstructure:"bucket"`
+	// SearchIndex is the full text search index used by product search
+	SearchIndex string `yaml:"searchindex" mapstructure:"searchindex"`
+	// Collections maps an entity, one of Entities, to its "scope.collection", entities that are not listed
+	// use the default collection
+	Collections map[string]string `yaml:"collections" mapstructure:"collections"`
+	// AutoCreate creates the missing scopes, collections and indexes at startup, which needs an admin user
+	AutoCreate bool `yaml:"autocreate" mapstructure:"autocreate"`
+	// Tenants maps a tenant to the scope that holds its products collection
+	Tenants    map[string]string         `yaml:"tenants" mapstructure:"tenants"`
+	Operations CouchbaseOperationsConfig `yaml:"operations" mapstructure:"operations"`
+}
+
+// CouchbaseOperationsConfig tunes the repository operations, fields that are not set keep their defaults
+type CouchbaseOperationsConfig struct {
+	Get    CouchbaseOperationConfig `yaml:"get" mapstructure:"get"`
+	Create CouchbaseOperationConfig `yaml:"create" mapstructure:"create"`
+	Update CouchbaseOperationConfig `yaml:"update" mapstructure:"update"`
+	// Bulk durability only applies to atomic bulk writes, which run in a transaction
+	Bulk   CouchbaseOperationConfig `yaml:"bulk" mapstructure:"bulk"`
+	Search CouchbaseOperationConfig `yaml:"search" mapstructure:"search"`
+	// Outbox is the query that reads the pending outbox records
+	Outbox CouchbaseOperationConfig `yaml:"outbox" mapstructure:"outbox"`
+}
+
+type CouchbaseOperationConfig struct {
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+	// Durability of writes is "none", "majority", "majorityandpersistactive" or "persisttomajority"
+	Durability string `yaml:"durability" mapstructure:"durability"`
+	// ReplicaFallback reads a replica when the active copy times out, only for get
+	ReplicaFallback bool `yaml:"replicafallback" mapstructure:"replicafallback"`
+	// ScanConsistency of queries is "notbounded" or "requestplus"
+	ScanConsistency string `yaml:"scanconsistency" mapstructure:"scanconsistency"`
 }
 
 type JaegerConfig struct {
-	URL string `yaml:"url"`
+	URL string `yaml:"url" mapstructure:"url"`
+}
+
+type IdempotencyConfig struct {
+	// Store is either "memory" or "couchbase"
+	Store    string        `yaml:"store" mapstructure:"store"`
+	Lifetime time.Duration `yaml:"lifetime" mapstructure:"lifetime"`
+	// Wait is how long a duplicate request waits for the original one before getting 409
+	Wait time.Duration `yaml:"wait" mapstructure:"wait"`
+}
+
+type BulkConfig struct {
+	// BatchSize is the number of items sent to Couchbase in one batched call
+	BatchSize int `yaml:"batchsize" mapstructure:"batchsize"`
+	// Concurrency is the number of batches of a request written at the same time
+	Concurrency int `yaml:"concurrency" mapstructure:"concurrency"`
+	// MaxAtomicItems is the largest number of items of an all-or-nothing request, which are read whole and
+	// written in one transaction
+	MaxAtomicItems int `yaml:"maxatomicitems" mapstructure:"maxatomicitems"`
 }
 
-func Read() *AppConfig {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("$PWD/config")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("/config")
-	viper.AddConfigPath("./config")
-	err := viper.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("fatal error config file: %w", err))
+// WithDefaults fills in the limits that are not configured
+func (c BulkConfig) WithDefaults() BulkConfig {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
 	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 4
+	}
+	if c.MaxAtomicItems <= 0 {
+		c.MaxAtomicItems = 1000
+	}
+	return c
+}
+
+type PaginationConfig struct {
+	// Secret signs pagination cursors. It must be the same on every instance
+	Secret Secret `yaml:"secret" mapstructure:"secret"`
+}
+
+type GraphQLConfig struct {
+	// MaxDepth and MaxComplexity bound the queries the endpoint accepts
+	MaxDepth      int `yaml:"maxdepth" mapstructure:"maxdepth"`
+	MaxComplexity int `yaml:"maxcomplexity" mapstructure:"maxcomplexity"`
+	// PersistedQueries is the number of automatic persisted queries kept in memory
+	PersistedQueries int `yaml:"persistedqueries" mapstructure:"persistedqueries"`
+}
+
+type StreamConfig struct {
+	// History is the number of recent product changes kept for clients resuming with Last-Event-ID
+	History int `yaml:"history" mapstructure:"history"`
+	// Buffer is the number of changes queued for a client before it is dropped as too slow
+	Buffer int `yaml:"buffer" mapstructure:"buffer"`
+	// Heartbeat is the interval of keep-alive comments and pings on idle streams
+	Heartbeat time.Duration `yaml:"heartbeat" mapstructure:"heartbeat"`
+	// Retry is the reconnection delay suggested to SSE clients
+	Retry        time.Duration `yaml:"retry" mapstructure:"retry"`
+	WriteTimeout time.Duration `yaml:"writetimeout" mapstructure:"writetimeout"`
+}
 
-	var appConfig AppConfig
-	err = viper.Unmarshal(&appConfig)
-	if err != nil {
-		panic(fmt.Errorf("fatal error config file: %w", err))
+// WithDefaults fills in the settings that are not configured
+func (c StreamConfig) WithDefaults() StreamConfig {
+	if c.History <= 0 {
+		c.History = 1000
 	}
+	if c.Buffer <= 0 {
+		c.Buffer = 64
+	}
+	if c.Heartbeat <= 0 {
+		c.Heartbeat = 15 * time.Second
+	}
+	if c.Retry <= 0 {
+		c.Retry = 3 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 5 * time.Second
+	}
+	return c
+}
+
+type BrokerConfig struct {
+	// Type is "memory", "kafka" or "nats"
+	Type  string      `yaml:"type" mapstructure:"type"`
+	Kafka KafkaConfig `yaml:"kafka" mapstructure:"kafka"`
+	NATS  NATSConfig  `yaml:"nats" mapstructure:"nats"`
+}
+
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers" mapstructure:"brokers"`
+}
+
+type NATSConfig struct {
+	URL string `yaml:"url" mapstructure:"url"`
+}
+
+type OutboxConfig struct {
+	// Topic receives the product events
+	Topic     string `yaml:"topic" mapstructure:"topic"`
+	BatchSize int    `yaml:"batchsize" mapstructure:"batchsize"`
+	// Interval is the pause between polls of the outbox
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+	// MaxBackoff caps the delay between attempts of an event that fails to publish
+	MaxBackoff time.Duration `yaml:"maxbackoff" mapstructure:"maxbackoff"`
+}
+
+type ConsumerConfig struct {
+	// Topic receives product changes from upstream systems, the consumer is disabled when it is empty
+	Topic string `yaml:"topic" mapstructure:"topic"`
+	Group string `yaml:"group" mapstructure:"group"`
+	// RetryDelays has the delay of every retry topic, failed messages go to the dead letter topic after the last one
+	RetryDelays     []time.Duration `yaml:"retrydelays" mapstructure:"retrydelays"`
+	DeadLetterTopic string          `yaml:"deadlettertopic" mapstructure:"deadlettertopic"`
+	// Store is either "memory" or "couchbase"
+	Store             string        `yaml:"store" mapstructure:"store"`
+	ProcessedLifetime time.Duration `yaml:"processedlifetime" mapstructure:"processedlifetime"`
+}
+
+type ChangeFeedConfig struct {
+	// Enabled streams the changes of the bucket over DCP, which then feeds the change stream and the read cache
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Name names the DCP connections and the checkpoint documents
+	Name string `yaml:"name" mapstructure:"name"`
+	// Instance tells the checkpoint documents of the replicas apart, the hostname when it is empty
+	Instance string `yaml:"instance" mapstructure:"instance"`
+	// From is where a partition without a checkpoint starts, "now" or "beginning"
+	From string `yaml:"from" mapstructure:"from"`
+	// Checkpoints is either "memory" or "couchbase"
+	Checkpoints        string        `yaml:"checkpoints" mapstructure:"checkpoints"`
+	CheckpointInterval time.Duration `yaml:"checkpointinterval" mapstructure:"checkpointinterval"`
+}
 
-	return &appConfig
+type CacheConfig struct {
+	// Size is the number of products kept in memory, the cache is disabled when it is 0 or the change feed is
+	Size int           `yaml:"size" mapstructure:"size"`
+	TTL  time.Duration `yaml:"ttl" mapstructure:"ttl"`
 }
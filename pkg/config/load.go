@@ -0,0 +1,284 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes the environment variables of the settings, couchbase.bucket is APP_COUCHBASE_BUCKET
+const EnvPrefix = "APP"
+
+// LocalProfile is the profile of development runs, which may leave the secrets of other deployments unset
+const LocalProfile = "local"
+
+// Options select the sources of the configuration
+type Options struct {
+	// File is read instead of the config.yaml found in Paths
+	File string
+
+	// Profile merges config.<profile>.yaml over config.yaml. APP_PROFILE is used when it is empty.
+	Profile string
+
+	// Paths are searched for config.yaml and the profile file
+	//
+	// Optional. Default: ./config, ., /config
+	Paths []string
+
+	// PrintConfig asks for the effective configuration to be printed instead of starting the application
+	PrintConfig bool
+
+	flags *pflag.FlagSet
+	sets  []string
+}
+
+// ParseFlags reads the command line, args do not include the program name
+func ParseFlags(args []string) (Options, error) {
+	var options Options
+	flags := pflag.NewFlagSet("golang-fiber-poc", pflag.ContinueOnError)
+	flags.StringVar(&options.File, "config", "", "config file to read instead of config.yaml")
+	flags.StringVar(&options.Profile, "profile", "", "profile whose config.<profile>.yaml is merged over config.yaml (default $APP_PROFILE)")
+	flags.BoolVar(&options.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	flags.String("port", "", "port of the HTTP server")
+	flags.String("grpc-port", "", "port of the gRPC server")
+	flags.StringArrayVar(&options.sets, "set", nil, "set a key, e.g. --set couchbase.bucket=products")
+	if err := flags.Parse(args); err != nil {
+		return options, err
+	}
+	options.flags = flags
+	return options, nil
+}
+
+// Load layers the configuration: the defaults, config.yaml, the profile file, the environment and the flags,
+// each overriding the previous ones. Settings that are invalid are reported together in a *ValidationError,
+// which comes with the config so that it can still be printed.
+func Load(options Options) (*AppConfig, error) {
+	files, err := options.files()
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	setDefaults(v, "", reflect.ValueOf(Defaults()))
+
+	for _, file := range files {
+		v.SetConfigFile(file)
+		if err = v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file %s: %w", file, err)
+		}
+	}
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	bindLegacyEnv(v)
+
+	if options.flags != nil {
+		_ = v.BindPFlag("port", options.flags.Lookup("port"))
+		_ = v.BindPFlag("grpc.port", options.flags.Lookup("grpc-port"))
+	}
+	for _, set := range options.sets {
+		key, value, found := strings.Cut(set, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("--set %s: expected key=value", set)
+		}
+		v.Set(strings.ToLower(key), value)
+	}
+
+	// mapstructure decodes the other settings when one fails, they are validated too so that every problem is reported
+	var appConfig AppConfig
+	validationErr := &ValidationError{}
+	err = v.Unmarshal(&appConfig, func(decoderConfig *mapstructure.DecoderConfig) {
+		decoderConfig.ErrorUnused = true
+	})
+	if err != nil {
+		validationErr = decodeError(err)
+	}
+	for _, problem := range resolveSecrets(&appConfig).Problems {
+		if !validationErr.has(problem.Key) {
+			validationErr.Problems = append(validationErr.Problems, problem)
+		}
+	}
+	var invalid *ValidationError
+	if errors.As(appConfig.Validate(options.profile()), &invalid) {
+		for _, problem := range invalid.Problems {
+			if !validationErr.has(problem.Key) {
+				validationErr.Problems = append(validationErr.Problems, problem)
+			}
+		}
+	}
+	if len(validationErr.Problems) > 0 {
+		return &appConfig, validationErr
+	}
+	return &appConfig, nil
+}
+
+// Defaults are the settings that neither the files, the environment nor the flags set
+func Defaults() AppConfig {
+	return AppConfig{
+		Port:   "8080",
+		Server: ServerConfig{JSON: "goccy"},
+		GRPC:   GRPCConfig{Port: "50051"},
+		Couchbase: CouchbaseConfig{
+			URL:         "couchbase://localhost",
+			Username:    "Administrator",
+			Bucket:      "products",
+			SearchIndex: "products-search",
+		},
+		Jaeger:      JaegerConfig{URL: "localhost:4318"},
+		Idempotency: IdempotencyConfig{Store: "memory", Lifetime: 24 * time.Hour, Wait: 2 * time.Second},
+		Bulk:        BulkConfig{}.WithDefaults(),
+		GraphQL:     GraphQLConfig{MaxDepth: 10, MaxComplexity: 1000, PersistedQueries: 1000},
+		Stream:      StreamConfig{}.WithDefaults(),
+		Broker: BrokerConfig{
+			Type:  "memory",
+			Kafka: KafkaConfig{Brokers: []string{"localhost:9092"}},
+			NATS:  NATSConfig{URL: "nats://localhost:4222"},
+		},
+		Outbox: OutboxConfig{Topic: "products.events", BatchSize: 100, Interval: time.Second, MaxBackoff: time.Minute},
+		Consumer: ConsumerConfig{
+			Group:             "golang-fiber-poc",
+			RetryDelays:       []time.Duration{10 * time.Second, time.Minute, 10 * time.Minute},
+			Store:             "memory",
+			ProcessedLifetime: 24 * time.Hour,
+		},
+		ChangeFeed: ChangeFeedConfig{Name: "golang-fiber-poc", From: "now", Checkpoints: "memory", CheckpointInterval: 5 * time.Second},
+		Cache:      CacheConfig{Size: 10000, TTL: 5 * time.Minute},
+		Log: LogConfig{
+			Level:    "info",
+			Format:   "json",
+			Outputs:  []string{"stderr"},
+			Rotation: LogRotationConfig{MaxSize: 100},
+			Redact:   []string{"password", "token", "secret", "authorization", "cookie"},
+			Access:   AccessLogConfig{SampleRate: 1, SlowThreshold: time.Second},
+		},
+		RateLimit: RateLimitConfig{Expiration: time.Minute},
+		Features:  FeaturesConfig{Remote: RemoteFeaturesConfig{Interval: 30 * time.Second}},
+		Tenancy:   TenancyConfig{Header: "X-Tenant-ID", TokenHeader: "X-Tenant-Token"},
+	}
+}
+
+// setDefaults registers every key, which is also what lets viper find it in the environment
+func setDefaults(v *viper.Viper, prefix string, value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+			setDefaults(v, key+".", value.Field(i))
+			continue
+		}
+		if field.Type.Kind() == reflect.Map && value.Field(i).IsNil() {
+			continue
+		}
+		v.SetDefault(key, value.Field(i).Interface())
+	}
+}
+
+// bindLegacyEnv keeps the environment variables used before the APP_ prefix, the prefixed ones win
+func bindLegacyEnv(v *viper.Viper) {
+	_ = v.BindEnv("couchbase.username", EnvPrefix+"_COUCHBASE_USERNAME", "COUCHBASE_USERNAME")
+	_ = v.BindEnv("couchbase.password", EnvPrefix+"_COUCHBASE_PASSWORD", "COUCHBASE_PASSWORD")
+
+	if _, ok := os.LookupEnv(EnvPrefix + "_COUCHBASE_URL"); !ok {
+		if host := os.Getenv("COUCHBASE_HOST"); host != "" {
+			v.Set("couchbase.url", "couchbase://"+host)
+		}
+	}
+	if _, ok := os.LookupEnv(EnvPrefix + "_JAEGER_URL"); !ok {
+		if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+			endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://")
+			v.Set("jaeger.url", strings.TrimSuffix(endpoint, "/"))
+		} else if host := os.Getenv("JAEGER_AGENT_HOST"); host != "" {
+			// The agent port is the UDP one of the Jaeger agent, spans go to the OTLP port of the collector
+			_, port, err := net.SplitHostPort(v.GetString("jaeger.url"))
+			if err != nil {
+				port = "4318"
+			}
+			v.Set("jaeger.url", net.JoinHostPort(host, port))
+		}
+	}
+}
+
+// files returns config.yaml, when there is one, and the file of the profile
+func (o Options) files() ([]string, error) {
+	paths := o.Paths
+	if len(paths) == 0 {
+		paths = []string{"./config", ".", "/config"}
+	}
+
+	var files []string
+	if o.File != "" {
+		files = append(files, filepath.Clean(o.File))
+		paths = []string{filepath.Dir(o.File)}
+	} else if file := find(paths, "config.yaml"); file != "" {
+		files = append(files, file)
+	}
+
+	if profile := o.profile(); profile != "" {
+		name := "config." + profile + ".yaml"
+		file := find(paths, name)
+		if file == "" {
+			return nil, fmt.Errorf("profile %s: no %s in %s", profile, name, strings.Join(paths, ", "))
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// profile is the profile of the flags or the environment
+func (o Options) profile() string {
+	if o.Profile != "" {
+		return o.Profile
+	}
+	return os.Getenv(EnvPrefix + "_PROFILE")
+}
+
+func find(paths []string, name string) string {
+	for _, path := range paths {
+		file := filepath.Join(path, name)
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file
+		}
+	}
+	return ""
+}
+
+// decodeError turns the errors of mapstructure, like a duration that does not parse or an unknown key, into problems
+func decodeError(err error) *ValidationError {
+	validationErr := &ValidationError{}
+	var mapstructureErr *mapstructure.Error
+	if !errors.As(err, &mapstructureErr) {
+		validationErr.add("config", "%s", err)
+		return validationErr
+	}
+
+	for _, message := range mapstructureErr.Errors {
+		key, rest := "", strings.TrimPrefix(message, "error decoding ")
+		if strings.HasPrefix(rest, "'") {
+			if end := strings.Index(rest[1:], "'"); end >= 0 {
+				key, rest = rest[1:end+1], strings.TrimSpace(strings.TrimPrefix(rest[end+2:], ":"))
+			}
+		}
+		if unknown, found := strings.CutPrefix(rest, "has invalid keys: "); found {
+			for _, name := range strings.Split(unknown, ", ") {
+				validationErr.add(strings.TrimPrefix(key+"."+name, "."), "unknown key")
+			}
+			continue
+		}
+		if key == "" {
+			key = "config"
+		}
+		validationErr.add(key, "%s", rest)
+	}
+	return validationErr
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Print writes the configuration as YAML, in the layout of config.yaml, with the secrets redacted
+func Print(w io.Writer, appConfig *AppConfig) error {
+	node, err := toNode(reflect.ValueOf(*appConfig))
+	if err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func toNode(value reflect.Value) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	switch {
+	case value.Type() == secretType:
+		return node, node.Encode(value.Interface().(Secret).String())
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		return node, node.Encode(time.Duration(value.Int()).String())
+	case value.Kind() == reflect.Slice:
+		node.Kind = yaml.SequenceNode
+		for i := 0; i < value.Len(); i++ {
+			item, err := toNode(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+		return node, nil
+	case value.Kind() != reflect.Struct:
+		return node, node.Encode(value.Interface())
+	}
+
+	node.Kind = yaml.MappingNode
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		item, err := toNode(value.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: field.Tag.Get("mapstructure")}
+		node.Content = append(node.Content, key, item)
+	}
+	return node, nil
+}
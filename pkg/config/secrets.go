@@ -0,0 +1,232 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the value of a Secret when it is printed, logged or marshalled
+const Redacted = "<redacted>"
+
+// Secret is a credential. In the config it is either the value or a reference resolved when the config is
+// loaded, and on every reload so that rotated secrets are picked up:
+//   - env://VAR reads an environment variable
+//   - secret:///run/secrets/name reads a file, like a Docker or Kubernetes secret
+//   - vault://path#key reads a key of a Vault secret, see VaultConfig
+//
+// Only Value returns the credential, formatting and marshalling it gives Redacted.
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type SecretsConfig struct {
+	// Refresh reloads the configuration periodically to pick up rotated secrets, 0 disables it
+	Refresh time.Duration `yaml:"refresh" mapstructure:"refresh"`
+	Vault   VaultConfig   `yaml:"vault" mapstructure:"vault"`
+}
+
+type VaultConfig struct {
+	// Address of the Vault server, vault://secret/data/couchbase#password reads the password key of the
+	// secret at secret/data/couchbase
+	Address string `yaml:"address" mapstructure:"address"`
+	// Token is read from VAULT_TOKEN when it is empty, it can be an env:// or secret:// reference
+	Token Secret `yaml:"token" mapstructure:"token"`
+	// Dir replaces the Vault server by files for local runs and tests, vault://path#key reads the key of the
+	// JSON object in <dir>/<path>.json
+	Dir string `yaml:"dir" mapstructure:"dir"`
+}
+
+// resolveSecrets replaces the references of the secrets of appConfig with their values
+func resolveSecrets(appConfig *AppConfig) *ValidationError {
+	validationErr := &ValidationError{}
+	resolver := &secretResolver{vaultSecrets: make(map[string]map[string]string)}
+
+	token, err := resolver.resolve(appConfig.Secrets.Vault.Token, "env", "secret")
+	if err != nil {
+		validationErr.add("secrets.vault.token", "%s", err)
+	}
+	if token == "" {
+		token = Secret(os.Getenv("VAULT_TOKEN"))
+	}
+	appConfig.Secrets.Vault.Token = token
+	resolver.vault = appConfig.Secrets.Vault
+
+	resolver.walk(validationErr, "", reflect.ValueOf(appConfig).Elem())
+	return validationErr
+}
+
+type secretResolver struct {
+	vault VaultConfig
+	// vaultSecrets caches the secrets read from Vault during one load
+	vaultSecrets map[string]map[string]string
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+func (r *secretResolver) walk(validationErr *ValidationError, key string, value reflect.Value) {
+	switch {
+	case value.Type() == secretType:
+		if key == "secrets.vault.token" {
+			return
+		}
+		secret, err := r.resolve(Secret(value.String()), "env", "secret", "vault")
+		if err != nil {
+			validationErr.add(key, "%s", err)
+			return
+		}
+		value.SetString(string(secret))
+	case value.Kind() == reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name := value.Type().Field(i).Tag.Get("mapstructure")
+			r.walk(validationErr, strings.TrimPrefix(key+"."+name, "."), value.Field(i))
+		}
+	case value.Kind() == reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			r.walk(validationErr, fmt.Sprintf("%s[%d]", key, i), value.Index(i))
+		}
+	}
+}
+
+// resolve returns the value of a reference with one of schemes, other values are returned as they are.
+// Errors name the reference but never the value.
+func (r *secretResolver) resolve(secret Secret, schemes ...string) (Secret, error) {
+	scheme, reference, found := strings.Cut(secret.Value(), "://")
+	if !found || !slices.Contains(schemes, scheme) {
+		return secret, nil
+	}
+
+	var value string
+	var err error
+	switch scheme {
+	case "env":
+		var ok bool
+		if value, ok = os.LookupEnv(reference); !ok {
+			err = errors.New("is not set")
+		}
+	case "secret":
+		var content []byte
+		if content, err = os.ReadFile(reference); err == nil {
+			value = strings.TrimRight(string(content), "\r\n")
+		}
+	case "vault":
+		value, err = r.readVault(reference)
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", secret.Value(), err)
+	}
+	return Secret(value), nil
+}
+
+func (r *secretResolver) readVault(reference string) (string, error) {
+	path, key, found := strings.Cut(reference, "#")
+	if !found || path == "" || key == "" {
+		return "", errors.New("expected vault://path#key")
+	}
+
+	secrets, ok := r.vaultSecrets[path]
+	if !ok {
+		var err error
+		switch {
+		case r.vault.Dir != "":
+			secrets, err = readVaultFile(r.vault.Dir, path)
+		case r.vault.Address != "":
+			secrets, err = readVaultServer(r.vault, path)
+		default:
+			err = errors.New("secrets.vault.address is not configured")
+		}
+		if err != nil {
+			return "", err
+		}
+		r.vaultSecrets[path] = secrets
+	}
+
+	value, ok := secrets[key]
+	if !ok {
+		return "", fmt.Errorf("no key %s", key)
+	}
+	return value, nil
+}
+
+// readVaultFile is the stand-in of Vault, the secret is a JSON object in a file
+func readVaultFile(dir string, path string) (map[string]string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var secrets map[string]string
+	if err = json.Unmarshal(content, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// readVaultServer reads a secret of the KV secrets engine, the data of version 2 is nested in a "data" key
+func readVaultServer(vault VaultConfig, path string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	endpoint, err := url.JoinPath(vault.Address, "v1", path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", vault.Token.Value())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault answered %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	data := body.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		data = nested
+	}
+
+	secrets := make(map[string]string, len(data))
+	for key, value := range data {
+		if text, ok := value.(string); ok {
+			secrets[key] = text
+		}
+	}
+	return secrets, nil
+}
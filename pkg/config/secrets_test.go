@@ -0,0 +1,115 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "couchbase-password")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "vault", "secret"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vault", "secret", "auth.json"), []byte(`{"admin": "from-vault"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_PAGINATION_SECRET", "from-env")
+
+	appConfig := Defaults()
+	appConfig.Secrets.Vault.Dir = filepath.Join(dir, "vault")
+	appConfig.Pagination.Secret = "env://TEST_PAGINATION_SECRET"
+	appConfig.Couchbase.Password = Secret("secret://" + secretFile)
+	appConfig.Auth.Users = []UserConfig{
+		{Username: "admin", Password: "vault://secret/auth#admin"},
+		{Username: "plain", Password: "plain-password"},
+	}
+
+	if validationErr := resolveSecrets(&appConfig); len(validationErr.Problems) > 0 {
+		t.Fatal(validationErr)
+	}
+
+	tests := []struct {
+		key  string
+		got  Secret
+		want string
+	}{
+		{key: "pagination.secret", got: appConfig.Pagination.Secret, want: "from-env"},
+		{key: "couchbase.password", got: appConfig.Couchbase.Password, want: "from-file"},
+		{key: "auth.users[0].password", got: appConfig.Auth.Users[0].Password, want: "from-vault"},
+		{key: "auth.users[1].password", got: appConfig.Auth.Users[1].Password, want: "plain-password"},
+	}
+	for _, tt := range tests {
+		if tt.got.Value() != tt.want {
+			t.Errorf("%s is %q, want %q", tt.key, tt.got.Value(), tt.want)
+		}
+	}
+}
+
+func TestResolveVaultServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/couchbase" || r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"data": {"password": "from-vault"}}}`)
+	}))
+	defer server.Close()
+	t.Setenv("TEST_VAULT_TOKEN", "token")
+
+	appConfig := Defaults()
+	appConfig.Secrets.Vault = VaultConfig{Address: server.URL, Token: "env://TEST_VAULT_TOKEN"}
+	appConfig.Couchbase.Password = "vault://secret/data/couchbase#password"
+
+	if validationErr := resolveSecrets(&appConfig); len(validationErr.Problems) > 0 {
+		t.Fatal(validationErr)
+	}
+	if appConfig.Couchbase.Password.Value() != "from-vault" {
+		t.Fatalf("couchbase.password is %q, want the value of the KV v2 secret", appConfig.Couchbase.Password.Value())
+	}
+}
+
+func TestResolveSecretErrors(t *testing.T) {
+	appConfig := Defaults()
+	appConfig.Secrets.Vault.Dir = t.TempDir()
+	appConfig.Pagination.Secret = "env://TEST_UNSET_SECRET"
+	appConfig.Couchbase.Password = "secret:///does/not/exist"
+	appConfig.Auth.Users = []UserConfig{{Username: "admin", Password: "vault://secret/auth"}}
+
+	validationErr := resolveSecrets(&appConfig)
+
+	keys := make([]string, 0, len(validationErr.Problems))
+	for _, problem := range validationErr.Problems {
+		keys = append(keys, problem.Key)
+	}
+	if got := strings.Join(keys, ","); got != "couchbase.password,pagination.secret,auth.users[0].password" {
+		t.Fatalf("problems with %s, want couchbase.password, pagination.secret and auth.users[0].password", got)
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	appConfig := Defaults()
+	appConfig.Couchbase.Password = "hunter2"
+
+	var printed bytes.Buffer
+	if err := Print(&printed, &appConfig); err != nil {
+		t.Fatal(err)
+	}
+	for name, output := range map[string]string{
+		"print":  printed.String(),
+		"format": fmt.Sprintf("%v %+v %#v %s", appConfig.Couchbase, appConfig.Couchbase, appConfig.Couchbase.Password, appConfig.Couchbase.Password),
+	} {
+		if strings.Contains(output, "hunter2") || !strings.Contains(output, Redacted) {
+			t.Errorf("%s shows the secret: %s", name, output)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Problem is a setting that is invalid
+type Problem struct {
+	Key     string
+	Message string
+}
+
+// ValidationError lists every setting that is invalid
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "invalid configuration, %d problem(s):", len(e.Problems))
+	for _, problem := range e.Problems {
+		fmt.Fprintf(&builder, "\n  %s: %s", problem.Key, problem.Message)
+	}
+	return builder.String()
+}
+
+func (e *ValidationError) add(key string, format string, args ...any) {
+	e.Problems = append(e.Problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) has(key string) bool {
+	for _, problem := range e.Problems {
+		if problem.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *ValidationError) oneOf(key string, value string, allowed ...string) {
+	for _, candidate := range allowed {
+		if strings.EqualFold(value, candidate) {
+			return
+		}
+	}
+	e.add(key, "%q is not one of %s", value, strings.Join(allowed, ", "))
+}
+
+func (e *ValidationError) required(key string, value string) {
+	if value == "" {
+		e.add(key, "is required")
+	}
+}
+
+func (e *ValidationError) port(key string, value string) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		e.add(key, "%q is not a port", value)
+	}
+}
+
+func (e *ValidationError) hostPort(key string, value string) {
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		e.add(key, "%q is not host:port", value)
+	}
+}
+
+func (e *ValidationError) positive(key string, value time.Duration) {
+	if value <= 0 {
+		e.add(key, "must be positive, got %s", value)
+	}
+}
+
+func (e *ValidationError) notNegative(key string, value int) {
+	if value < 0 {
+		e.add(key, "must not be negative, got %d", value)
+	}
+}
+
+func (e *ValidationError) percentage(key string, value *int) {
+	if value != nil && (*value < 0 || *value > 100) {
+		e.add(key, "must be a percentage, got %d", *value)
+	}
+}
+
+// Validate checks every setting and returns a *ValidationError listing the invalid ones. Secrets are only
+// optional in the local profile.
+func (c *AppConfig) Validate(profile string) error {
+	e := &ValidationError{}
+
+	e.port("port", c.Port)
+	e.port("grpc.port", c.GRPC.Port)
+	e.oneOf("server.json", c.Server.JSON, "goccy", "std")
+
+	e.required("couchbase.url", c.Couchbase.URL)
+	e.required("couchbase.bucket", c.Couchbase.Bucket)
+	for _, entity := range slices.Sorted(maps.Keys(c.Couchbase.Collections)) {
+		collection, key := c.Couchbase.Collections[entity], "couchbase.collections."+entity
+		e.oneOf(key, entity, Entities...)
+		if scope, name, found := strings.Cut(collection, "."); !found || scope == "" || name == "" {
+			e.add(key, "%q is not scope.collection", collection)
+		}
+	}
+	for _, tenant := range slices.Sorted(maps.Keys(c.Couchbase.Tenants)) {
+		e.required("couchbase.tenants."+tenant, c.Couchbase.Tenants[tenant])
+	}
+	c.Couchbase.Operations.validate(e)
+
+	e.hostPort("jaeger.url", c.Jaeger.URL)
+
+	e.oneOf("idempotency.store", c.Idempotency.Store, "memory", "couchbase")
+	e.positive("idempotency.lifetime", c.Idempotency.Lifetime)
+	e.positive("idempotency.wait", c.Idempotency.Wait)
+
+	if profile != LocalProfile {
+		e.required("pagination.secret", c.Pagination.Secret.Value())
+		if len(c.Auth.Users) == 0 {
+			e.add("auth.users", "is required")
+		}
+	}
+
+	e.notNegative("bulk.batchsize", c.Bulk.BatchSize)
+	e.notNegative("bulk.concurrency", c.Bulk.Concurrency)
+	e.notNegative("bulk.maxatomicitems", c.Bulk.MaxAtomicItems)
+
+	e.notNegative("graphql.maxdepth", c.GraphQL.MaxDepth)
+	e.notNegative("graphql.maxcomplexity", c.GraphQL.MaxComplexity)
+	e.notNegative("graphql.persistedqueries", c.GraphQL.PersistedQueries)
+
+	e.notNegative("stream.history", c.Stream.History)
+	e.notNegative("stream.buffer", c.Stream.Buffer)
+
+	e.oneOf("broker.type", c.Broker.Type, "memory", "kafka", "nats")
+	if c.Broker.Type == "kafka" && len(c.Broker.Kafka.Brokers) == 0 {
+		e.add("broker.kafka.brokers", "is required by the kafka broker")
+	}
+	if c.Broker.Type == "nats" {
+		e.required("broker.nats.url", c.Broker.NATS.URL)
+	}
+
+	e.required("outbox.topic", c.Outbox.Topic)
+	e.notNegative("outbox.batchsize", c.Outbox.BatchSize)
+	e.positive("outbox.interval", c.Outbox.Interval)
+	e.positive("outbox.maxbackoff", c.Outbox.MaxBackoff)
+
+	if c.Consumer.Topic != "" {
+		e.required("consumer.group", c.Consumer.Group)
+		for i, delay := range c.Consumer.RetryDelays {
+			e.positive(fmt.Sprintf("consumer.retrydelays[%d]", i), delay)
+		}
+		e.oneOf("consumer.store", c.Consumer.Store, "memory", "couchbase")
+		e.positive("consumer.processedlifetime", c.Consumer.ProcessedLifetime)
+	}
+
+	if c.ChangeFeed.Enabled {
+		e.required("changefeed.name", c.ChangeFeed.Name)
+		e.oneOf("changefeed.from", c.ChangeFeed.From, "now", "beginning")
+		e.oneOf("changefeed.checkpoints", c.ChangeFeed.Checkpoints, "memory", "couchbase")
+		e.positive("changefeed.checkpointinterval", c.ChangeFeed.CheckpointInterval)
+	}
+
+	e.notNegative("cache.size", c.Cache.Size)
+	if c.Cache.Size > 0 {
+		e.positive("cache.ttl", c.Cache.TTL)
+	}
+
+	if c.Shutdown.DrainDelay < 0 {
+		e.add("shutdown.draindelay", "must not be negative, got %s", c.Shutdown.DrainDelay)
+	}
+
+	e.oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	e.oneOf("log.format", c.Log.Format, "json", "console")
+	if len(c.Log.Outputs) == 0 {
+		e.add("log.outputs", "is required")
+	}
+	for i, output := range c.Log.Outputs {
+		e.required(fmt.Sprintf("log.outputs[%d]", i), output)
+	}
+	e.notNegative("log.rotation.maxsize", c.Log.Rotation.MaxSize)
+	e.notNegative("log.rotation.maxage", c.Log.Rotation.MaxAge)
+	e.notNegative("log.rotation.maxbackups", c.Log.Rotation.MaxBackups)
+	e.notNegative("log.sampling.initial", c.Log.Sampling.Initial)
+	e.notNegative("log.sampling.thereafter", c.Log.Sampling.Thereafter)
+	for _, name := range slices.Sorted(maps.Keys(c.Log.Packages)) {
+		e.oneOf("log.packages."+name, c.Log.Packages[name], "debug", "info", "warn", "error")
+	}
+	if c.Log.Access.SampleRate < 0 || c.Log.Access.SampleRate > 1 {
+		e.add("log.access.samplerate", "must be between 0 and 1, got %g", c.Log.Access.SampleRate)
+	}
+	if c.Log.Access.SlowThreshold < 0 {
+		e.add("log.access.slowthreshold", "must not be negative, got %s", c.Log.Access.SlowThreshold)
+	}
+	for i, user := range c.Auth.Users {
+		e.required(fmt.Sprintf("auth.users[%d].username", i), user.Username)
+		e.required(fmt.Sprintf("auth.users[%d].password", i), user.Password.Value())
+		for j, tenant := range user.Tenants {
+			e.oneOf(fmt.Sprintf("auth.users[%d].tenants[%d]", i, j), tenant, append(slices.Sorted(maps.Keys(c.Couchbase.Tenants)), "*")...)
+		}
+	}
+	e.notNegative("ratelimit.max", c.RateLimit.Max)
+	if c.RateLimit.Max > 0 {
+		e.positive("ratelimit.expiration", c.RateLimit.Expiration)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.CircuitBreakers)) {
+		breaker, key := c.CircuitBreakers[name], "circuitbreakers."+name
+		if breaker.Interval < 0 {
+			e.add(key+".interval", "must not be negative, got %s", breaker.Interval)
+		}
+		if breaker.Timeout < 0 {
+			e.add(key+".timeout", "must not be negative, got %s", breaker.Timeout)
+		}
+		if breaker.FailureThreshold < 0 || breaker.FailureThreshold > 1 {
+			e.add(key+".failurethreshold", "must be between 0 and 1, got %g", breaker.FailureThreshold)
+		}
+	}
+
+	if c.Secrets.Refresh < 0 {
+		e.add("secrets.refresh", "must not be negative, got %s", c.Secrets.Refresh)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.Features.Flags)) {
+		c.Features.Flags[name].validate(e, "features.flags."+name)
+	}
+	if c.Features.Remote.URL != "" {
+		e.positive("features.remote.interval", c.Features.Remote.Interval)
+	}
+
+	if c.Tenancy.Required && c.Tenancy.Header == "" && c.Tenancy.Domain == "" && c.Tenancy.Claim == "" {
+		e.add("tenancy.required", "needs a header, domain or claim")
+	}
+	if c.Tenancy.Claim != "" {
+		e.required("tenancy.tokenheader", c.Tenancy.TokenHeader)
+		e.required("tenancy.secret", c.Tenancy.Secret.Value())
+	}
+	for _, tenant := range slices.Sorted(maps.Keys(c.Tenancy.Tenants)) {
+		overrides, key := c.Tenancy.Tenants[tenant], "tenancy.tenants."+tenant
+		if _, ok := c.Couchbase.Tenants[tenant]; !ok {
+			e.add(key, "is not one of couchbase.tenants")
+		}
+		if limit := overrides.RateLimit; limit != nil {
+			e.notNegative(key+".ratelimit.max", limit.Max)
+			if limit.Max > 0 && limit.Expiration < 0 {
+				e.add(key+".ratelimit.expiration", "must not be negative, got %s", limit.Expiration)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(overrides.Features)) {
+			overrides.Features[name].validate(e, key+".features."+name)
+		}
+	}
+
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return nil
+}
+
+func (c FeatureFlagConfig) validate(e *ValidationError, key string) {
+	e.percentage(key+".rollout", c.Rollout)
+	for i, rule := range c.Rules {
+		ruleKey := fmt.Sprintf("%s.rules[%d]", key, i)
+		e.percentage(ruleKey+".rollout", rule.Rollout)
+		if rule.Header != "" && len(rule.Values) == 0 {
+			e.add(ruleKey+".values", "is required with a header")
+		}
+	}
+}
+
+// validate checks the values, the repository checks which operations they apply to
+func (c CouchbaseOperationsConfig) validate(e *ValidationError) {
+	operations := map[string]CouchbaseOperationConfig{
+		"get": c.Get, "create": c.Create, "update": c.Update, "bulk": c.Bulk, "search": c.Search, "outbox": c.Outbox,
+	}
+	for _, name := range []string{"get", "create", "update", "bulk", "search", "outbox"} {
+		operation, key := operations[name], "couchbase.operations."+name
+		if operation.Timeout < 0 {
+			e.add(key+".timeout", "must not be negative, got %s", operation.Timeout)
+		}
+		if operation.Durability != "" {
+			e.oneOf(key+".durability", operation.Durability, "none", "majority", "majorityandpersistactive", "persisttomajority")
+		}
+		if operation.ScanConsistency != "" {
+			e.oneOf(key+".scanconsistency", operation.ScanConsistency, "notbounded", "requestplus")
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "config_reloads_total",
+	Help: "Reloads of the configuration, by result",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(reloads)
+}
+
+// debounce groups the events of an editor or of a ConfigMap update, which write a file in several steps
+const debounce = 200 * time.Millisecond
+
+// Watcher reloads the configuration when one of its files changes or on SIGHUP and notifies the subscribers
+// of the sections that changed. A configuration that does not load or validate is rejected, the current one
+// is kept.
+type Watcher struct {
+	options Options
+
+	// reloading serializes the reloads, mu guards the fields below it so that subscribers can call Current
+	reloading   sync.Mutex
+	mu          sync.Mutex
+	current     *AppConfig
+	subscribers []subscriber
+}
+
+type subscriber struct {
+	key    string
+	notify func(old, new *AppConfig)
+}
+
+// NewWatcher watches the sources that options select, current is the configuration loaded from them
+func NewWatcher(options Options, current *AppConfig) *Watcher {
+	return &Watcher{options: options, current: current}
+}
+
+// Subscribe calls notify with the new section when a reload changes it. Key is the top level key of the
+// section, changes of the keys without a subscriber need a restart.
+func Subscribe[T any](w *Watcher, key string, section func(c *AppConfig) T, notify func(section T)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers = append(w.subscribers, subscriber{key: key, notify: func(old, new *AppConfig) {
+		if next := section(new); !reflect.DeepEqual(section(old), next) {
+			notify(next)
+		}
+	}})
+}
+
+// Current returns the last configuration that was loaded
+func (w *Watcher) Current() *AppConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload loads the configuration again and notifies the subscribers. The error of a rejected configuration is
+// returned, typically a *ValidationError.
+func (w *Watcher) Reload() error {
+	w.reloading.Lock()
+	defer w.reloading.Unlock()
+
+	next, err := Load(w.options)
+	if err != nil {
+		reloads.WithLabelValues("rejected").Inc()
+		zap.L().Error("Rejected configuration reload", zap.Error(err))
+		return err
+	}
+
+	w.mu.Lock()
+	old := w.current
+	w.current = next
+	subscribers := slices.Clone(w.subscribers)
+	w.mu.Unlock()
+	reloads.WithLabelValues("success").Inc()
+
+	subscribed := make([]string, 0, len(subscribers))
+	for _, subscriber := range subscribers {
+		subscriber.notify(old, next)
+		subscribed = append(subscribed, subscriber.key)
+	}
+
+	var restart []string
+	for _, key := range changedKeys(old, next) {
+		if !slices.Contains(subscribed, key) {
+			restart = append(restart, key)
+		}
+	}
+	switch {
+	case len(restart) > 0:
+		zap.L().Warn("Reloaded configuration, some changes need a restart", zap.Strings("keys", restart))
+	case reflect.DeepEqual(old, next):
+		zap.L().Debug("Reloaded configuration, nothing changed")
+	default:
+		zap.L().Info("Reloaded configuration")
+	}
+	return nil
+}
+
+// Run reloads the configuration until ctx is done. Files that do not exist when it starts are not watched,
+// SIGHUP reloads them. With secrets.refresh, the configuration is also reloaded periodically so that rotated
+// secrets are resolved again.
+func (w *Watcher) Run(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	refresh := time.NewTicker(time.Hour)
+	defer refresh.Stop()
+	setRefresh := func(secrets SecretsConfig) {
+		refresh.Stop()
+		if secrets.Refresh > 0 {
+			refresh.Reset(secrets.Refresh)
+		}
+	}
+	setRefresh(w.Current().Secrets)
+	Subscribe(w, "secrets", func(c *AppConfig) SecretsConfig { return c.Secrets }, setRefresh)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	files, err := w.options.files()
+	if err == nil && len(files) > 0 {
+		watcher, err := watchFiles(files)
+		if err != nil {
+			zap.L().Error("Failed to watch config files, reload with SIGHUP", zap.Error(err))
+		} else {
+			defer watcher.Close()
+			events, errs = watcher.Events, watcher.Errors
+		}
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			zap.L().Info("Received SIGHUP, reloading configuration")
+			_ = w.Reload()
+		case event, ok := <-events:
+			// A closed watcher stops watching the files, SIGHUP still reloads them
+			if !ok {
+				events = nil
+				continue
+			}
+			if slices.Contains(files, filepath.Clean(event.Name)) || filepath.Base(event.Name) == "..data" {
+				timer.Reset(debounce)
+			}
+		case <-refresh.C:
+			_ = w.Reload()
+		case <-timer.C:
+			zap.L().Info("Config file changed, reloading configuration")
+			_ = w.Reload()
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			zap.L().Error("Failed to watch config files", zap.Error(err))
+		}
+	}
+}
+
+// watchFiles watches the directories of files, which also sees files that are replaced instead of written,
+// like the ..data link of a Kubernetes ConfigMap
+func watchFiles(files []string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err = watcher.Add(filepath.Dir(file)); err != nil {
+			_ = watcher.Close()
+			return nil, err
+		}
+	}
+	return watcher, nil
+}
+
+// changedKeys returns the top level keys whose settings differ
+func changedKeys(old, new *AppConfig) []string {
+	var keys []string
+	oldValue, newValue := reflect.ValueOf(*old), reflect.ValueOf(*new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			keys = append(keys, oldValue.Type().Field(i).Tag.Get("mapstructure"))
+		}
+	}
+	return keys
+}
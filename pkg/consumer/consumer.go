@@ -0,0 +1,242 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-fiber-poc/pkg/broker"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// Headers added to messages sent to retry and dead letter topics
+const (
+	HeaderAttempt       = "attempt"
+	HeaderNotBefore     = "not-before"
+	HeaderError         = "error"
+	HeaderOriginalTopic = "original-topic"
+)
+
+// Config defines the config for a consumer
+type Config struct {
+	// Topic is consumed by the consumer
+	Topic string
+
+	// Group shares the topic between the instances of the service and keeps their offsets
+	Group string
+
+	// RetryDelays has the delay of every retry topic. A message that fails goes to the next retry
+	// topic and is handled again after its delay, and to the dead letter topic after the last one.
+	//
+	// Optional. Default: nil
+	RetryDelays []time.Duration
+
+	// DeadLetterTopic receives the messages that failed every attempt or cannot succeed
+	//
+	// Optional. Default: "<group>.<topic>.dlq"
+	DeadLetterTopic string
+
+	// Store remembers processed messages, so that redelivered messages are skipped
+	//
+	// Optional. Default: NewMemoryStore()
+	Store Store
+
+	// ProcessedLifetime is how long a processed message is remembered
+	//
+	// Optional. Default: 24 * time.Hour
+	ProcessedLifetime time.Duration
+
+	// HandleTimeout bounds the handling of one message
+	//
+	// Optional. Default: 30 * time.Second
+	HandleTimeout time.Duration
+}
+
+// Consumer hands the messages of a topic to a handler. Failed messages move through retry topics
+// to a dead letter topic, so one bad message does not hold back the ones behind it.
+type Consumer struct {
+	subscriber broker.Subscriber
+	publisher  broker.Publisher
+	handler    broker.Handler
+	config     Config
+
+	mu sync.Mutex
+	// failures has the error of every topic that waits to be subscribed again
+	failures map[string]error
+}
+
+func New(subscriber broker.Subscriber, publisher broker.Publisher, handler broker.Handler, config Config) *Consumer {
+	if config.DeadLetterTopic == "" {
+		config.DeadLetterTopic = fmt.Sprintf("%s.%s.dlq", config.Group, config.Topic)
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.ProcessedLifetime <= 0 {
+		config.ProcessedLifetime = 24 * time.Hour
+	}
+	if config.HandleTimeout <= 0 {
+		config.HandleTimeout = 30 * time.Second
+	}
+
+	return &Consumer{
+		subscriber: subscriber,
+		publisher:  publisher,
+		handler:    handler,
+		config:     config,
+		failures:   make(map[string]error),
+	}
+}
+
+// Run consumes the topic and its retry topics until ctx is done. Messages that are being handled
+// when ctx is done are finished and committed before Run returns.
+func (c *Consumer) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for stage := 0; stage <= len(c.config.RetryDelays); stage++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.subscribe(ctx, stage)
+		}()
+	}
+	wg.Wait()
+}
+
+// subscribe consumes the topic of a stage, 0 being the topic itself, and resubscribes when the broker fails
+func (c *Consumer) subscribe(ctx context.Context, stage int) {
+	topic := c.topic(stage)
+	for attempt := 1; ; attempt++ {
+		c.setFailure(topic, nil)
+		err := c.subscriber.Subscribe(ctx, topic, c.config.Group, func(ctx context.Context, message broker.Message) error {
+			return c.process(ctx, stage, message)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		zap.L().Error("Failed to consume topic", zap.Error(err), zap.String("topic", topic), zap.Int("attempt", attempt))
+		c.setFailure(topic, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(min(time.Duration(attempt)*time.Second, 30*time.Second)):
+		}
+	}
+}
+
+func (c *Consumer) setFailure(topic string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		delete(c.failures, topic)
+	} else {
+		c.failures[topic] = err
+	}
+}
+
+// Health fails while a topic cannot be consumed and waits to be subscribed again
+func (c *Consumer) Health(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := make([]error, 0, len(c.failures))
+	for topic, err := range c.failures {
+		errs = append(errs, fmt.Errorf("%s: %w", topic, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Consumer) process(ctx context.Context, stage int, message broker.Message) error {
+	// The subscriber pauses the retry topic until the message is due, rather than the handler blocking it
+	if at, err := time.Parse(time.RFC3339Nano, message.Headers[HeaderNotBefore]); stage > 0 && err == nil && time.Now().Before(at) {
+		return broker.Pause(at)
+	}
+
+	key := c.config.Group + "::" + message.ID
+	if processed, err := c.config.Store.Processed(ctx, key); err != nil {
+		zap.L().Error("Failed to check processed message", zap.Error(err), zap.String("id", message.ID))
+	} else if processed {
+		return nil
+	}
+
+	// A message that started is finished even when the consumer is stopping
+	handleCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.config.HandleTimeout)
+	defer cancel()
+
+	err := c.handler(handleCtx, message)
+	if err == nil {
+		if err := c.config.Store.MarkProcessed(handleCtx, key, c.config.ProcessedLifetime); err != nil {
+			zap.L().Error("Failed to mark message processed", zap.Error(err), zap.String("id", message.ID))
+		}
+		return nil
+	}
+
+	next := c.config.DeadLetterTopic
+	var delay time.Duration
+	if stage < len(c.config.RetryDelays) && !isPermanent(err) {
+		next = c.topic(stage + 1)
+		delay = c.config.RetryDelays[stage]
+	}
+	zap.L().Warn("Failed to handle message", zap.Error(err), zap.String("id", message.ID), zap.String("topic", message.Topic), zap.String("next", next))
+
+	forward := message
+	forward.Topic = next
+	forward.Headers = maps.Clone(message.Headers)
+	if forward.Headers == nil {
+		forward.Headers = make(map[string]string)
+	}
+	forward.Headers[HeaderAttempt] = strconv.Itoa(stage + 1)
+	forward.Headers[HeaderError] = err.Error()
+	forward.Headers[HeaderOriginalTopic] = c.config.Topic
+	forward.Headers[HeaderNotBefore] = time.Now().Add(delay).UTC().Format(time.RFC3339Nano)
+
+	// The message is committed only once it is forwarded
+	return c.publisher.Publish(handleCtx, forward)
+}
+
+// topic returns the topic of a stage: the topic itself, then the retry topics
+func (c *Consumer) topic(stage int) string {
+	if stage == 0 {
+		return c.config.Topic
+	}
+	return fmt.Sprintf("%s.%s.retry.%d", c.config.Group, c.config.Topic, stage)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error that retrying cannot fix. The message goes straight to the dead letter topic.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanent tells whether a retry can succeed. Handlers report invalid requests with a 4xx *fiber.Error,
+// which fails the same way every time, except for timeouts and rate limits.
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return true
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code >= 400 && fiberErr.Code < 500 &&
+			fiberErr.Code != fiber.StatusRequestTimeout && fiberErr.Code != fiber.StatusTooManyRequests
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/infra/memory"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/consumer"
+	"sync"
+	"testing"
+	"time"
+)
+
+// run consumes "products" with handle until a message reaches topic and finished is closed, and returns the message
+func run(t *testing.T, handle broker.Handler, topic string, finished <-chan struct{}) broker.Message {
+	t.Helper()
+	return runWithDelay(t, handle, topic, finished, time.Millisecond)
+}
+
+// runWithDelay is run with retry topics of delay
+func runWithDelay(t *testing.T, handle broker.Handler, topic string, finished <-chan struct{}, delay time.Duration) broker.Message {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	memoryBroker := memory.NewBroker()
+	c := consumer.New(memoryBroker, memoryBroker, handle, consumer.Config{
+		Topic:       "products",
+		Group:       "test",
+		RetryDelays: []time.Duration{delay, delay},
+	})
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The consumer subscribes to its topics before anything is published
+	time.Sleep(50 * time.Millisecond)
+
+	reached := make(chan broker.Message, 1)
+	go memoryBroker.Subscribe(ctx, topic, "observer", func(_ context.Context, message broker.Message) error {
+		reached <- message
+		return nil
+	})
+	if err := memoryBroker.Publish(ctx, broker.Message{ID: "1", Topic: "products", Value: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var message broker.Message
+	select {
+	case message = <-reached:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message reached %s", topic)
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer did not finish")
+	}
+	return message
+}
+
+func TestRetry(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []string
+	handled := make(chan struct{})
+	handle := func(_ context.Context, message broker.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, message.Topic)
+		if len(attempts) == 1 {
+			return errors.New("temporarily unavailable")
+		}
+		close(handled)
+		return nil
+	}
+
+	message := run(t, handle, "test.products.retry.1", handled)
+
+	if message.Headers[consumer.HeaderAttempt] != "1" || message.Headers[consumer.HeaderOriginalTopic] != "products" {
+		t.Fatalf("retry headers %v", message.Headers)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 2 || attempts[1] != "test.products.retry.1" {
+		t.Fatalf("attempts %v, want products then the first retry topic", attempts)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		attempt string
+	}{
+		{name: "after every retry", err: errors.New("temporarily unavailable"), attempt: "3"},
+		{name: "permanent", err: consumer.Permanent(errors.New("invalid")), attempt: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finished := make(chan struct{})
+			close(finished)
+			message := run(t, func(context.Context, broker.Message) error { return tt.err }, "test.products.dlq", finished)
+
+			if message.ID != "1" || message.Headers[consumer.HeaderAttempt] != tt.attempt || message.Headers[consumer.HeaderError] != tt.err.Error() {
+				t.Fatalf("dead letter %s with headers %v, want attempt %s", message.ID, message.Headers, tt.attempt)
+			}
+		})
+	}
+}
+
+func TestRetryWaitsForTheDelay(t *testing.T) {
+	var mu sync.Mutex
+	var handledAt []time.Time
+	handled := make(chan struct{})
+	handle := func(context.Context, broker.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handledAt = append(handledAt, time.Now())
+		if len(handledAt) == 1 {
+			return errors.New("temporarily unavailable")
+		}
+		close(handled)
+		return nil
+	}
+
+	runWithDelay(t, handle, "test.products.retry.1", handled, 200*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handledAt) != 2 {
+		t.Fatalf("handled %d times, want 2", len(handledAt))
+	}
+	if waited := handledAt[1].Sub(handledAt[0]); waited < 200*time.Millisecond {
+		t.Errorf("retried after %s, want the delay of 200ms", waited)
+	}
+}
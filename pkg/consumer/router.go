@@ -0,0 +1,21 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"golang-fiber-poc/pkg/broker"
+)
+
+// HeaderType selects the handler of a message in a Router
+const HeaderType = "type"
+
+// Router dispatches messages to handlers by their type header
+type Router map[string]broker.Handler
+
+func (r Router) Handle(ctx context.Context, message broker.Message) error {
+	handler, ok := r[message.Headers[HeaderType]]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for message type %q", message.Headers[HeaderType]))
+	}
+	return handler(ctx, message)
+}
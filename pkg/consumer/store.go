@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store remembers the messages a group has processed, so that redelivered messages are skipped
+type Store interface {
+	Processed(ctx context.Context, key string) (bool, error)
+	MarkProcessed(ctx context.Context, key string, lifetime time.Duration) error
+}
+
+// MemoryStore keeps processed message keys in process memory. It is meant for
+// single instance deployments and local development.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]time.Time)}
+}
+
+func (s *MemoryStore) Processed(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.entries[key]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (s *MemoryStore) MarkProcessed(_ context.Context, key string, lifetime time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	s.entries[key] = now.Add(lifetime)
+	return nil
+}
+
+// sweep drops expired entries at most once a minute. Callers must hold the lock.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, expiresAt := range s.entries {
+		if now.After(expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchFunc loads many keys in one call. It returns one value and one error per key, in key order.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) ([]V, []error)
+
+// Loader collects keys and loads them together the first time one of their results is read.
+// Results are cached for the lifetime of the loader, so a loader belongs to a single request.
+type Loader[K comparable, V any] struct {
+	batch    BatchFunc[K, V]
+	maxBatch int
+
+	mu      sync.Mutex
+	pending []K
+	results map[K]*result[V]
+}
+
+type result[V any] struct {
+	value V
+	err   error
+}
+
+// New creates a loader. Batches larger than maxBatch are split, zero means unlimited.
+func New[K comparable, V any](batch BatchFunc[K, V], maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		batch:    batch,
+		maxBatch: maxBatch,
+		results:  make(map[K]*result[V]),
+	}
+}
+
+// Load queues the key and returns a thunk. Calling the thunk loads every queued key in one batch.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	res, ok := l.results[key]
+	if !ok {
+		res = &result[V]{}
+		l.results[key] = res
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.dispatch(ctx)
+		return res.value, res.err
+	}
+}
+
+// LoadMany queues all keys and returns a thunk yielding their values and errors in key order
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) func() ([]V, []error) {
+	thunks := make([]func() (V, error), len(keys))
+	for i, key := range keys {
+		thunks[i] = l.Load(ctx, key)
+	}
+
+	return func() ([]V, []error) {
+		values := make([]V, len(keys))
+		errs := make([]error, len(keys))
+		for i, thunk := range thunks {
+			values[i], errs[i] = thunk()
+		}
+		return values, errs
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for len(l.pending) > 0 {
+		size := len(l.pending)
+		if l.maxBatch > 0 {
+			size = min(size, l.maxBatch)
+		}
+		keys := l.pending[:size]
+		l.pending = l.pending[size:]
+
+		values, errs := l.batch(ctx, keys)
+		for i, key := range keys {
+			res := l.results[key]
+			if i < len(values) {
+				res.value = values[i]
+			}
+			if i < len(errs) {
+				res.err = errs[i]
+			}
+		}
+	}
+}
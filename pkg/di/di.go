@@ -0,0 +1,135 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Module registers the providers of a package
+type Module func(c *Container)
+
+// Container builds every type once, with the provider registered for it, when it is first needed.
+// Providers resolve their dependencies with Get or MustGet. A container is meant to be assembled and
+// resolved by one goroutine at startup.
+type Container struct {
+	providers map[reflect.Type]func(c *Container) (any, error)
+	instances map[reflect.Type]any
+	resolving []reflect.Type
+}
+
+func New(modules ...Module) *Container {
+	c := &Container{
+		providers: make(map[reflect.Type]func(c *Container) (any, error)),
+		instances: make(map[reflect.Type]any),
+	}
+	c.Install(modules...)
+	return c
+}
+
+// Install registers the providers of modules. Later modules replace the providers of earlier ones, so
+// tests install their fakes last.
+func (c *Container) Install(modules ...Module) {
+	for _, module := range modules {
+		module(c)
+	}
+}
+
+// Provide registers the provider of T, replacing the previous one. T must not be built yet.
+func Provide[T any](c *Container, provider func(c *Container) (T, error)) {
+	key := typeOf[T]()
+	if _, ok := c.instances[key]; ok {
+		panic(fmt.Sprintf("di: %s is provided after it was built", key))
+	}
+	c.providers[key] = func(c *Container) (any, error) {
+		return provider(c)
+	}
+}
+
+// Supply registers a value of T
+func Supply[T any](c *Container, value T) {
+	Provide(c, func(*Container) (T, error) { return value, nil })
+}
+
+// Bind provides the interface I with the implementation T
+func Bind[I, T any](c *Container) {
+	Provide(c, func(c *Container) (I, error) {
+		implementation, err := Get[T](c)
+		if err != nil {
+			var zero I
+			return zero, err
+		}
+		value, ok := any(implementation).(I)
+		if !ok {
+			var zero I
+			return zero, fmt.Errorf("di: %s does not implement %s", typeOf[T](), typeOf[I]())
+		}
+		return value, nil
+	})
+}
+
+// Get returns the instance of T, building it and its dependencies the first time
+func Get[T any](c *Container) (value T, err error) {
+	key := typeOf[T]()
+	if instance, ok := c.instances[key]; ok {
+		return instance.(T), nil
+	}
+
+	provider, ok := c.providers[key]
+	if !ok {
+		return value, fmt.Errorf("di: no provider of %s%s", key, c.path())
+	}
+	for _, resolving := range c.resolving {
+		if resolving == key {
+			return value, fmt.Errorf("di: %s depends on itself%s", key, c.path())
+		}
+	}
+
+	c.resolving = append(c.resolving, key)
+	defer func() {
+		c.resolving = c.resolving[:len(c.resolving)-1]
+		// MustGet in a provider panics with the error of a dependency, the caller of Get gets it back
+		if r := recover(); r != nil {
+			resolveErr, ok := r.(mustGetError)
+			if !ok {
+				panic(r)
+			}
+			err = resolveErr.err
+		}
+	}()
+
+	instance, err := provider(c)
+	if err != nil {
+		return value, fmt.Errorf("di: build %s: %w", key, err)
+	}
+	c.instances[key] = instance
+	return instance.(T), nil
+}
+
+// MustGet is Get for providers, the error fails the Get that built the provider
+func MustGet[T any](c *Container) T {
+	value, err := Get[T](c)
+	if err != nil {
+		panic(mustGetError{err: err})
+	}
+	return value
+}
+
+type mustGetError struct {
+	err error
+}
+
+func (c *Container) path() string {
+	if len(c.resolving) == 0 {
+		return ""
+	}
+	names := make([]string, len(c.resolving))
+	for i, key := range c.resolving {
+		names[i] = key.String()
+	}
+	return " (needed by " + strings.Join(names, " -> ") + ")"
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
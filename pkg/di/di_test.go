@@ -0,0 +1,100 @@
+package di
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type greeter interface {
+	Greet() string
+}
+
+type english struct{}
+
+func (english) Greet() string { return "hello" }
+
+type french struct{}
+
+func (french) Greet() string { return "bonjour" }
+
+type service struct {
+	greeter greeter
+}
+
+func appModule(c *Container) {
+	Supply(c, english{})
+	Bind[greeter, english](c)
+	Provide(c, func(c *Container) (*service, error) {
+		return &service{greeter: MustGet[greeter](c)}, nil
+	})
+}
+
+func TestOverride(t *testing.T) {
+	c := New(appModule, func(c *Container) {
+		Provide(c, func(*Container) (greeter, error) { return french{}, nil })
+	})
+
+	s, err := Get[*service](c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.greeter.Greet(); got != "bonjour" {
+		t.Fatalf("greeting %q, want the one of the override", got)
+	}
+	if again := MustGet[*service](c); again != s {
+		t.Fatal("the service was built twice")
+	}
+}
+
+func TestProvideAfterBuild(t *testing.T) {
+	c := New(appModule)
+	MustGet[*service](c)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("replacing a built provider did not panic")
+		}
+	}()
+	Supply[greeter](c, french{})
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("missing provider", func(t *testing.T) {
+		c := New(func(c *Container) {
+			Provide(c, func(c *Container) (*service, error) {
+				return &service{greeter: MustGet[greeter](c)}, nil
+			})
+		})
+
+		_, err := Get[*service](c)
+		if err == nil || !strings.Contains(err.Error(), "no provider of di.greeter (needed by *di.service)") {
+			t.Fatalf("error %v, want the missing provider with its path", err)
+		}
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		c := New(func(c *Container) {
+			Provide(c, func(c *Container) (*service, error) {
+				MustGet[*service](c)
+				return &service{}, nil
+			})
+		})
+
+		_, err := Get[*service](c)
+		if err == nil || !strings.Contains(err.Error(), "depends on itself") {
+			t.Fatalf("error %v, want a cycle", err)
+		}
+	})
+
+	t.Run("failed provider", func(t *testing.T) {
+		failed := errors.New("failed")
+		c := New(appModule, func(c *Container) {
+			Provide(c, func(*Container) (greeter, error) { return nil, failed })
+		})
+
+		if _, err := Get[*service](c); !errors.Is(err, failed) {
+			t.Fatalf("error %v, want the error of the dependency", err)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package eventbus
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSlowSubscriber ends a subscription whose buffer filled up. The subscriber can resume from the last event it read.
+	ErrSlowSubscriber = errors.New("subscriber is too slow")
+	ErrClosed         = errors.New("event bus is closed")
+)
+
+type Event[T any] struct {
+	ID   uint64
+	Data T
+}
+
+// Bus fans published events out to subscribers and keeps the latest ones, so that subscribers can
+// resume after a disconnect. Publishing never blocks: a subscriber that cannot keep up is dropped.
+type Bus[T any] struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event[T]
+	size        int
+	subscribers map[*Subscription[T]]struct{}
+	closed      bool
+}
+
+// New creates a bus keeping the last history events. IDs start at the current time in microseconds,
+// so IDs handed out before a restart are older than any event of the new bus.
+func New[T any](history int) *Bus[T] {
+	return &Bus[T]{
+		nextID:      uint64(time.Now().UnixMicro()),
+		size:        history,
+		subscribers: make(map[*Subscription[T]]struct{}),
+	}
+}
+
+func (b *Bus[T]) Publish(data T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	event := Event[T]{ID: b.nextID, Data: data}
+	b.nextID++
+
+	if b.size > 0 {
+		if len(b.history) == b.size {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, event)
+	}
+
+	for subscription := range b.subscribers {
+		if subscription.filter != nil && !subscription.filter(data) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			b.drop(subscription, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Subscribe receives the events matching filter, nil matches all. With after set, the kept events
+// published after it are replayed first. Missed is set on the subscription when some of them are gone.
+func (b *Bus[T]) Subscribe(after uint64, filter func(T) bool, buffer int) *Subscription[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []Event[T]
+	missed := false
+	if after > 0 {
+		missed = after >= b.nextID || (len(b.history) > 0 && after < b.history[0].ID-1) || (len(b.history) == 0 && after < b.nextID-1)
+		for _, event := range b.history {
+			if event.ID > after && (filter == nil || filter(event.Data)) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	subscription := &Subscription[T]{
+		bus:    b,
+		filter: filter,
+		events: make(chan Event[T], buffer+len(replay)),
+		Missed: missed,
+	}
+	for _, event := range replay {
+		subscription.events <- event
+	}
+
+	if b.closed {
+		subscription.err = ErrClosed
+		close(subscription.events)
+		return subscription
+	}
+
+	b.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// Close ends every subscription
+func (b *Bus[T]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for subscription := range b.subscribers {
+		b.drop(subscription, ErrClosed)
+	}
+}
+
+func (b *Bus[T]) drop(subscription *Subscription[T], err error) {
+	delete(b.subscribers, subscription)
+	subscription.err = err
+	close(subscription.events)
+}
+
+type Subscription[T any] struct {
+	// Missed is set when the subscription resumed from an event that is no longer kept
+	Missed bool
+
+	bus    *Bus[T]
+	filter func(T) bool
+	events chan Event[T]
+	err    error
+}
+
+// Events is closed when the subscription ends, Err tells why
+func (s *Subscription[T]) Events() <-chan Event[T] {
+	return s.events
+}
+
+func (s *Subscription[T]) Err() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription[T]) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subscribers[s]; ok {
+		s.bus.drop(s, nil)
+	}
+}
@@ -0,0 +1,229 @@
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"maps"
+	"net/textproto"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"golang-fiber-poc/pkg/auth"
+	"golang-fiber-poc/pkg/tenant"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Flag decides whether a behavior is on for the subject of a request, the principal and tenant of its context
+// and its headers
+type Flag struct {
+	// Enabled is the value for the subjects that no rule matches
+	Enabled bool `json:"enabled"`
+
+	// Rollout limits Enabled to a percentage of the subjects, from 0 to 100. JSON without it gets 100.
+	Rollout int `json:"rollout"`
+
+	// Rules target subjects, the first rule that matches decides
+	Rules []Rule `json:"rules"`
+}
+
+// Rule matches the subjects that meet all of its conditions, a rule without conditions matches every subject
+type Rule struct {
+	Principals []string `json:"principals"`
+	Tenants    []string `json:"tenants"`
+
+	// Header matches the requests that send one of Values in it
+	Header string   `json:"header"`
+	Values []string `json:"values"`
+
+	// Enabled is the value for the subjects the rule matches
+	Enabled bool `json:"enabled"`
+
+	// Rollout limits Enabled to a percentage of the subjects the rule matches, from 0 to 100. JSON without it
+	// gets 100.
+	Rollout int `json:"rollout"`
+}
+
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	type plain Flag
+	flag := plain{Rollout: 100}
+	if err := json.Unmarshal(data, &flag); err != nil {
+		return err
+	}
+	*f = Flag(flag)
+	return nil
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type plain Rule
+	rule := plain{Rollout: 100}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	*r = Rule(rule)
+	return nil
+}
+
+// Evaluator evaluates the flags of the config, and those of a remote Provider that replace them by name.
+// Flags that neither define are off, unless the code that uses them defines a default. Tenants can override
+// flags for their own requests.
+type Evaluator struct {
+	mu       sync.Mutex
+	defaults map[string]Flag
+	local    map[string]Flag
+	remote   map[string]Flag
+	flags    atomic.Pointer[map[string]Flag]
+	tenants  atomic.Pointer[map[string]map[string]Flag]
+}
+
+func NewEvaluator(flags map[string]Flag) *Evaluator {
+	evaluator := &Evaluator{defaults: make(map[string]Flag)}
+	evaluator.SetFlags(flags)
+	return evaluator
+}
+
+// Define sets the default of a flag, used while the config and the remote provider do not define it
+func (e *Evaluator) Define(name string, flag Flag) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.defaults[name] = flag
+	e.merge()
+}
+
+// SetFlags replaces the local flags
+func (e *Evaluator) SetFlags(flags map[string]Flag) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.local = flags
+	e.merge()
+}
+
+// SetRemoteFlags replaces the flags of the remote provider
+func (e *Evaluator) SetRemoteFlags(flags map[string]Flag) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.remote = flags
+	e.merge()
+}
+
+// SetTenantFlags replaces the flags that tenants override, by tenant then by name
+func (e *Evaluator) SetTenantFlags(flags map[string]map[string]Flag) {
+	e.tenants.Store(&flags)
+}
+
+func (e *Evaluator) merge() {
+	flags := maps.Clone(e.defaults)
+	maps.Copy(flags, e.local)
+	maps.Copy(flags, e.remote)
+	e.flags.Store(&flags)
+}
+
+// Enabled evaluates the flag for the subject of ctx and records the result on the span of ctx
+func (e *Evaluator) Enabled(ctx context.Context, name string) bool {
+	flag, ok := (*e.flags.Load())[name]
+	if tenantID, found := tenant.FromContext(ctx); found {
+		if overrides := e.tenants.Load(); overrides != nil {
+			if override, overridden := (*overrides)[tenantID][name]; overridden {
+				flag, ok = override, true
+			}
+		}
+	}
+	enabled := ok && flag.evaluate(ctx, name)
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("feature_flag."+name, enabled))
+	return enabled
+}
+
+// headers returns the names of the headers the rules look at
+func (e *Evaluator) headers() []string {
+	var names []string
+	collect := func(flags map[string]Flag) {
+		for _, flag := range flags {
+			for _, rule := range flag.Rules {
+				if rule.Header != "" && !slices.Contains(names, rule.Header) {
+					names = append(names, rule.Header)
+				}
+			}
+		}
+	}
+	collect(*e.flags.Load())
+	if overrides := e.tenants.Load(); overrides != nil {
+		for _, flags := range *overrides {
+			collect(flags)
+		}
+	}
+	return names
+}
+
+func (f Flag) evaluate(ctx context.Context, name string) bool {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	tenantID, _ := tenant.FromContext(ctx)
+	// Subjects stay in or out of a rollout, keyed by principal, tenant, then client address
+	key := principal
+	if key == "" {
+		key = tenantID
+	}
+	if key == "" {
+		key = subject(ctx)
+	}
+
+	for _, rule := range f.Rules {
+		if rule.matches(ctx, principal, tenantID) {
+			return rule.Enabled && inRollout(name, key, rule.Rollout)
+		}
+	}
+	return f.Enabled && inRollout(name, key, f.Rollout)
+}
+
+func (r Rule) matches(ctx context.Context, principal string, tenantID string) bool {
+	if len(r.Principals) > 0 && !slices.Contains(r.Principals, principal) {
+		return false
+	}
+	if len(r.Tenants) > 0 && !slices.Contains(r.Tenants, tenantID) {
+		return false
+	}
+	if r.Header != "" && !slices.Contains(r.Values, header(ctx, r.Header)) {
+		return false
+	}
+	return true
+}
+
+func inRollout(name string, key string, rollout int) bool {
+	switch {
+	case rollout >= 100:
+		return true
+	case rollout <= 0:
+		return false
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name + "/" + key))
+	return hash.Sum32()%100 < uint32(rollout)
+}
+
+type (
+	headersKey struct{}
+	subjectKey struct{}
+)
+
+// WithSubject stores the key of the rollouts of requests without a principal or tenant
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+func subject(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// WithHeaders stores the headers of a request, or the metadata of a call, for the rules
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+func header(ctx context.Context, name string) string {
+	headers, _ := ctx.Value(headersKey{}).(map[string]string)
+	return headers[textproto.CanonicalMIMEHeaderKey(name)]
+}
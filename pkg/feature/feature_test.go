@@ -0,0 +1,65 @@
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestRollout(t *testing.T) {
+	tests := []struct {
+		rollout int
+		want    int
+	}{
+		{rollout: 0, want: 0},
+		{rollout: 100, want: 1000},
+	}
+	for _, tt := range tests {
+		evaluator := NewEvaluator(map[string]Flag{"flag": {Enabled: true, Rollout: tt.rollout}})
+		enabled := 0
+		for i := range 1000 {
+			if evaluator.Enabled(WithSubject(context.Background(), fmt.Sprintf("10.0.0.%d", i)), "flag") {
+				enabled++
+			}
+		}
+		if enabled != tt.want {
+			t.Errorf("rollout %d enabled %d subjects, want %d", tt.rollout, enabled, tt.want)
+		}
+	}
+}
+
+func TestRolloutOfAnonymousSubjects(t *testing.T) {
+	evaluator := NewEvaluator(map[string]Flag{"flag": {Enabled: true, Rollout: 50}})
+
+	enabled := map[bool]int{}
+	for i := range 100 {
+		ctx := WithSubject(context.Background(), fmt.Sprintf("10.0.0.%d", i))
+		first := evaluator.Enabled(ctx, "flag")
+		if evaluator.Enabled(ctx, "flag") != first {
+			t.Fatal("a subject moved in or out of the rollout")
+		}
+		enabled[first]++
+	}
+	if enabled[true] == 0 || enabled[false] == 0 {
+		t.Fatalf("anonymous subjects are all in or all out of the rollout: %v", enabled)
+	}
+}
+
+func TestUnmarshalDefaultsRollout(t *testing.T) {
+	var flags map[string]Flag
+	err := json.Unmarshal([]byte(`{
+		"unset": {"enabled": true, "rules": [{"tenants": ["acme"], "enabled": true}]},
+		"off": {"enabled": true, "rollout": 0, "rules": [{"tenants": ["acme"], "enabled": true, "rollout": 0}]}
+	}`), &flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if flags["unset"].Rollout != 100 || flags["unset"].Rules[0].Rollout != 100 {
+		t.Fatalf("unset rollouts %+v, want 100", flags["unset"])
+	}
+	if flags["off"].Rollout != 0 || flags["off"].Rules[0].Rollout != 0 {
+		t.Fatalf("zero rollouts %+v, want 0", flags["off"])
+	}
+}
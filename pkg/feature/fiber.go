@@ -0,0 +1,27 @@
+package feature
+
+import (
+	"net/textproto"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Middleware stores the client address and the headers that the rules of the flags look at in the user
+// context, so that flags can be evaluated with the context of the handlers
+func Middleware(evaluator *Evaluator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := WithSubject(c.UserContext(), c.IP())
+		if names := evaluator.headers(); len(names) > 0 {
+			headers := make(map[string]string, len(names))
+			for _, name := range names {
+				if value := c.Get(name); value != "" {
+					headers[textproto.CanonicalMIMEHeaderKey(name)] = utils.CopyString(value)
+				}
+			}
+			ctx = WithHeaders(ctx, headers)
+		}
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package feature
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// UnaryServerInterceptor stores the peer address and the metadata that the rules of the flags look at in the
+// context, like Middleware does for HTTP
+func UnaryServerInterceptor(evaluator *Evaluator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(evaluator.callContext(ctx), req)
+	}
+}
+
+// StreamServerInterceptor stores the same context as UnaryServerInterceptor for streams
+func StreamServerInterceptor(evaluator *Evaluator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &stream{ServerStream: ss, ctx: evaluator.callContext(ss.Context())})
+	}
+}
+
+func (e *Evaluator) callContext(ctx context.Context) context.Context {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		address := p.Addr.String()
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			address = host
+		}
+		ctx = WithSubject(ctx, address)
+	}
+
+	if names := e.headers(); len(names) > 0 {
+		md, _ := metadata.FromIncomingContext(ctx)
+		headers := make(map[string]string, len(names))
+		for _, name := range names {
+			if values := md.Get(name); len(values) > 0 {
+				headers[textproto.CanonicalMIMEHeaderKey(name)] = values[0]
+			}
+		}
+		ctx = WithHeaders(ctx, headers)
+	}
+	return ctx
+}
+
+// stream replaces the context of a server stream
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,35 @@
+package feature
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryServerInterceptorHeaders(t *testing.T) {
+	evaluator := NewEvaluator(map[string]Flag{"flag": {Rollout: 100, Rules: []Rule{
+		{Header: "X-Beta", Values: []string{"yes"}, Enabled: true, Rollout: 100},
+	}}})
+	interceptor := UnaryServerInterceptor(evaluator)
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want bool
+	}{
+		{"matching metadata", metadata.Pairs("x-beta", "yes"), true},
+		{"other value", metadata.Pairs("x-beta", "no"), false},
+		{"no metadata", nil, false},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+		enabled, _ := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+			return evaluator.Enabled(ctx, "flag"), nil
+		})
+		if enabled != tt.want {
+			t.Errorf("%s: enabled %v, want %v", tt.name, enabled, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Provider supplies flags from a remote service
+type Provider interface {
+	Flags(ctx context.Context) (map[string]Flag, error)
+}
+
+// HTTPProvider reads the flags from a URL that answers a JSON object of the flags by name
+type HTTPProvider struct {
+	URL string
+
+	// Client sends the requests
+	//
+	// Optional. Default: a client with a 5 second timeout
+	Client *http.Client
+}
+
+func (p HTTPProvider) Flags(ctx context.Context) (map[string]Flag, error) {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("flags provider answered %s", resp.Status)
+	}
+
+	var flags map[string]Flag
+	if err = json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// Poll reads the flags of provider every interval until ctx is done. The last flags that were read are kept
+// while the provider fails.
+func (e *Evaluator) Poll(ctx context.Context, provider Provider, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		flags, err := provider.Flags(ctx)
+		if err != nil {
+			zap.L().Error("Failed to read feature flags", zap.Error(err))
+		} else {
+			e.SetRemoteFlags(flags)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
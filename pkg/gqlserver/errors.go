@@ -0,0 +1,82 @@
+package gqlserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"go.uber.org/zap"
+)
+
+// Error codes of failures that happen before a query executes
+const (
+	CodeParseFailed              = "GRAPHQL_PARSE_FAILED"
+	CodeValidationFailed         = "GRAPHQL_VALIDATION_FAILED"
+	CodeQueryTooComplex          = "QUERY_TOO_COMPLEX"
+	CodePersistedQueryNotFound   = "PERSISTED_QUERY_NOT_FOUND"
+	CodePersistedQueryHashFailed = "PERSISTED_QUERY_HASH_MISMATCH"
+	CodeOperationNotAllowed      = "OPERATION_NOT_ALLOWED"
+)
+
+// Error is reported in the errors of a response with its code in the extensions
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]any {
+	return map[string]any{"code": e.Code}
+}
+
+// extensions describes the error a resolver returned. A *fiber.Error, which handlers return for failures
+// the client is responsible for, keeps its status, the way handler.Handle answers it over HTTP.
+// Any other error is logged and reported as an internal error.
+func extensions(formatted gqlerrors.FormattedError) map[string]any {
+	err := originalError(formatted)
+	if err == nil {
+		return formatted.Extensions
+	}
+
+	var extended gqlerrors.ExtendedError
+	if errors.As(err, &extended) {
+		return extended.Extensions()
+	}
+
+	status := fiber.StatusInternalServerError
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &fiberErr):
+		status = fiberErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		status = fiber.StatusGatewayTimeout
+	default:
+		zap.L().Error("Failed to resolve GraphQL field", zap.Error(err), zap.Any("path", formatted.Path))
+	}
+
+	return map[string]any{
+		"code":   strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		"status": status,
+	}
+}
+
+// originalError unwraps the error a resolver returned. Errors of thunks are wrapped twice by the executor.
+func originalError(formatted gqlerrors.FormattedError) error {
+	err := formatted.OriginalError()
+	for {
+		switch wrapped := err.(type) {
+		case *gqlerrors.Error:
+			err = wrapped.OriginalError
+		case gqlerrors.FormattedError:
+			err = wrapped.OriginalError()
+		default:
+			return err
+		}
+	}
+}
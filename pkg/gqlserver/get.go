@@ -0,0 +1,37 @@
+package gqlserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetRequest is a request sent with GET, its variables and extensions are JSON in the query string.
+// Clients send persisted queries this way so that their responses can be cached by URL.
+type GetRequest struct {
+	Query         string `query:"query"`
+	OperationName string `query:"operationName"`
+	Variables     string `query:"variables"`
+	Extensions    string `query:"extensions"`
+}
+
+// GetServer serves the requests sent with GET. Only queries run, GET must not change anything.
+type GetServer struct {
+	*Server
+}
+
+func (s GetServer) Handle(ctx context.Context, req *GetRequest) (*Response, error) {
+	request := &Request{Query: req.Query, OperationName: req.OperationName}
+	if req.Variables != "" {
+		if err := json.Unmarshal([]byte(req.Variables), &request.Variables); err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "variables are not a JSON object: "+err.Error())
+		}
+	}
+	if req.Extensions != "" {
+		if err := json.Unmarshal([]byte(req.Extensions), &request.Extensions); err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "extensions are not a JSON object: "+err.Error())
+		}
+	}
+	return s.handle(ctx, request, true)
+}
@@ -0,0 +1,66 @@
+package gqlserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func testSchema(t *testing.T) graphql.Schema {
+	t.Helper()
+	hello := &graphql.Field{
+		Type:    graphql.String,
+		Resolve: func(graphql.ResolveParams) (any, error) { return "world", nil },
+	}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{"hello": hello}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{"hello": hello}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestGetPersistedQuery(t *testing.T) {
+	ctx := context.Background()
+	server := GetServer{Server: New(Config{Schema: testSchema(t)})}
+	query := "{ hello }"
+	sum := sha256.Sum256([]byte(query))
+	extensions := `{"persistedQuery":{"version":1,"sha256Hash":"` + hex.EncodeToString(sum[:]) + `"}}`
+
+	res, err := server.Handle(ctx, &GetRequest{Extensions: extensions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != CodePersistedQueryNotFound {
+		t.Fatalf("errors %v, want %s", res.Errors, CodePersistedQueryNotFound)
+	}
+
+	if res, err = server.Handle(ctx, &GetRequest{Query: query, Extensions: extensions}); err != nil || res.HasErrors() {
+		t.Fatalf("register query: %v %v", err, res.Errors)
+	}
+
+	res, err = server.Handle(ctx, &GetRequest{Extensions: extensions})
+	if err != nil || res.HasErrors() {
+		t.Fatalf("persisted query: %v %v", err, res.Errors)
+	}
+	if data := res.Data.(map[string]any); data["hello"] != "world" {
+		t.Fatalf("data %v", data)
+	}
+}
+
+func TestGetRejectsMutations(t *testing.T) {
+	server := GetServer{Server: New(Config{Schema: testSchema(t)})}
+
+	res, err := server.Handle(context.Background(), &GetRequest{Query: "mutation { hello }"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != CodeOperationNotAllowed {
+		t.Fatalf("errors %v, want %s", res.Errors, CodeOperationNotAllowed)
+	}
+}
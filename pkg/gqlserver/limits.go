@@ -0,0 +1,102 @@
+package gqlserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// pageArguments multiply the cost of the selection below a field by the number of items they ask for
+var pageArguments = []string{"limit", "first"}
+
+// checkLimits rejects operations nesting deeper than MaxDepth or costing more than MaxComplexity.
+// Every field costs one, plus the cost of its selection times the page size it asks for.
+// Introspection fields are not counted.
+func (s *Server) checkLimits(document *ast.Document, operationName string, variables map[string]any) *Error {
+	op := operation(document, operationName)
+	if op == nil {
+		// Execute reports the missing or ambiguous operation
+		return nil
+	}
+
+	walker := limitWalker{fragments: make(map[string]*ast.FragmentDefinition), variables: variables}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			walker.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	depth, complexity := walker.walk(op.SelectionSet)
+	if depth > s.config.MaxDepth {
+		return &Error{Message: fmt.Sprintf("query depth %d exceeds the maximum of %d", depth, s.config.MaxDepth), Code: CodeQueryTooComplex}
+	}
+	if complexity > s.config.MaxComplexity {
+		return &Error{Message: fmt.Sprintf("query complexity %d exceeds the maximum of %d", complexity, s.config.MaxComplexity), Code: CodeQueryTooComplex}
+	}
+	return nil
+}
+
+type limitWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// walk returns the depth and complexity of a selection set. Validation has already rejected fragment cycles.
+func (w limitWalker) walk(selectionSet *ast.SelectionSet) (depth, complexity int) {
+	if selectionSet == nil {
+		return 0, 0
+	}
+
+	for _, selection := range selectionSet.Selections {
+		var childDepth, childComplexity int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			childDepth, childComplexity = w.walk(selection.SelectionSet)
+			childDepth++
+			childComplexity = 1 + w.multiplier(selection)*childComplexity
+		case *ast.InlineFragment:
+			childDepth, childComplexity = w.walk(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := w.fragments[selection.Name.Value]; ok {
+				childDepth, childComplexity = w.walk(fragment.SelectionSet)
+			}
+		}
+		depth = max(depth, childDepth)
+		complexity += childComplexity
+	}
+	return depth, complexity
+}
+
+func (w limitWalker) multiplier(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		for _, name := range pageArguments {
+			if argument.Name.Value == name {
+				if size := w.intValue(argument.Value); size > 0 {
+					return size
+				}
+			}
+		}
+	}
+	return 1
+}
+
+func (w limitWalker) intValue(value ast.Value) int {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		size, _ := strconv.Atoi(value.Value)
+		return size
+	case *ast.Variable:
+		switch size := w.variables[value.Name.Value].(type) {
+		case float64:
+			return int(size)
+		case int:
+			return size
+		}
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package gqlserver
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// persistedQueries keeps the most recently used queries by their SHA-256 hash. Clients send the hash
+// alone and only send the full query after a PERSISTED_QUERY_NOT_FOUND error.
+type persistedQueries struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	queries  map[string]*list.Element
+}
+
+type persistedQuery struct {
+	hash  string
+	query string
+}
+
+func newPersistedQueries(capacity int) *persistedQueries {
+	return &persistedQueries{
+		capacity: capacity,
+		order:    list.New(),
+		queries:  make(map[string]*list.Element),
+	}
+}
+
+// resolve returns the query to run. A query sent along with its hash is remembered for later requests.
+func (p *persistedQueries) resolve(query string, persisted *PersistedQuery) (string, *Error) {
+	if persisted == nil {
+		if query == "" {
+			return "", &Error{Message: "query is required", Code: CodeParseFailed}
+		}
+		return query, nil
+	}
+
+	if query == "" {
+		stored, ok := p.get(persisted.SHA256Hash)
+		if !ok {
+			return "", &Error{Message: "PersistedQueryNotFound", Code: CodePersistedQueryNotFound}
+		}
+		return stored, nil
+	}
+
+	hash := sha256.Sum256([]byte(query))
+	if hex.EncodeToString(hash[:]) != persisted.SHA256Hash {
+		return "", &Error{Message: "provided sha256Hash does not match query", Code: CodePersistedQueryHashFailed}
+	}
+
+	p.put(persisted.SHA256Hash, query)
+	return query, nil
+}
+
+func (p *persistedQueries) get(hash string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.queries[hash]
+	if !ok {
+		return "", false
+	}
+	p.order.MoveToFront(element)
+	return element.Value.(*persistedQuery).query, true
+}
+
+func (p *persistedQueries) put(hash, query string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.queries[hash]; ok {
+		p.order.MoveToFront(element)
+		return
+	}
+
+	p.queries[hash] = p.order.PushFront(&persistedQuery{hash: hash, query: query})
+	if p.order.Len() > p.capacity {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.queries, oldest.Value.(*persistedQuery).hash)
+	}
+}
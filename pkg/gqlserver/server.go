@@ -0,0 +1,167 @@
+package gqlserver
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Config defines the config for the GraphQL server
+type Config struct {
+	// Schema is served by the server
+	Schema graphql.Schema
+
+	// Context prepares the context of every request, e.g. to attach per-request dataloaders
+	//
+	// Optional. Default: nil
+	Context func(ctx context.Context) context.Context
+
+	// MaxDepth is the deepest selection a query may nest
+	//
+	// Optional. Default: 10
+	MaxDepth int
+
+	// MaxComplexity is the highest cost a query may have, see complexity
+	//
+	// Optional. Default: 1000
+	MaxComplexity int
+
+	// PersistedQueries is the number of automatic persisted queries kept in memory
+	//
+	// Optional. Default: 1000
+	PersistedQueries int
+}
+
+// ConfigDefault is the default config
+var ConfigDefault = Config{
+	MaxDepth:         10,
+	MaxComplexity:    1000,
+	PersistedQueries: 1000,
+}
+
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    Extensions     `json:"extensions"`
+}
+
+type Extensions struct {
+	PersistedQuery *PersistedQuery `json:"persistedQuery"`
+}
+
+// PersistedQuery references a query by its hash, as sent by Apollo's automatic persisted queries
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+type Response = graphql.Result
+
+// Server executes GraphQL requests. It is a handler.HandlerInterface, so it mounts with handler.Handle.
+// Errors are reported in the response body, the HTTP status is 200 whenever a request could be read.
+type Server struct {
+	config    Config
+	persisted *persistedQueries
+}
+
+func New(config Config) *Server {
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = ConfigDefault.MaxDepth
+	}
+	if config.MaxComplexity <= 0 {
+		config.MaxComplexity = ConfigDefault.MaxComplexity
+	}
+	if config.PersistedQueries <= 0 {
+		config.PersistedQueries = ConfigDefault.PersistedQueries
+	}
+
+	return &Server{
+		config:    config,
+		persisted: newPersistedQueries(config.PersistedQueries),
+	}
+}
+
+func (s *Server) Handle(ctx context.Context, req *Request) (*Response, error) {
+	return s.handle(ctx, req, false)
+}
+
+// handle runs a request, queriesOnly rejects the other operations
+func (s *Server) handle(ctx context.Context, req *Request, queriesOnly bool) (*Response, error) {
+	query, queryErr := s.persisted.resolve(req.Query, req.Extensions.PersistedQuery)
+	if queryErr != nil {
+		return errorResult(queryErr), nil
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return errorResult(&Error{Message: err.Error(), Code: CodeParseFailed}), nil
+	}
+
+	if validation := graphql.ValidateDocument(&s.config.Schema, document, nil); !validation.IsValid {
+		return &Response{Errors: withCode(validation.Errors, CodeValidationFailed)}, nil
+	}
+
+	if op := operation(document, req.OperationName); queriesOnly && op != nil && op.Operation != ast.OperationTypeQuery {
+		return errorResult(&Error{Message: "only queries can be sent with GET", Code: CodeOperationNotAllowed}), nil
+	}
+
+	if limitErr := s.checkLimits(document, req.OperationName, req.Variables); limitErr != nil {
+		return errorResult(limitErr), nil
+	}
+
+	if s.config.Context != nil {
+		ctx = s.config.Context(ctx)
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.config.Schema,
+		AST:           document,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	for i := range result.Errors {
+		result.Errors[i].Extensions = extensions(result.Errors[i])
+	}
+
+	return result, nil
+}
+
+// operation finds the operation the request runs, the only one when no name is given
+func operation(document *ast.Document, name string) *ast.OperationDefinition {
+	var found *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		op, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			if found != nil && name == "" {
+				return nil
+			}
+			found = op
+		}
+	}
+	return found
+}
+
+func errorResult(err *Error) *Response {
+	formatted := gqlerrors.FormatError(err)
+	formatted.Extensions = err.Extensions()
+	return &Response{Errors: []gqlerrors.FormattedError{formatted}}
+}
+
+func withCode(errs []gqlerrors.FormattedError, code string) []gqlerrors.FormattedError {
+	for i := range errs {
+		errs[i].Extensions = map[string]any{"code": code}
+	}
+	return errs
+}
@@ -0,0 +1,63 @@
+package grpcserver
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/auth"
+	"golang-fiber-poc/pkg/feature"
+	"golang-fiber-poc/pkg/middlewares/ratelimit"
+	"golang-fiber-poc/pkg/tenant"
+	"runtime/debug"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// New creates a gRPC server with tracing, panic recovery, basic auth, tenant resolution, rate limiting and the
+// context of the feature flags, plus the health and reflection services. Health and reflection are not
+// authenticated nor limited.
+func New(users auth.Authenticator, tenants tenant.Config, limiter *ratelimit.Limiter, features *feature.Evaluator) (*grpc.Server, *health.Server) {
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			recoverInterceptor,
+			auth.UnaryServerInterceptor(users, isPublic),
+			tenant.UnaryServerInterceptor(tenants, isPublic),
+			limiter.UnaryServerInterceptor(isPublic),
+			feature.UnaryServerInterceptor(features),
+		),
+		grpc.ChainStreamInterceptor(
+			limiter.StreamServerInterceptor(isPublic),
+			feature.StreamServerInterceptor(features),
+		),
+	)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	return server, healthServer
+}
+
+func isPublic(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") ||
+		strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
+
+// recoverInterceptor turns panics into Internal errors, like the recover middleware does for HTTP
+func recoverInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			zap.L().Error("Recovered from panic", zap.String("method", info.FullMethod), zap.Any("panic", r), zap.ByteString("stack", debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,9 @@
+package handler
+
+import "io"
+
+// BodyBinder is implemented by requests that decode body formats BodyParser does not support,
+// such as NDJSON. BindBody reports whether it consumed the body.
+type BodyBinder interface {
+	BindBody(contentType string, body io.Reader) (bool, error)
+}
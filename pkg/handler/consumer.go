@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/codec"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Consume adapts a handler to broker messages, so the same handler serves HTTP and message consumers.
+// The message is decoded with the codec of its content-type header, JSON by default, and validated like a request.
+func Consume[R Request, Res Response](handler HandlerInterface[R, Res]) broker.Handler {
+	return func(ctx context.Context, message broker.Message) error {
+		contentType := message.Headers["content-type"]
+		if contentType == "" {
+			contentType = fiber.MIMEApplicationJSON
+		}
+
+		messageCodec, ok := codec.Default.ForContentType(contentType)
+		if !ok {
+			return fiber.NewError(fiber.StatusUnsupportedMediaType, "unsupported content type "+contentType)
+		}
+
+		var req R
+		if err := messageCodec.Unmarshal(message.Value, &req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		_, err := Call(ctx, handler, &req)
+		return err
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// fieldTree is a parsed ?fields= list. A node without children selects the whole value.
+type fieldTree map[string]fieldTree
+
+// parseFields parses a comma separated list of dotted paths, e.g. "id,name,owner.id"
+func parseFields(fields string) fieldTree {
+	tree := fieldTree{}
+	for _, path := range strings.Split(fields, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		node := tree
+		for _, part := range strings.Split(path, ".") {
+			child, ok := node[part]
+			if !ok {
+				child = fieldTree{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return tree
+}
+
+// selectFields prunes the response to the selected fields. For paginated responses the selection
+// applies to every item while the rest of the envelope is kept.
+func selectFields(res any, tree fieldTree) (any, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if _, ok := res.(Paginated); ok {
+		if envelope, ok := value.(map[string]any); ok {
+			envelope["items"] = prune(envelope["items"], tree)
+			return envelope, nil
+		}
+	}
+
+	return prune(value, tree), nil
+}
+
+func prune(value any, tree fieldTree) any {
+	if len(tree) == 0 {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		pruned := make(map[string]any, len(tree))
+		for field, child := range tree {
+			if fieldValue, ok := v[field]; ok {
+				pruned[field] = prune(fieldValue, child)
+			}
+		}
+		return pruned
+	case []any:
+		for i := range v {
+			v[i] = prune(v[i], tree)
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type owner struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type item struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner owner  `json:"owner"`
+}
+
+type page struct {
+	Items []item `json:"items"`
+	Next  string `json:"next"`
+}
+
+func (p *page) Cursors() (string, string) {
+	return p.Next, ""
+}
+
+func TestParseFields(t *testing.T) {
+	got := parseFields(" id, owner.id ,,owner.name,owner")
+	want := fieldTree{"id": {}, "owner": {"id": {}, "name": {}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tree %v, want %v", got, want)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	chair := item{ID: "1", Name: "chair", Owner: owner{ID: "2", Name: "acme"}}
+	tests := []struct {
+		name   string
+		res    any
+		fields string
+		want   string
+	}{
+		{name: "top level", res: &chair, fields: "id,name", want: `{"id":"1","name":"chair"}`},
+		{name: "nested", res: &chair, fields: "id,owner.id", want: `{"id":"1","owner":{"id":"2"}}`},
+		{name: "whole nested value", res: &chair, fields: "owner", want: `{"owner":{"id":"2","name":"acme"}}`},
+		{name: "unknown fields", res: &chair, fields: "id,price,owner.email", want: `{"id":"1","owner":{}}`},
+		{name: "paginated", res: &page{Items: []item{chair}, Next: "abc"}, fields: "name", want: `{"items":[{"name":"chair"}],"next":"abc"}`},
+		{name: "list", res: []item{chair}, fields: "owner.name", want: `[{"owner":{"name":"acme"}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := selectFields(tt.res, parseFields(tt.fields))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(selected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("selected %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
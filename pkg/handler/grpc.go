@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"golang-fiber-poc/pkg/log"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Unary adapts a handler to a unary gRPC method, so the same handler serves both transports.
+// toRequest and toResponse convert between the generated messages and the handler types.
+func Unary[R Request, Res Response, In, Out any](handler HandlerInterface[R, Res], toRequest func(*In) *R, toResponse func(*Res) *Out) func(context.Context, *In) (*Out, error) {
+	return func(ctx context.Context, in *In) (*Out, error) {
+		res, err := Call(ctx, handler, toRequest(in))
+		if err != nil {
+			return nil, GRPCError(ctx, err)
+		}
+
+		return toResponse(res), nil
+	}
+}
+
+// GRPCError converts a handler error into a gRPC status, the same way Handle picks the HTTP status
+func GRPCError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return status.Error(grpcCode(fiberErr.Code), fiberErr.Message)
+	}
+
+	method, _ := grpc.Method(ctx)
+	log.FromContext(ctx).Error("Failed to handle request", zap.Error(err), zap.String("method", method))
+	return status.Error(codes.Internal, err.Error())
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case fiber.StatusBadRequest, fiber.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case fiber.StatusUnauthorized:
+		return codes.Unauthenticated
+	case fiber.StatusForbidden:
+		return codes.PermissionDenied
+	case fiber.StatusNotFound:
+		return codes.NotFound
+	case fiber.StatusConflict:
+		return codes.AlreadyExists
+	case fiber.StatusPreconditionFailed, fiber.StatusFailedDependency:
+		return codes.FailedPrecondition
+	case fiber.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case fiber.StatusNotImplemented:
+		return codes.Unimplemented
+	case fiber.StatusServiceUnavailable:
+		return codes.Unavailable
+	case fiber.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
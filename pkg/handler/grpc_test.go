@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{name: "bad request", err: fiber.NewError(fiber.StatusBadRequest, "name is required"), code: codes.InvalidArgument, message: "name is required"},
+		{name: "unprocessable", err: fiber.NewError(fiber.StatusUnprocessableEntity, "invalid"), code: codes.InvalidArgument, message: "invalid"},
+		{name: "unauthorized", err: fiber.ErrUnauthorized, code: codes.Unauthenticated, message: "Unauthorized"},
+		{name: "forbidden", err: fiber.ErrForbidden, code: codes.PermissionDenied, message: "Forbidden"},
+		{name: "not found", err: fiber.NewError(fiber.StatusNotFound, "product not found"), code: codes.NotFound, message: "product not found"},
+		{name: "conflict", err: fiber.ErrConflict, code: codes.AlreadyExists, message: "Conflict"},
+		{name: "failed dependency", err: fiber.ErrFailedDependency, code: codes.FailedPrecondition, message: "Failed Dependency"},
+		{name: "rate limited", err: fiber.ErrTooManyRequests, code: codes.ResourceExhausted, message: "Too Many Requests"},
+		{name: "unavailable", err: fiber.ErrServiceUnavailable, code: codes.Unavailable, message: "Service Unavailable"},
+		{name: "gateway timeout", err: fiber.ErrGatewayTimeout, code: codes.DeadlineExceeded, message: "Gateway Timeout"},
+		{name: "unmapped status", err: fiber.ErrTeapot, code: codes.Internal, message: "I'm a teapot"},
+		{name: "wrapped fiber error", err: fmt.Errorf("get product: %w", fiber.NewError(fiber.StatusNotFound, "product not found")), code: codes.NotFound, message: "product not found"},
+		{name: "canceled", err: fmt.Errorf("query: %w", context.Canceled), code: codes.Canceled, message: "query: context canceled"},
+		{name: "deadline", err: context.DeadlineExceeded, code: codes.DeadlineExceeded, message: "context deadline exceeded"},
+		{name: "other error", err: errors.New("database down"), code: codes.Internal, message: "database down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(GRPCError(context.Background(), tt.err))
+			if !ok {
+				t.Fatal("not a gRPC status")
+			}
+			if st.Code() != tt.code || st.Message() != tt.message {
+				t.Errorf("status %s %q, want %s %q", st.Code(), st.Message(), tt.code, tt.message)
+			}
+		})
+	}
+}
+
+func TestUnary(t *testing.T) {
+	type in struct{ name string }
+	type out struct{ name string }
+	unary := Unary[echoRequest, echoResponse](&echoHandler{},
+		func(in *in) *echoRequest { return &echoRequest{Name: in.name} },
+		func(res *echoResponse) *out { return &out{name: res.Name} })
+
+	res, err := unary(context.Background(), &in{name: "chair"})
+	if err != nil || res.name != "chair" {
+		t.Fatalf("response %+v with %v, want chair", res, err)
+	}
+}
@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
+	"golang-fiber-poc/pkg/codec"
+	"golang-fiber-poc/pkg/log"
+	"io"
+	"strings"
 )
 
 type Request any
@@ -18,10 +23,42 @@ func Handle[R Request, Res Response](handler HandlerInterface[R, Res]) fiber.Han
 	return func(c *fiber.Ctx) error {
 		var req R
 
-		if err := c.BodyParser(&req); err != nil && !errors.Is(err, fiber.ErrUnprocessableEntity) {
+		// The response type is negotiated before the handler runs so that a request is not executed
+		// only to find out that its response cannot be encoded
+		var encoded any = (*Res)(nil)
+		if c.Query("fields") != "" {
+			encoded = map[string]any{}
+		}
+		contentTypes := codec.Default.ContentTypesFor(encoded)
+		contentType := c.Accepts(contentTypes...)
+		if contentType == "" {
+			return c.Status(fiber.StatusNotAcceptable).JSON(fiber.Map{"error": "supported response types are " + strings.Join(contentTypes, ", ")})
+		}
+
+		bound, err := bindBody(c, &req)
+		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 		}
 
+		if !bound {
+			bound, err = decodeBody(c, &req)
+			if err != nil {
+				return errorResponse(c, err, "Failed to decode request")
+			}
+		}
+
+		if !bound {
+			if err := c.BodyParser(&req); err != nil {
+				if !errors.Is(err, fiber.ErrUnprocessableEntity) {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+				}
+				// BodyParser does not know the content type, which only matters when there is a body
+				if len(c.Body()) > 0 {
+					return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": "unsupported content type " + c.Get(fiber.HeaderContentType)})
+				}
+			}
+		}
+
 		if err := c.ParamsParser(&req); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 		}
@@ -34,6 +71,10 @@ func Handle[R Request, Res Response](handler HandlerInterface[R, Res]) fiber.Han
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 		}
 
+		if err := validate(&req); err != nil {
+			return errorResponse(c, err, "Failed to validate request")
+		}
+
 		/*
 			ctx, cancel := context.WithTimeout(c.UserContext(), 3*time.Second)
 			defer cancel()
@@ -43,12 +84,96 @@ func Handle[R Request, Res Response](handler HandlerInterface[R, Res]) fiber.Han
 
 		res, err := handler.Handle(ctx, &req)
 		if err != nil {
-			zap.L().Error("Failed to handle request", zap.Error(err))
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			return errorResponse(c, err, "Failed to handle request")
+		}
+
+		if paginated, ok := any(res).(Paginated); ok {
+			setLinkHeader(c, paginated)
+		}
+
+		if fields := c.Query("fields"); fields != "" {
+			selected, err := selectFields(res, parseFields(fields))
+			if err != nil {
+				return errorResponse(c, err, "Failed to select response fields")
+			}
+			return writeResponse(c, contentType, selected)
+		}
+
+		return writeResponse(c, contentType, res)
+	}
+}
+
+// Call validates the request and runs the handler. Transports other than Fiber use it to share
+// the validation of Handle.
+func Call[R Request, Res Response](ctx context.Context, handler HandlerInterface[R, Res], req *R) (*Res, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	return handler.Handle(ctx, req)
+}
+
+// errorResponse writes the status of a *fiber.Error, which handlers return for failures the client
+// is responsible for. Any other error is logged with the context of the request and answered with 500.
+func errorResponse(c *fiber.Ctx, err error, message string) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message})
+	}
+
+	log.FromContext(c.UserContext()).Error(message, zap.Error(err), zap.String("method", c.Method()), zap.String("route", c.Route().Path))
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+}
+
+// decodeBody decodes bodies of the binary codecs. JSON, XML and forms are left to BodyParser.
+func decodeBody(c *fiber.Ctx, req any) (bool, error) {
+	bodyCodec, ok := codec.Default.ForContentType(c.Get(fiber.HeaderContentType))
+	if !ok || bodyCodec.ContentType() == fiber.MIMEApplicationJSON {
+		return false, nil
+	}
+
+	if err := bodyCodec.Unmarshal(c.Body(), req); err != nil {
+		if errors.Is(err, codec.ErrUnsupportedType) {
+			return false, fiber.NewError(fiber.StatusUnsupportedMediaType, "this endpoint does not accept "+bodyCodec.ContentType())
 		}
+		return false, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return true, nil
+}
 
+// writeResponse encodes the response with the codec negotiated from Accept.
+// JSON goes through fiber so that the encoder configured in fiber.Config is used.
+func writeResponse(c *fiber.Ctx, contentType string, res any) error {
+	if contentType == fiber.MIMEApplicationJSON {
 		return c.JSON(res)
 	}
+
+	responseCodec, _ := codec.Default.ForContentType(contentType)
+	body, err := responseCodec.Marshal(res)
+	if err != nil {
+		if errors.Is(err, codec.ErrUnsupportedType) {
+			return c.Status(fiber.StatusNotAcceptable).JSON(fiber.Map{"error": "this response cannot be encoded as " + contentType})
+		}
+		return errorResponse(c, err, "Failed to encode response")
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(body)
+}
+
+// bindBody lets requests implementing BodyBinder decode the body themselves.
+// The body is streamed when the app runs with StreamRequestBody.
+func bindBody(c *fiber.Ctx, req any) (bool, error) {
+	binder, ok := req.(BodyBinder)
+	if !ok {
+		return false, nil
+	}
+
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	return binder.BindBody(c.Get(fiber.HeaderContentType), body)
 }
 
 //For V3
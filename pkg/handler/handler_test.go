@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type echoRequest struct {
+	Name string `json:"name"`
+}
+
+type echoResponse struct {
+	Name string `json:"name"`
+}
+
+type echoHandler struct {
+	calls int
+}
+
+func (h *echoHandler) Handle(_ context.Context, req *echoRequest) (*echoResponse, error) {
+	h.calls++
+	return &echoResponse{Name: req.Name}, nil
+}
+
+func TestHandleNegotiatesBeforeHandling(t *testing.T) {
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		calls       int
+	}{
+		{accept: "application/x-protobuf", status: fiber.StatusNotAcceptable, calls: 0},
+		{accept: "application/x-protobuf, application/json;q=0.5", status: fiber.StatusOK, contentType: fiber.MIMEApplicationJSON, calls: 1},
+		{accept: "application/msgpack", status: fiber.StatusOK, contentType: "application/msgpack", calls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			h := &echoHandler{}
+			app := fiber.New()
+			app.Get("/echo", Handle[echoRequest, echoResponse](h))
+
+			req := httptest.NewRequest(fiber.MethodGet, "/echo", nil)
+			req.Header.Set(fiber.HeaderAccept, tt.accept)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.contentType != "" && resp.Header.Get(fiber.HeaderContentType) != tt.contentType {
+				t.Fatalf("content type %q, want %q", resp.Header.Get(fiber.HeaderContentType), tt.contentType)
+			}
+			if h.calls != tt.calls {
+				t.Fatalf("handler called %d times, want %d", h.calls, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest is embedded in the requests of collection endpoints and bound by QueryParser
+type PageRequest struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+	// Sort is a comma separated list of fields, prefixed with '-' for descending order, e.g. "-score,name"
+	Sort string `query:"sort"`
+}
+
+type PageLimits struct {
+	Default int
+	Max     int
+}
+
+var DefaultPageLimits = PageLimits{Default: 20, Max: 100}
+
+// PageSize returns the requested limit or the default one. A limit out of bounds is a 400.
+func (p PageRequest) PageSize(limits PageLimits) (int, error) {
+	switch {
+	case p.Limit == 0:
+		return limits.Default, nil
+	case p.Limit < 0 || p.Limit > limits.Max:
+		return 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", limits.Max))
+	}
+	return p.Limit, nil
+}
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ParseSort parses the sort spec, rejecting fields that are not allowed with a 400
+func (p PageRequest) ParseSort(allowed []string) ([]SortField, error) {
+	var fields []SortField
+	for _, field := range strings.Split(p.Sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		sortField := SortField{Field: strings.TrimPrefix(field, "-"), Descending: strings.HasPrefix(field, "-")}
+		if !slices.Contains(allowed, sortField.Field) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("cannot sort by %q, allowed fields are %s", sortField.Field, strings.Join(allowed, ", ")))
+		}
+		fields = append(fields, sortField)
+	}
+	return fields, nil
+}
+
+// CursorCodec turns a position into an opaque cursor signed with HMAC-SHA256, so clients cannot forge positions
+type CursorCodec struct {
+	secret []byte
+}
+
+// NewCursorCodec creates a codec with the given secret. Without a secret a random one is generated,
+// which means cursors do not survive restarts and are not shared between instances.
+func NewCursorCodec(secret []byte) *CursorCodec {
+	if len(secret) == 0 {
+		zap.L().Warn("No pagination secret configured, cursors are only valid for this instance")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Errorf("failed to generate pagination secret: %w", err))
+		}
+	}
+	return &CursorCodec{secret: secret}
+}
+
+func (c *CursorCodec) Encode(position any) (string, error) {
+	payload, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode verifies the cursor and unmarshals its position. Malformed or tampered cursors are a 400.
+func (c *CursorCodec) Decode(cursor string, position any) error {
+	invalid := fiber.NewError(fiber.StatusBadRequest, ErrInvalidCursor.Error())
+
+	encodedPayload, encodedSignature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return invalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return invalid
+	}
+	if err := json.Unmarshal(payload, position); err != nil {
+		return invalid
+	}
+	return nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Page is the envelope of collection responses
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+func (p *Page[T]) Cursors() (string, string) {
+	return p.Next, p.Prev
+}
+
+// Paginated is implemented by responses that carry cursors, Handle turns them into a Link header
+type Paginated interface {
+	Cursors() (next string, prev string)
+}
+
+func setLinkHeader(c *fiber.Ctx, paginated Paginated) {
+	next, prev := paginated.Cursors()
+
+	var links []string
+	if next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(c, next)))
+	}
+	if prev != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(c, prev)))
+	}
+	if len(links) > 0 {
+		c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+}
+
+// pageURL is the current URL with the cursor replaced
+func pageURL(c *fiber.Ctx, cursor string) string {
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	c.Request().URI().QueryArgs().CopyTo(args)
+	args.Set("cursor", cursor)
+
+	return c.BaseURL() + c.Path() + "?" + args.String()
+}
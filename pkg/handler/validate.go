@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"golang-fiber-poc/pkg/customvalidator"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+var structValidator = newStructValidator()
+
+func newStructValidator() *customvalidator.StructValidator {
+	validation := validator.New(validator.WithRequiredStructEnabled())
+	// Report the names clients know from the JSON body
+	validation.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+	return &customvalidator.StructValidator{Validation: validation}
+}
+
+// validate checks the `validate` tags of the request. Failures are a 400 listing every invalid field.
+func validate(req any) error {
+	err := structValidator.Validate(req)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		// Requests that are not structs have nothing to validate
+		var invalidValidationError *validator.InvalidValidationError
+		if errors.As(err, &invalidValidationError) {
+			return nil
+		}
+		return err
+	}
+
+	messages := make([]string, len(validationErrors))
+	for i, fieldError := range validationErrors {
+		messages[i] = fmt.Sprintf("%s failed on the '%s' rule", fieldError.Field(), fieldError.Tag())
+	}
+	return fiber.NewError(fiber.StatusBadRequest, strings.Join(messages, "; "))
+}
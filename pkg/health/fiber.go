@@ -0,0 +1,29 @@
+package health
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const MIMEHealthJSON = "application/health+json"
+
+// Handler answers a probe with the report of its checks, with 503 when it fails
+func Handler(registry *Registry, probe Probe) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := registry.Run(c.UserContext(), probe)
+
+		body, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+
+		status := fiber.StatusOK
+		if report.Status == StatusFail {
+			status = fiber.StatusServiceUnavailable
+		}
+		c.Set(fiber.HeaderContentType, MIMEHealthJSON)
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.Status(status).Send(body)
+	}
+}
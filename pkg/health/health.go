@@ -0,0 +1,216 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a check and of a probe, as in the health check response format for HTTP APIs
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Probe is a set of the probes a check takes part in
+type Probe uint8
+
+const (
+	// Liveness fails when the process must be restarted
+	Liveness Probe = 1 << iota
+	// Readiness fails when the process must not get traffic
+	Readiness
+	// Startup fails until the process has started
+	Startup
+)
+
+// Check returns nil when a component is healthy
+type Check func(ctx context.Context) error
+
+// Config defines the config of a check
+type Config struct {
+	// Probes the check takes part in
+	//
+	// Optional. Default: Readiness
+	Probes Probe
+
+	// Critical checks fail their probes, the others only turn them to warn
+	//
+	// Optional. Default: false
+	Critical bool
+
+	// Timeout bounds one run of the check
+	//
+	// Optional. Default: 2 * time.Second
+	Timeout time.Duration
+
+	// CacheTTL keeps the result of the check, so that frequent probes do not load the component
+	//
+	// Optional. Default: 0
+	CacheTTL time.Duration
+
+	// ComponentType describes the component, e.g. "datastore" or "component"
+	//
+	// Optional. Default: "component"
+	ComponentType string
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status        string    `json:"status"`
+	ComponentType string    `json:"componentType,omitempty"`
+	Output        string    `json:"output,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// Report is the outcome of a probe. Checks has one result per component.
+type Report struct {
+	Status string              `json:"status"`
+	Output string              `json:"output,omitempty"`
+	Checks map[string][]Result `json:"checks,omitempty"`
+}
+
+// Registry runs the checks of the components for the probes
+type Registry struct {
+	mu     sync.Mutex
+	checks map[string]*registered
+
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+type registered struct {
+	check  Check
+	config Config
+
+	mu     sync.Mutex
+	result Result
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]*registered)}
+}
+
+// Register adds the check of a component, replacing the one with the same name
+func (r *Registry) Register(name string, check Check, config Config) {
+	if config.Probes == 0 {
+		config.Probes = Readiness
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Second
+	}
+	if config.ComponentType == "" {
+		config.ComponentType = "component"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks[name] = &registered{check: check, config: config}
+}
+
+// Started marks the end of the startup, the startup probe fails until then
+func (r *Registry) Started() {
+	r.started.Store(true)
+}
+
+// ShuttingDown fails the readiness probe, so that no new traffic is sent while the process stops
+func (r *Registry) ShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Run runs the checks of a probe concurrently
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.checks))
+	for name, check := range r.checks {
+		if check.config.Probes&probe != 0 {
+			names = append(names, name)
+		}
+	}
+	checks := make([]*registered, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusPass, Checks: make(map[string][]Result, len(checks))}
+	for i, name := range names {
+		report.Checks[name] = []Result{results[i]}
+		switch {
+		case results[i].Status == StatusPass:
+		case checks[i].config.Critical:
+			report.Status = StatusFail
+		case report.Status == StatusPass:
+			report.Status = StatusWarn
+		}
+	}
+
+	switch {
+	case probe == Startup && !r.started.Load():
+		report.Status, report.Output = StatusFail, "starting"
+	case probe == Readiness && r.shuttingDown.Load():
+		report.Status, report.Output = StatusFail, "shutting down"
+	}
+	return report
+}
+
+func (c *registered) run(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.config.CacheTTL > 0 && time.Since(c.result.Time) < c.config.CacheTTL {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	result := Result{Status: StatusPass, ComponentType: c.config.ComponentType, Time: time.Now()}
+	if err := c.call(ctx); err != nil {
+		result.Output = err.Error()
+		result.Status = StatusWarn
+		if c.config.Critical {
+			result.Status = StatusFail
+		}
+	}
+	c.result = result
+	return result
+}
+
+// call runs the check, giving up when it does not return within the timeout
+func (c *registered) call(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.New("check timed out")
+	}
+}
@@ -0,0 +1,154 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func pass(context.Context) error { return nil }
+
+func fail(context.Context) error { return errors.New("down") }
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  map[string]Config
+		failing map[string]bool
+		probe   Probe
+		want    string
+	}{
+		{name: "no checks", probe: Readiness, want: StatusPass},
+		{name: "passing", checks: map[string]Config{"db": {Critical: true}}, probe: Readiness, want: StatusPass},
+		{name: "critical failure", checks: map[string]Config{"db": {Critical: true}, "cache": {}}, failing: map[string]bool{"db": true}, probe: Readiness, want: StatusFail},
+		{name: "warning failure", checks: map[string]Config{"db": {Critical: true}, "cache": {}}, failing: map[string]bool{"cache": true}, probe: Readiness, want: StatusWarn},
+		{name: "critical wins over warning", checks: map[string]Config{"db": {Critical: true}, "cache": {}}, failing: map[string]bool{"db": true, "cache": true}, probe: Readiness, want: StatusFail},
+		{name: "other probe", checks: map[string]Config{"db": {Critical: true, Probes: Liveness}}, failing: map[string]bool{"db": true}, probe: Readiness, want: StatusPass},
+		{name: "several probes", checks: map[string]Config{"db": {Critical: true, Probes: Liveness | Readiness}}, failing: map[string]bool{"db": true}, probe: Liveness, want: StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Started()
+			for name, config := range tt.checks {
+				check := pass
+				if tt.failing[name] {
+					check = fail
+				}
+				registry.Register(name, check, config)
+			}
+
+			report := registry.Run(context.Background(), tt.probe)
+			if report.Status != tt.want {
+				t.Errorf("status %s, want %s", report.Status, tt.want)
+			}
+			for name, failing := range tt.failing {
+				results, ok := report.Checks[name]
+				if failing && ok && results[0].Output != "down" {
+					t.Errorf("output of %s %q, want down", name, results[0].Output)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCheckFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		check  Check
+		output string
+	}{
+		{name: "timeout", check: func(ctx context.Context) error { <-ctx.Done(); time.Sleep(time.Second); return nil }, output: "check timed out"},
+		{name: "panic", check: func(context.Context) error { panic("boom") }, output: "check panicked: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Register("db", tt.check, Config{Critical: true, Timeout: 10 * time.Millisecond})
+
+			start := time.Now()
+			report := registry.Run(context.Background(), Readiness)
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("probe took %s", elapsed)
+			}
+			if report.Status != StatusFail || report.Checks["db"][0].Output != tt.output {
+				t.Errorf("report %+v, want fail with %q", report, tt.output)
+			}
+		})
+	}
+}
+
+func TestRunCachesResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		cacheTTL time.Duration
+		want     int32
+	}{
+		{name: "cached", cacheTTL: time.Minute, want: 1},
+		{name: "not cached", cacheTTL: 0, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			registry := NewRegistry()
+			registry.Register("db", func(context.Context) error { calls.Add(1); return nil }, Config{CacheTTL: tt.cacheTTL})
+
+			for range 3 {
+				registry.Run(context.Background(), Readiness)
+			}
+			if got := calls.Load(); got != tt.want {
+				t.Errorf("check ran %d times, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunLifecycle(t *testing.T) {
+	registry := NewRegistry()
+	if report := registry.Run(context.Background(), Startup); report.Status != StatusFail {
+		t.Errorf("startup %s before Started, want fail", report.Status)
+	}
+	registry.Started()
+	if report := registry.Run(context.Background(), Startup); report.Status != StatusPass {
+		t.Errorf("startup %s after Started, want pass", report.Status)
+	}
+	registry.ShuttingDown()
+	if report := registry.Run(context.Background(), Readiness); report.Status != StatusFail {
+		t.Errorf("readiness %s while shutting down, want fail", report.Status)
+	}
+	if report := registry.Run(context.Background(), Liveness); report.Status != StatusPass {
+		t.Errorf("liveness %s while shutting down, want pass", report.Status)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		critical bool
+		status   int
+	}{
+		{name: "warn", critical: false, status: fiber.StatusOK},
+		{name: "fail", critical: true, status: fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Register("db", fail, Config{Critical: tt.critical})
+			app := fiber.New()
+			app.Get("/readyz", Handler(registry, Readiness))
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/readyz", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || resp.Header.Get(fiber.HeaderContentType) != MIMEHealthJSON {
+				t.Errorf("status %d with %s, want %d with %s", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType), tt.status, MIMEHealthJSON)
+			}
+		})
+	}
+}
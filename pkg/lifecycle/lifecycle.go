@@ -0,0 +1,231 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Hook starts and stops a component
+type Hook struct {
+	Name string
+
+	// DependsOn names the components that start before this one and stop after it
+	DependsOn []string
+
+	// OnStart starts the component and returns once it is running. Components that fail later report
+	// it with Manager.Fail.
+	//
+	// Optional. Default: nil
+	OnStart func(ctx context.Context) error
+
+	// OnStop stops the component. It is given up on when it does not return within StopTimeout.
+	//
+	// Optional. Default: nil
+	OnStop func(ctx context.Context) error
+
+	// StopTimeout is the deadline of OnStop
+	//
+	// Optional. Default: 5 * time.Second
+	StopTimeout time.Duration
+}
+
+// Config defines the config for a manager
+type Config struct {
+	// Signals stop the components
+	//
+	// Optional. Default: os.Interrupt, syscall.SIGTERM
+	Signals []os.Signal
+
+	// StartTimeout is the deadline of every OnStart
+	//
+	// Optional. Default: 30 * time.Second
+	StartTimeout time.Duration
+}
+
+// Manager starts the components in the order of their dependencies and stops them in reverse order
+// on a signal or when one of them fails
+type Manager struct {
+	config Config
+	hooks  []Hook
+	failed chan error
+}
+
+func New(config Config) *Manager {
+	if len(config.Signals) == 0 {
+		config.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if config.StartTimeout <= 0 {
+		config.StartTimeout = 30 * time.Second
+	}
+
+	return &Manager{config: config, failed: make(chan error, 1)}
+}
+
+// Append adds a component. Components without dependencies between them start in the order they are added.
+func (m *Manager) Append(hook Hook) {
+	if hook.StopTimeout <= 0 {
+		hook.StopTimeout = 5 * time.Second
+	}
+	m.hooks = append(m.hooks, hook)
+}
+
+// Fail stops the components, Run returns err. Only the first failure is kept.
+func (m *Manager) Fail(err error) {
+	select {
+	case m.failed <- err:
+	default:
+	}
+}
+
+// Run starts the components, waits for a signal or a failure and stops the components that started.
+// It returns the error of the failure or of the start that failed.
+func (m *Manager) Run() error {
+	order, err := m.order()
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, m.config.Signals...)
+	defer signal.Stop(signals)
+
+	started := 0
+	for _, hook := range order {
+		if err = m.start(hook); err != nil {
+			break
+		}
+		started++
+	}
+
+	if err == nil {
+		select {
+		case sig := <-signals:
+			zap.L().Info("Received signal, stopping", zap.String("signal", sig.String()))
+		case err = <-m.failed:
+			zap.L().Error("Component failed, stopping", zap.Error(err))
+		}
+	}
+
+	for i := started - 1; i >= 0; i-- {
+		m.stop(order[i])
+	}
+	return err
+}
+
+func (m *Manager) start(hook Hook) error {
+	if hook.OnStart == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.StartTimeout)
+	defer cancel()
+
+	if err := hook.OnStart(ctx); err != nil {
+		zap.L().Error("Failed to start component", zap.String("component", hook.Name), zap.Error(err))
+		return fmt.Errorf("start %s: %w", hook.Name, err)
+	}
+	return nil
+}
+
+func (m *Manager) stop(hook Hook) {
+	if hook.OnStop == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hook.StopTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- hook.OnStop(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			zap.L().Error("Failed to stop component", zap.String("component", hook.Name), zap.Error(err))
+		}
+	case <-ctx.Done():
+		zap.L().Error("Gave up stopping component", zap.String("component", hook.Name), zap.Duration("timeout", hook.StopTimeout))
+	}
+}
+
+// order sorts the hooks so that every hook comes after its dependencies
+func (m *Manager) order() ([]Hook, error) {
+	byName := make(map[string]int, len(m.hooks))
+	for i, hook := range m.hooks {
+		if _, ok := byName[hook.Name]; ok {
+			return nil, fmt.Errorf("component %s is added twice", hook.Name)
+		}
+		byName[hook.Name] = i
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make([]int, len(m.hooks))
+	order := make([]Hook, 0, len(m.hooks))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle through component %s", m.hooks[i].Name)
+		}
+		state[i] = visiting
+		for _, name := range m.hooks[i].DependsOn {
+			dependency, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("component %s depends on unknown component %s", m.hooks[i].Name, name)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		order = append(order, m.hooks[i])
+		return nil
+	}
+
+	for i := range m.hooks {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Background fills the hooks of a component that runs until its ctx is done. OnStop cancels the ctx and
+// waits for run to return.
+func Background(hook Hook, run func(ctx context.Context)) Hook {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	hook.OnStart = func(context.Context) error {
+		go func() {
+			defer close(done)
+			run(ctx)
+		}()
+		return nil
+	}
+	hook.OnStop = func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return errors.New("did not stop in time")
+		}
+	}
+	return hook
+}
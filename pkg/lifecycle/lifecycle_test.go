@@ -0,0 +1,131 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name string
+		// hooks are name:dependency,dependency, the failing hook fails to start
+		hooks   []string
+		failing string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "dependencies first",
+			hooks: []string{"http:db,broker", "db", "broker:db"},
+			want:  []string{"start db", "start broker", "start http", "stop http", "stop broker", "stop db"},
+		},
+		{
+			name:  "order of addition without dependencies",
+			hooks: []string{"a", "b", "c"},
+			want:  []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"},
+		},
+		{
+			name:    "rollback of a failed start",
+			hooks:   []string{"db", "broker:db", "http:broker"},
+			failing: "broker",
+			want:    []string{"start db", "start broker", "stop db"},
+			wantErr: "start broker: failed",
+		},
+		{
+			name:    "cycle",
+			hooks:   []string{"a:b", "b:a"},
+			wantErr: "dependency cycle",
+		},
+		{
+			name:    "unknown dependency",
+			hooks:   []string{"a:b"},
+			wantErr: "depends on unknown component b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := New(Config{})
+			var calls []string
+			for _, spec := range tt.hooks {
+				name, dependencies, _ := strings.Cut(spec, ":")
+				hook := Hook{Name: name}
+				if dependencies != "" {
+					hook.DependsOn = strings.Split(dependencies, ",")
+				}
+				hook.OnStart = func(context.Context) error {
+					calls = append(calls, "start "+name)
+					if name == tt.failing {
+						return errFailed
+					}
+					return nil
+				}
+				hook.OnStop = func(context.Context) error {
+					calls = append(calls, "stop "+name)
+					return nil
+				}
+				manager.Append(hook)
+			}
+			// Every component started, stop them as if one failed later
+			if tt.failing == "" {
+				manager.Append(Hook{Name: "stopper", OnStart: func(context.Context) error {
+					manager.Fail(errors.New("stopped"))
+					return nil
+				}})
+			}
+
+			err := manager.Run()
+			switch {
+			case tt.wantErr == "" && (err == nil || err.Error() != "stopped"):
+				t.Fatalf("error %v, want the failure that stopped the components", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("error %v, want %s", err, tt.wantErr)
+			}
+			if !slices.Equal(calls, tt.want) {
+				t.Errorf("calls %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	manager := New(Config{})
+	manager.Append(Hook{Name: "stuck", StopTimeout: 10 * time.Millisecond, OnStop: func(context.Context) error {
+		select {}
+	}})
+	manager.Append(Hook{Name: "stopper", OnStart: func(context.Context) error {
+		manager.Fail(errors.New("stopped"))
+		return nil
+	}})
+
+	done := make(chan error, 1)
+	go func() { done <- manager.Run() }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run waited for a component past its stop timeout")
+	}
+}
+
+func TestBackground(t *testing.T) {
+	stopped := make(chan struct{})
+	hook := Background(Hook{Name: "relay"}, func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	if err := hook.OnStart(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.OnStop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("OnStop returned before run")
+	}
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"context"
+
+	"golang-fiber-poc/pkg/auth"
+	"golang-fiber-poc/pkg/tenant"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+// WithLogger stores the logger of a request, like one with its request id, for FromContext
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of the request of ctx, or the global logger, with the trace and span ids, the
+// principal and the tenant of ctx
+func FromContext(ctx context.Context) *zap.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*zap.Logger)
+	if !ok {
+		logger = zap.L()
+	}
+	return logger.With(Fields(ctx)...)
+}
+
+// Fields returns the trace and span ids, the principal and the tenant of ctx
+func Fields(ctx context.Context) []zap.Field {
+	var fields []zap.Field
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields = append(fields, zap.String("trace_id", spanContext.TraceID().String()), zap.String("span_id", spanContext.SpanID().String()))
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		fields = append(fields, zap.String("principal", principal))
+	}
+	if tenantID, ok := tenant.FromContext(ctx); ok {
+		fields = append(fields, zap.String("tenant", tenantID))
+	}
+	return fields
+}
@@ -0,0 +1,154 @@
+package log
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// packages holds the levels of the packages that do not log at Level
+var packages atomic.Pointer[map[string]zapcore.Level]
+
+// levelCore enables the entries at the level of the package that logs them, found from the caller, or at Level
+type levelCore struct {
+	zapcore.Core
+}
+
+func (c levelCore) Enabled(level zapcore.Level) bool {
+	if Level.Enabled(level) {
+		return true
+	}
+	for _, l := range packageLevels() {
+		if l.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields)}
+}
+
+func (c levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write drops the entries below the level of their package, the caller is only known once they are checked
+func (c levelCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if !levelOf(entry.Caller.Function).Enabled(entry.Level) {
+		return nil
+	}
+	return c.Core.Write(entry, fields)
+}
+
+func packageLevels() map[string]zapcore.Level {
+	if levels := packages.Load(); levels != nil {
+		return *levels
+	}
+	return nil
+}
+
+// levelOf returns the level of the package of a function like "golang-fiber-poc/infra/couchbase.(*Repository).Get".
+// The longest configured package that matches wins.
+func levelOf(function string) zapcore.LevelEnabler {
+	levels := packageLevels()
+	if len(levels) == 0 || function == "" {
+		return Level
+	}
+
+	pkg := function
+	slash := strings.LastIndexByte(pkg, '/')
+	if dot := strings.IndexByte(pkg[slash+1:], '.'); dot >= 0 {
+		pkg = pkg[:slash+1+dot]
+	}
+
+	var enabler zapcore.LevelEnabler = Level
+	matched := ""
+	for name, level := range levels {
+		if (pkg == name || strings.HasSuffix(pkg, "/"+name)) && len(name) > len(matched) {
+			enabler, matched = level, name
+		}
+	}
+	return enabler
+}
+
+const redacted = "<redacted>"
+
+// redactingCore replaces the values of the fields with sensitive keys
+type redactingCore struct {
+	zapcore.Core
+	keys map[string]bool
+}
+
+func redactCore(core zapcore.Core, keys []string) zapcore.Core {
+	if len(keys) == 0 {
+		return core
+	}
+	sensitive := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		sensitive[strings.ToLower(key)] = true
+	}
+	return redactingCore{Core: core, keys: sensitive}
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{Core: c.Core.With(c.redact(fields)), keys: c.keys}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.redact(fields))
+}
+
+func (c redactingCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var redactedFields []zapcore.Field
+	for i, field := range fields {
+		if !c.keys[strings.ToLower(field.Key)] {
+			continue
+		}
+		if redactedFields == nil {
+			redactedFields = append([]zapcore.Field(nil), fields...)
+		}
+		redactedFields[i] = zap.String(field.Key, redacted)
+	}
+	if redactedFields == nil {
+		return fields
+	}
+	return redactedFields
+}
+
+// SetPackageLevel changes the level of a package, an empty level makes the package log at Level again
+func SetPackageLevel(name string, level string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	levels := make(map[string]zapcore.Level)
+	for key, l := range packageLevels() {
+		levels[key] = l
+	}
+	name = strings.Trim(name, "/")
+	if level == "" {
+		delete(levels, name)
+	} else {
+		parsed, err := zapcore.ParseLevel(level)
+		if err != nil {
+			return err
+		}
+		levels[name] = parsed
+	}
+	packages.Store(&levels)
+	zap.L().Info("Changed package log level", zap.String("package", name), zap.String("level", level))
+	return nil
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelRequest changes the level of a package, or Level when Package is empty
+type LevelRequest struct {
+	Package string `json:"package"`
+	Level   string `json:"level"`
+}
+
+type levelsResponse struct {
+	Level    string            `json:"level"`
+	Packages map[string]string `json:"packages"`
+}
+
+// LevelHandler answers the levels to GET, and changes one with a LevelRequest sent with PUT
+func LevelHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodPut {
+			var req LevelRequest
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+
+			var err error
+			if req.Package == "" {
+				err = SetLevel(req.Level)
+			} else {
+				err = SetPackageLevel(req.Package, req.Level)
+			}
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
+
+		return c.JSON(levelsResponse{Level: Level.Level().String(), Packages: levelNames(packageLevels())})
+	}
+}
+
+func levelNames(levels map[string]zapcore.Level) map[string]string {
+	names := make(map[string]string, len(levels))
+	for name, level := range levels {
+		names[name] = level.String()
+	}
+	return names
+}
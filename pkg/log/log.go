@@ -1,32 +1,223 @@
 package log
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/bridges/otelzap"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"os"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-func init() {
+// Level is the level of the global logger, it can be changed while the application runs
+var Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+// Config defines how the global logger writes
+type Config struct {
+	// Level is the level of the packages that Packages does not set
+	//
+	// Optional. Default: "info"
+	Level string
+
+	// Format is "json" or "console"
+	//
+	// Optional. Default: "json"
+	Format string
+
+	// Outputs are "stdout", "stderr" or the paths of files, which are rotated
+	//
+	// Optional. Default: stderr
+	Outputs  []string
+	Rotation Rotation
+
+	// Sampling logs the first Initial entries with the same level and message every second, then every
+	// Thereafter-th of them
+	//
+	// Optional. Default: no sampling
+	Sampling Sampling
+
+	// Packages sets the level of packages, by import path or its last elements like "infra/couchbase"
+	//
+	// Optional. Default: nil
+	Packages map[string]string
+
+	// Redact replaces the values of the fields with these keys, case-insensitively
+	//
+	// Optional. Default: nil
+	Redact []string
+
+	// OTLPEndpoint exports the entries to an OpenTelemetry collector over OTLP/HTTP, "" disables it
+	//
+	// Optional. Default: ""
+	OTLPEndpoint string
+}
+
+// Rotation rotates the files that are written to
+type Rotation struct {
+	// MaxSize is the size in megabytes a file is rotated at, 100 when it is 0
+	MaxSize int
+	// MaxAge is the number of days rotated files are kept, 0 keeps them
+	MaxAge int
+	// MaxBackups is the number of rotated files kept, 0 keeps them
+	MaxBackups int
+	// Compress gzips the rotated files
+	Compress bool
+}
+
+type Sampling struct {
+	Initial    int
+	Thereafter int
+}
+
+var (
+	// mu guards closers and the changes of packages
+	mu      sync.Mutex
+	closers []func(ctx context.Context) error
+)
+
+// Configure replaces the global logger. It can be called again, the outputs of the previous logger are closed.
+func Configure(config Config) error {
+	if config.Level == "" {
+		config.Level = zap.InfoLevel.String()
+	}
+	level, err := zapcore.ParseLevel(config.Level)
+	if err != nil {
+		return err
+	}
+	levels, err := parsePackages(config.Packages)
+	if err != nil {
+		return err
+	}
+
 	encoderCfg := zap.NewProductionEncoderConfig()
 	encoderCfg.TimeKey = "timestamp"
 	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	var encoder zapcore.Encoder
+	switch config.Format {
+	case "", "json":
+		encoder = zapcore.NewJSONEncoder(encoderCfg)
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	default:
+		return fmt.Errorf("unknown log format %q", config.Format)
+	}
 
-	config := zap.Config{
-		Level:             zap.NewAtomicLevelAt(zap.InfoLevel),
-		Development:       false,
-		DisableCaller:     false,
-		DisableStacktrace: false,
-		Sampling:          nil,
-		Encoding:          "json",
-		EncoderConfig:     encoderCfg,
-		OutputPaths:       []string{"stderr"},
-		ErrorOutputPaths:  []string{"stderr"},
-		InitialFields: map[string]interface{}{
-			"pid": os.Getpid(),
-		},
+	// The cores write every entry, levelCore decides which are enabled
+	all := zap.LevelEnablerFunc(func(zapcore.Level) bool { return true })
+
+	var next []func(ctx context.Context) error
+	outputs := config.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{"stderr"}
+	}
+	writers := make([]zapcore.WriteSyncer, 0, len(outputs))
+	for _, output := range outputs {
+		switch output {
+		case "stdout":
+			writers = append(writers, zapcore.Lock(os.Stdout))
+		case "stderr":
+			writers = append(writers, zapcore.Lock(os.Stderr))
+		default:
+			file := &lumberjack.Logger{
+				Filename:   output,
+				MaxSize:    config.Rotation.MaxSize,
+				MaxAge:     config.Rotation.MaxAge,
+				MaxBackups: config.Rotation.MaxBackups,
+				Compress:   config.Rotation.Compress,
+			}
+			writers = append(writers, zapcore.AddSync(file))
+			next = append(next, closer(file))
+		}
+	}
+	cores := []zapcore.Core{zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(writers...), all)}
+
+	if config.OTLPEndpoint != "" {
+		exporter, err := otlploghttp.New(context.Background(), otlploghttp.WithEndpoint(config.OTLPEndpoint), otlploghttp.WithInsecure())
+		if err != nil {
+			return fmt.Errorf("create log exporter: %w", err)
+		}
+		provider := sdklog.NewLoggerProvider(
+			sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+			sdklog.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("golang-fiber-poc"))),
+		)
+		cores = append(cores, otelzap.NewCore("golang-fiber-poc", otelzap.WithLoggerProvider(provider)))
+		next = append(next, provider.Shutdown)
+	}
+
+	var core zapcore.Core = levelCore{Core: redactCore(zapcore.NewTee(cores...), config.Redact)}
+	if config.Sampling.Initial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, config.Sampling.Initial, config.Sampling.Thereafter)
 	}
 
-	logger := zap.Must(config.Build())
+	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.ErrorLevel), zap.ErrorOutput(zapcore.Lock(os.Stderr))).
+		With(zap.Int("pid", os.Getpid()))
 
+	mu.Lock()
+	previous := closers
+	closers = next
+	packages.Store(&levels)
+	mu.Unlock()
+
+	Level.SetLevel(level)
 	zap.ReplaceGlobals(logger)
+	return closeAll(context.Background(), previous)
+}
+
+// Shutdown flushes the entries that are not exported yet and closes the outputs
+func Shutdown(ctx context.Context) error {
+	_ = zap.L().Sync()
+
+	mu.Lock()
+	previous := closers
+	closers = nil
+	mu.Unlock()
+	return closeAll(ctx, previous)
+}
+
+func closeAll(ctx context.Context, closers []func(ctx context.Context) error) error {
+	var errs []error
+	for _, closer := range closers {
+		errs = append(errs, closer(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func closer(c io.Closer) func(context.Context) error {
+	return func(context.Context) error { return c.Close() }
+}
+
+// SetLevel changes the level of the global logger, level is a name like "debug" or "info"
+func SetLevel(level string) error {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if parsed != Level.Level() {
+		zap.L().Info("Changed log level", zap.Stringer("from", Level.Level()), zap.Stringer("to", parsed))
+		Level.SetLevel(parsed)
+	}
+	return nil
+}
+
+func parsePackages(levels map[string]string) (map[string]zapcore.Level, error) {
+	parsed := make(map[string]zapcore.Level, len(levels))
+	for name, level := range levels {
+		l, err := zapcore.ParseLevel(level)
+		if err != nil {
+			return nil, fmt.Errorf("level of package %s: %w", name, err)
+		}
+		parsed[strings.Trim(name, "/")] = l
+	}
+	return parsed, nil
 }
@@ -0,0 +1,155 @@
+package log
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// configure configures the global logger to write to a file, and restores the previous one when the test ends.
+// The entries of the file are returned by the func.
+func configure(t *testing.T, config Config) func() []map[string]any {
+	t.Helper()
+	previous, level, levels := zap.L(), Level.Level(), packages.Load()
+	t.Cleanup(func() {
+		_ = Shutdown(t.Context())
+		zap.ReplaceGlobals(previous)
+		Level.SetLevel(level)
+		packages.Store(levels)
+	})
+
+	file := filepath.Join(t.TempDir(), "app.log")
+	config.Outputs = []string{file}
+	if err := Configure(config); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() []map[string]any {
+		_ = zap.L().Sync()
+		f, err := os.Open(file)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		var entries []map[string]any
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, entry)
+		}
+		return entries
+	}
+}
+
+func TestPackageLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		packages map[string]string
+		logged   []string
+	}{
+		{name: "global level", level: "info", logged: []string{"info", "warn"}},
+		{name: "more verbose package", level: "info", packages: map[string]string{"pkg/log": "debug"}, logged: []string{"debug", "info", "warn"}},
+		{name: "quieter package", level: "debug", packages: map[string]string{"golang-fiber-poc/pkg/log": "warn"}, logged: []string{"warn"}},
+		{name: "other package", level: "info", packages: map[string]string{"infra/couchbase": "debug"}, logged: []string{"info", "warn"}},
+		{name: "longest package wins", level: "info", packages: map[string]string{"pkg": "debug", "pkg/log": "error"}, logged: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := configure(t, Config{Level: tt.level, Packages: tt.packages})
+
+			zap.L().Debug("debug")
+			zap.L().Info("info")
+			zap.L().Warn("warn")
+
+			var logged []string
+			for _, entry := range entries() {
+				logged = append(logged, entry["msg"].(string))
+			}
+			if len(logged) != len(tt.logged) {
+				t.Fatalf("logged %v, want %v", logged, tt.logged)
+			}
+			for i := range logged {
+				if logged[i] != tt.logged[i] {
+					t.Fatalf("logged %v, want %v", logged, tt.logged)
+				}
+			}
+		})
+	}
+}
+
+func TestSetPackageLevel(t *testing.T) {
+	entries := configure(t, Config{Level: "warn"})
+
+	if err := SetPackageLevel("pkg/log", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	zap.L().Debug("enabled")
+	if err := SetPackageLevel("pkg/log", ""); err != nil {
+		t.Fatal(err)
+	}
+	zap.L().Debug("disabled")
+	if err := SetPackageLevel("pkg/log", "verbose"); err == nil {
+		t.Error("an unknown level was accepted")
+	}
+
+	var messages []string
+	for _, entry := range entries() {
+		if msg := entry["msg"].(string); msg == "enabled" || msg == "disabled" {
+			messages = append(messages, msg)
+		}
+	}
+	if len(messages) != 1 || messages[0] != "enabled" {
+		t.Errorf("logged %v, want only enabled", messages)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	entries := configure(t, Config{Redact: []string{"password", "Authorization"}})
+
+	zap.L().With(zap.String("authorization", "Basic abc")).Info("request",
+		zap.String("Password", "secret"),
+		zap.Int("password", 42),
+		zap.String("user", "alice"),
+	)
+
+	logged := entries()
+	if len(logged) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(logged))
+	}
+	want := map[string]any{"authorization": redacted, "Password": redacted, "password": redacted, "user": "alice"}
+	for key, value := range want {
+		if logged[0][key] != value {
+			t.Errorf("%s %v, want %v", key, logged[0][key], value)
+		}
+	}
+}
+
+func TestConfigureRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "level", config: Config{Level: "verbose"}},
+		{name: "package level", config: Config{Packages: map[string]string{"infra/couchbase": "verbose"}}},
+		{name: "format", config: Config{Format: "xml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Configure(tt.config); err == nil {
+				t.Error("the config was accepted")
+			}
+		})
+	}
+}
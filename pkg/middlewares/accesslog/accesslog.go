@@ -0,0 +1,142 @@
+package accesslog
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"golang-fiber-poc/pkg/log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type Config struct {
+	// Next defines a function to skip this middleware when returned true
+	Next func(c *fiber.Ctx) bool
+
+	// SampleRate is the share of the successful requests that are logged, from 0 to 1. Failed and slow
+	// requests are always logged.
+	//
+	// Optional. Default: 1
+	SampleRate float64
+
+	// SlowThreshold makes requests that take longer slow, 0 disables it
+	//
+	// Optional. Default: 0
+	SlowThreshold time.Duration
+
+	// RequestIDHeader carries the id of the request, it is generated when the client does not send one
+	//
+	// Optional. Default: "X-Request-ID"
+	RequestIDHeader string
+}
+
+var ConfigDefault = Config{
+	SampleRate:      1,
+	RequestIDHeader: fiber.HeaderXRequestID,
+}
+
+func configDefault(config Config) Config {
+	if config.SampleRate < 0 {
+		config.SampleRate = 0
+	}
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = ConfigDefault.RequestIDHeader
+	}
+	return config
+}
+
+// Logger logs a line per request and gives the handlers a logger with the id of the request, see
+// log.FromContext. The sampling can be changed while requests are logged.
+type Logger struct {
+	mu     sync.Mutex
+	config Config
+}
+
+func New(config Config) *Logger {
+	return &Logger{config: configDefault(config)}
+}
+
+// SetSampling replaces SampleRate and SlowThreshold
+func (l *Logger) SetSampling(sampleRate float64, slowThreshold time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	config := l.config
+	config.SampleRate, config.SlowThreshold = sampleRate, slowThreshold
+	l.config = configDefault(config)
+}
+
+// Handler creates the middleware
+func (l *Logger) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		l.mu.Lock()
+		config := l.config
+		l.mu.Unlock()
+
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		start := time.Now()
+		requestID := utils.CopyString(c.Get(config.RequestIDHeader))
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		c.Set(config.RequestIDHeader, requestID)
+		c.SetUserContext(log.WithLogger(c.UserContext(), zap.L().With(zap.String("request_id", requestID))))
+
+		err := c.Next()
+
+		latency := time.Since(start)
+		status := statusCode(c, err)
+		slow := config.SlowThreshold > 0 && latency >= config.SlowThreshold
+
+		level := zapcore.InfoLevel
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = zapcore.ErrorLevel
+		case status >= fiber.StatusBadRequest, slow:
+			level = zapcore.WarnLevel
+		case config.SampleRate < 1 && rand.Float64() >= config.SampleRate:
+			return err
+		}
+
+		fields := []zap.Field{
+			zap.String("method", c.Method()),
+			zap.String("route", c.Route().Path),
+			zap.String("path", c.Path()),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.String("ip", c.IP()),
+		}
+		if slow {
+			fields = append(fields, zap.Bool("slow", true))
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+		// The stack of the middleware tells nothing about a failed request
+		log.FromContext(c.UserContext()).WithOptions(zap.AddStacktrace(zapcore.FatalLevel)).Log(level, "Handled request", fields...)
+		return err
+	}
+}
+
+// statusCode returns the status of the response, errors are written by the error handler after the
+// middlewares return
+func statusCode(c *fiber.Ctx, err error) int {
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &fiberErr):
+		return fiberErr.Code
+	case err != nil:
+		return fiber.StatusInternalServerError
+	default:
+		return c.Response().StatusCode()
+	}
+}
@@ -0,0 +1,138 @@
+package accesslog
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-fiber-poc/pkg/auth"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// observe replaces the global logger with one that records the entries until the test ends
+func observe(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(zapcore.DebugLevel)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core)))
+	return logs
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		path       string
+		requestID  string
+		handler    fiber.Handler
+		logged     bool
+		level      zapcore.Level
+		status     int
+		wantFields map[string]any
+	}{
+		{
+			name:       "success",
+			path:       "/products/1?token=secret",
+			requestID:  "abc",
+			handler:    func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) },
+			logged:     true,
+			level:      zapcore.InfoLevel,
+			status:     fiber.StatusOK,
+			wantFields: map[string]any{"method": "GET", "route": "/products/:id", "path": "/products/1", "request_id": "abc", "principal": "alice"},
+		},
+		{
+			name:    "client error",
+			path:    "/products/1",
+			handler: func(c *fiber.Ctx) error { return fiber.NewError(fiber.StatusNotFound, "product not found") },
+			logged:  true,
+			level:   zapcore.WarnLevel,
+			status:  fiber.StatusNotFound,
+		},
+		{
+			name:    "server error",
+			path:    "/products/1",
+			handler: func(c *fiber.Ctx) error { return errors.New("database down") },
+			logged:  true,
+			level:   zapcore.ErrorLevel,
+			status:  fiber.StatusInternalServerError,
+		},
+		{
+			name:    "sampled out",
+			config:  Config{SampleRate: 0.000001},
+			path:    "/products/1",
+			handler: func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) },
+		},
+		{
+			name:       "slow",
+			config:     Config{SampleRate: 0.000001, SlowThreshold: time.Millisecond},
+			path:       "/products/1",
+			handler:    func(c *fiber.Ctx) error { time.Sleep(5 * time.Millisecond); return c.SendStatus(fiber.StatusOK) },
+			logged:     true,
+			level:      zapcore.WarnLevel,
+			status:     fiber.StatusOK,
+			wantFields: map[string]any{"slow": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := observe(t)
+			app := fiber.New()
+			if tt.config.SampleRate == 0 {
+				tt.config.SampleRate = 1
+			}
+			app.Use(New(tt.config).Handler())
+			app.Get("/products/:id", func(c *fiber.Ctx) error {
+				c.SetUserContext(auth.WithPrincipal(c.UserContext(), "alice"))
+				return c.Next()
+			}, tt.handler)
+
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			if tt.requestID != "" {
+				req.Header.Set(fiber.HeaderXRequestID, tt.requestID)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Header.Get(fiber.HeaderXRequestID) == "" {
+				t.Error("no request id in the response")
+			}
+
+			entries := logs.FilterMessage("Handled request").All()
+			if !tt.logged {
+				if len(entries) != 0 {
+					t.Fatalf("logged %d entries, want none", len(entries))
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.Level != tt.level {
+				t.Errorf("level %s, want %s", entry.Level, tt.level)
+			}
+			fields := entry.ContextMap()
+			if fields["status"] != int64(tt.status) {
+				t.Errorf("status %v, want %d", fields["status"], tt.status)
+			}
+			for key, want := range tt.wantFields {
+				if fields[key] != want {
+					t.Errorf("%s %v, want %v", key, fields[key], want)
+				}
+			}
+			if _, ok := fields["latency"]; !ok {
+				t.Error("no latency")
+			}
+			if logged := fmt.Sprint(fields); strings.Contains(logged, "secret") {
+				t.Errorf("the query string was logged: %s", logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.uber.org/zap"
+)
+
+// Inspired by https://datatracker.ietf.org/doc/html/draft-ietf-httpapi-idempotency-key-header
+
+const replayedHeader = "Idempotent-Replayed"
+
+type Config struct {
+	// Next defines a function to skip this middleware when returned true
+	Next func(c *fiber.Ctx) bool
+
+	// Store keeps the recorded responses
+	Store Store
+
+	// KeyHeader is the name of the header that carries the idempotency key
+	KeyHeader string
+
+	// Lifetime is how long a recorded response is replayed for
+	Lifetime time.Duration
+
+	// WaitTimeout is how long a duplicate waits for the in-flight request to finish.
+	// When zero, duplicates of an in-flight request are rejected with 409 right away
+	WaitTimeout time.Duration
+
+	// PollInterval is how often a waiting duplicate checks the store
+	PollInterval time.Duration
+
+	// KeyScope returns a prefix that isolates keys of different callers.
+	// Defaults to the basic auth username
+	KeyScope func(c *fiber.Ctx) string
+
+	// MaxFingerprintBody is the largest body that is hashed into the fingerprint of a request. Larger bodies,
+	// and streamed ones of unknown length, are identified by their Content-Digest header so that they are not
+	// buffered. Without the header they are rejected with 413, since a different body could not be told apart.
+	//
+	// Optional. Default: 1 MiB
+	MaxFingerprintBody int
+
+	// StoreErrorStatus is the status of the requests whose key the store fails to acquire or read, e.g. 503
+	// while the store is unavailable, so that clients retry later
+	//
+	// Optional. Default: 500
+	StoreErrorStatus func(err error) int
+}
+
+var ConfigDefault = Config{
+	KeyHeader:    "Idempotency-Key",
+	Lifetime:     24 * time.Hour,
+	WaitTimeout:  0,
+	PollInterval: 50 * time.Millisecond,
+	KeyScope: func(c *fiber.Ctx) string {
+		username, _ := c.Locals("username").(string)
+		return username
+	},
+	MaxFingerprintBody: 1 << 20,
+	StoreErrorStatus: func(error) int {
+		return fiber.StatusInternalServerError
+	},
+}
+
+func configDefault(config Config) Config {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.KeyHeader == "" {
+		config.KeyHeader = ConfigDefault.KeyHeader
+	}
+	if config.Lifetime <= 0 {
+		config.Lifetime = ConfigDefault.Lifetime
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = ConfigDefault.PollInterval
+	}
+	if config.KeyScope == nil {
+		config.KeyScope = ConfigDefault.KeyScope
+	}
+	if config.MaxFingerprintBody <= 0 {
+		config.MaxFingerprintBody = ConfigDefault.MaxFingerprintBody
+	}
+	if config.StoreErrorStatus == nil {
+		config.StoreErrorStatus = ConfigDefault.StoreErrorStatus
+	}
+	return config
+}
+
+// New creates a middleware that records the first response for an idempotency key
+// and replays it for every retry carrying the same key
+func New(config Config) fiber.Handler {
+	cfg := configDefault(config)
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		if isSafeMethod(c.Method()) {
+			return c.Next()
+		}
+
+		key := utils.CopyString(c.Get(cfg.KeyHeader))
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": cfg.KeyHeader + " must be at most 255 characters"})
+		}
+
+		storeKey := cfg.KeyScope(c) + ":" + key
+		fingerprint, ok := fingerprint(c, cfg.MaxFingerprintBody)
+		if !ok {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "requests with an " + cfg.KeyHeader + " larger than " + strconv.Itoa(cfg.MaxFingerprintBody) + " bytes need a Content-Digest header"})
+		}
+		ctx := c.UserContext()
+
+		existing, acquired, err := cfg.Store.Acquire(ctx, storeKey, &Record{Fingerprint: fingerprint}, cfg.Lifetime)
+		if err != nil {
+			zap.L().Error("Failed to acquire idempotency key", zap.Error(err))
+			return c.Status(cfg.StoreErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if !acquired {
+			if existing.Fingerprint != fingerprint {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": cfg.KeyHeader + " was already used with a different request"})
+			}
+
+			if !existing.Completed && cfg.WaitTimeout > 0 {
+				existing, err = waitForCompletion(c, cfg, storeKey)
+				if err != nil {
+					zap.L().Error("Failed to read idempotency key", zap.Error(err))
+					return c.Status(cfg.StoreErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+				}
+			}
+
+			if existing == nil || !existing.Completed {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "a request with the same " + cfg.KeyHeader + " is still being processed"})
+			}
+
+			return replay(c, existing)
+		}
+
+		if err := c.Next(); err != nil {
+			release(c, cfg, storeKey)
+			return err
+		}
+
+		// Server errors are not recorded so that the client can retry them, neither are failed negotiations,
+		// which are answered before the request is processed
+		statusCode := c.Response().StatusCode()
+		if statusCode >= fiber.StatusInternalServerError || statusCode == fiber.StatusNotAcceptable || statusCode == fiber.StatusUnsupportedMediaType {
+			release(c, cfg, storeKey)
+			return nil
+		}
+
+		record := &Record{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			StatusCode:  statusCode,
+			Headers:     c.GetRespHeaders(),
+			Body:        utils.CopyBytes(c.Response().Body()),
+		}
+		if err := cfg.Store.Save(ctx, storeKey, record, cfg.Lifetime); err != nil {
+			zap.L().Error("Failed to save idempotent response", zap.String("key", key), zap.Error(err))
+		}
+
+		return nil
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// fingerprint identifies the request payload so that a key reused for a different request can be detected.
+// It returns false for the bodies it cannot identify without buffering them.
+func fingerprint(c *fiber.Ctx, maxBody int) (string, bool) {
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(c.Method()), []byte(c.Path()), c.Request().URI().QueryString()} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+
+	length := c.Request().Header.ContentLength()
+	switch {
+	case length >= 0 && length <= maxBody:
+		hash.Write(c.Body())
+	case c.Get("Content-Digest") != "":
+		hash.Write([]byte(c.Get("Content-Digest")))
+	default:
+		return "", false
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+func waitForCompletion(c *fiber.Ctx, cfg Config, storeKey string) (*Record, error) {
+	ctx := c.UserContext()
+	deadline := time.NewTimer(cfg.WaitTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, nil
+		case <-ticker.C:
+			record, err := cfg.Store.Get(ctx, storeKey)
+			if err != nil {
+				return nil, err
+			}
+			// The original request failed and released the key
+			if record == nil || record.Completed {
+				return record, nil
+			}
+		}
+	}
+}
+
+func replay(c *fiber.Ctx, record *Record) error {
+	for header, values := range record.Headers {
+		for _, value := range values {
+			c.Response().Header.Add(header, value)
+		}
+	}
+	c.Set(replayedHeader, "true")
+	c.Status(record.StatusCode)
+	return c.Send(record.Body)
+}
+
+func release(c *fiber.Ctx, cfg Config, storeKey string) {
+	if err := cfg.Store.Delete(c.UserContext(), storeKey); err != nil {
+		zap.L().Error("Failed to release idempotency key", zap.Error(err))
+	}
+}
@@ -0,0 +1,182 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newApp(calls *int) *fiber.App {
+	app := fiber.New()
+	app.Use(New(Config{}))
+	app.Post("/products", func(c *fiber.Ctx) error {
+		*calls++
+		return c.Status(fiber.StatusCreated).SendString("created " + string(c.Body()))
+	})
+	return app
+}
+
+func send(t *testing.T, app *fiber.App, target, key, body string) (*http.Response, string) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestReplay(t *testing.T) {
+	calls := 0
+	app := newApp(&calls)
+
+	first, firstBody := send(t, app, "/products", "key", "a")
+	second, secondBody := send(t, app, "/products", "key", "a")
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.StatusCode != first.StatusCode || secondBody != firstBody {
+		t.Fatalf("replayed %d %q, want %d %q", second.StatusCode, secondBody, first.StatusCode, firstBody)
+	}
+	if second.Header.Get(replayedHeader) != "true" {
+		t.Fatalf("missing %s header", replayedHeader)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{name: "body", target: "/products", body: "b"},
+		{name: "query", target: "/products?dry=true", body: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			app := newApp(&calls)
+
+			send(t, app, "/products", "key", "a")
+			resp, _ := send(t, app, tt.target, "key", tt.body)
+
+			if resp.StatusCode != fiber.StatusUnprocessableEntity {
+				t.Fatalf("status %d, want %d", resp.StatusCode, fiber.StatusUnprocessableEntity)
+			}
+			if calls != 1 {
+				t.Fatalf("handler called %d times, want 1", calls)
+			}
+		})
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	app := fiber.New()
+	app.Use(New(Config{}))
+	var duplicate int
+	app.Post("/products", func(c *fiber.Ctx) error {
+		// A retry that arrives while the first request is still running
+		resp, _ := send(t, app, "/products", "key", "a")
+		duplicate = resp.StatusCode
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	send(t, app, "/products", "key", "a")
+
+	if duplicate != fiber.StatusConflict {
+		t.Fatalf("duplicate status %d, want %d", duplicate, fiber.StatusConflict)
+	}
+}
+
+// unavailableStore fails like a store whose database cannot be reached
+type unavailableStore struct {
+	Store
+}
+
+var errUnavailable = errors.New("unavailable")
+
+func (unavailableStore) Acquire(context.Context, string, *Record, time.Duration) (*Record, bool, error) {
+	return nil, false, errUnavailable
+}
+
+func TestStoreError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status func(err error) int
+		want   int
+	}{
+		{name: "default", want: fiber.StatusInternalServerError},
+		{name: "mapped", status: func(err error) int {
+			if errors.Is(err, errUnavailable) {
+				return fiber.StatusServiceUnavailable
+			}
+			return fiber.StatusInternalServerError
+		}, want: fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			app := fiber.New()
+			app.Use(New(Config{Store: unavailableStore{}, StoreErrorStatus: tt.status}))
+			app.Post("/products", func(c *fiber.Ctx) error {
+				calls++
+				return c.SendStatus(fiber.StatusCreated)
+			})
+
+			resp, _ := send(t, app, "/products", "key", "a")
+
+			if resp.StatusCode != tt.want || calls != 0 {
+				t.Fatalf("status %d after %d calls, want %d without calling the handler", resp.StatusCode, calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestLargeBody(t *testing.T) {
+	calls := 0
+	app := fiber.New()
+	app.Use(New(Config{MaxFingerprintBody: 4}))
+	app.Post("/products", func(c *fiber.Ctx) error {
+		calls++
+		return c.SendStatus(fiber.StatusCreated)
+	})
+	post := func(body, digest string) int {
+		req := httptest.NewRequest(fiber.MethodPost, "/products", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "key")
+		if digest != "" {
+			req.Header.Set("Content-Digest", digest)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if status := post("aaaaa", ""); status != fiber.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d without a digest, want %d", status, fiber.StatusRequestEntityTooLarge)
+	}
+	if status := post("aaaaa", "sha-256=:a:"); status != fiber.StatusCreated {
+		t.Fatalf("status %d, want %d", status, fiber.StatusCreated)
+	}
+	// A different body of the same size is told apart by its digest
+	if status := post("bbbbb", "sha-256=:b:"); status != fiber.StatusUnprocessableEntity {
+		t.Fatalf("status %d for another body, want %d", status, fiber.StatusUnprocessableEntity)
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,32 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// Record is what the middleware keeps for an idempotency key
+type Record struct {
+	Fingerprint string              `json:"fingerprint"`
+	Completed   bool                `json:"completed"`
+	StatusCode  int                 `json:"statusCode,omitempty"`
+	Headers     map[string][]string `json:"headers,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+// Store persists idempotency records. Implementations must make Acquire atomic
+// so that only one request can own a key at a time.
+type Store interface {
+	// Acquire reserves the key with an in-flight record. When the key is already taken
+	// it returns the existing record and false.
+	Acquire(ctx context.Context, key string, record *Record, lifetime time.Duration) (*Record, bool, error)
+
+	// Get returns the record for the key or nil when there is none
+	Get(ctx context.Context, key string) (*Record, error)
+
+	// Save overwrites the record for the key
+	Save(ctx context.Context, key string, record *Record, lifetime time.Duration) error
+
+	// Delete releases the key so that the request can be retried
+	Delete(ctx context.Context, key string) error
+}
@@ -1,6 +1,9 @@
 package prometheus
 
 import (
+	"errors"
+	"golang-fiber-poc/pkg/tenant"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	"strconv"
@@ -11,22 +14,33 @@ var httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 	Name:    "http_request_duration_seconds",
 	Help:    "Duration of HTTP requests",
 	Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
-}, []string{"route", "method", "status"})
+}, []string{"route", "method", "status", "tenant"})
 
 func init() {
 	prometheus.MustRegister(httpRequestDuration)
 }
 
+// RequestDurationMiddleware observes the duration of every request, by tenant for the requests of a tenant
 func RequestDurationMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
 		err := c.Next()
 		duration := time.Since(start).Seconds()
-		status := strconv.Itoa(c.Response().StatusCode())
+		// Errors are written by the error handler after the middlewares return
+		code := c.Response().StatusCode()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			code = fiberErr.Code
+		} else if err != nil {
+			code = fiber.StatusInternalServerError
+		}
+		status := strconv.Itoa(code)
+		tenantID, _ := tenant.FromContext(c.UserContext())
 		httpRequestDuration.WithLabelValues(
 			c.Route().Path,
 			c.Method(),
 			status,
+			tenantID,
 		).Observe(duration)
 		return err
 	}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"golang-fiber-poc/pkg/auth"
+	"golang-fiber-poc/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor limits calls like Handler does HTTP requests. The client of a call is its principal,
+// or the address of its peer, and its group is its tenant. Methods for which skip returns true are not counted.
+func (l *Limiter) UnaryServerInterceptor(skip func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if skip == nil || !skip(info.FullMethod) {
+			if err := l.allow(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits the streams like UnaryServerInterceptor does the calls
+func (l *Limiter) StreamServerInterceptor(skip func(fullMethod string) bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skip == nil || !skip(info.FullMethod) {
+			if err := l.allow(ss.Context()); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// allow counts a call of the client of ctx and fails with ResourceExhausted over the limit
+func (l *Limiter) allow(ctx context.Context) error {
+	l.mu.Lock()
+	config := l.config
+	l.mu.Unlock()
+
+	group, _ := tenant.FromContext(ctx)
+	limit := config.limit(group)
+	if limit.Max <= 0 {
+		return nil
+	}
+
+	client, _ := auth.PrincipalFromContext(ctx)
+	if p, ok := peer.FromContext(ctx); client == "" && ok && p.Addr != nil {
+		client = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+	}
+
+	remaining, reset, ok := l.take(group+"/"+client, limit)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(
+		"x-ratelimit-limit", strconv.Itoa(limit.Max),
+		"x-ratelimit-remaining", strconv.Itoa(remaining),
+		"x-ratelimit-reset", strconv.Itoa(int(reset.Seconds())),
+	))
+	if !ok {
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", reset)
+	}
+	return nil
+}
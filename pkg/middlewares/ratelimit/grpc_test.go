@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang-fiber-poc/pkg/auth"
+	"golang-fiber-poc/pkg/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	limiter := New(Config{Max: 2, Expiration: time.Minute})
+	interceptor := limiter.UnaryServerInterceptor(func(method string) bool { return method == "/grpc.health.v1.Health/Check" })
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	call := func(principal, method string) codes.Code {
+		ctx := auth.WithPrincipal(context.Background(), principal)
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return status.Code(err)
+	}
+
+	tests := []struct {
+		name      string
+		principal string
+		method    string
+		want      codes.Code
+	}{
+		{"first call", "alice", "/product.v1.ProductService/GetProduct", codes.OK},
+		{"second call", "alice", "/product.v1.ProductService/GetProduct", codes.OK},
+		{"over the limit", "alice", "/product.v1.ProductService/GetProduct", codes.ResourceExhausted},
+		{"skipped method", "alice", "/grpc.health.v1.Health/Check", codes.OK},
+		{"other client", "bob", "/product.v1.ProductService/GetProduct", codes.OK},
+	}
+	for _, tt := range tests {
+		if got := call(tt.principal, tt.method); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnaryServerInterceptorTenantLimits(t *testing.T) {
+	limiter := New(Config{Max: 1, Expiration: time.Minute, Groups: map[string]Limit{"acme": {Max: 3}, "free": {Max: 0}}})
+	interceptor := limiter.UnaryServerInterceptor(nil)
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	tests := []struct {
+		tenant string
+		calls  int
+		want   int
+	}{
+		{tenant: "", calls: 3, want: 1},
+		{tenant: "acme", calls: 5, want: 3},
+		{tenant: "globex", calls: 3, want: 1},
+		{tenant: "free", calls: 5, want: 5},
+	}
+	for _, tt := range tests {
+		ctx := auth.WithPrincipal(context.Background(), "alice")
+		if tt.tenant != "" {
+			ctx = tenant.WithTenant(ctx, tt.tenant)
+		}
+		allowed := 0
+		for range tt.calls {
+			if _, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/product.v1.ProductService/GetProduct"}, handler); err == nil {
+				allowed++
+			}
+		}
+		if allowed != tt.want {
+			t.Errorf("tenant %q: allowed %d calls, want %d", tt.tenant, allowed, tt.want)
+		}
+	}
+}
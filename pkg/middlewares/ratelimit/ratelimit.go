@@ -0,0 +1,182 @@
+package ratelimit
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"golang-fiber-poc/pkg/tenant"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Limit is the number of requests a client may send per Expiration, it is disabled when Max is 0
+type Limit struct {
+	Max        int
+	Expiration time.Duration
+}
+
+type Config struct {
+	// Next defines a function to skip this middleware when returned true
+	Next func(c *fiber.Ctx) bool
+
+	// Max is the number of requests a client may send per Expiration, the limit is disabled when it is 0
+	Max int
+
+	// Expiration is the window the requests are counted in
+	Expiration time.Duration
+
+	// KeyGenerator returns the client a request is counted for.
+	// Defaults to the basic auth username, or the IP of anonymous requests
+	KeyGenerator func(c *fiber.Ctx) string
+
+	// Group returns the group of a request, the clients of a group are counted apart from other groups and
+	// against the limit of the group in Groups, or Max when it has none.
+	// Defaults to the tenant of the request
+	Group func(c *fiber.Ctx) string
+
+	// Groups overrides the limit of groups
+	//
+	// Optional. Default: nil
+	Groups map[string]Limit
+}
+
+var ConfigDefault = Config{
+	Max:        0,
+	Expiration: time.Minute,
+	KeyGenerator: func(c *fiber.Ctx) string {
+		if username, ok := c.Locals("username").(string); ok && username != "" {
+			return username
+		}
+		return c.IP()
+	},
+	Group: func(c *fiber.Ctx) string {
+		id, _ := tenant.FromContext(c.UserContext())
+		return id
+	},
+}
+
+func configDefault(config Config) Config {
+	if config.Expiration <= 0 {
+		config.Expiration = ConfigDefault.Expiration
+	}
+	if config.KeyGenerator == nil {
+		config.KeyGenerator = ConfigDefault.KeyGenerator
+	}
+	if config.Group == nil {
+		config.Group = ConfigDefault.Group
+	}
+	return config
+}
+
+// Limiter counts the requests of every client in fixed windows and rejects them with 429 over the limit.
+// The limit can be changed while requests are counted.
+type Limiter struct {
+	mu      sync.Mutex
+	config  Config
+	windows map[string]*window
+	swept   time.Time
+}
+
+type window struct {
+	count int
+	reset time.Time
+}
+
+func New(config Config) *Limiter {
+	return &Limiter{config: configDefault(config), windows: make(map[string]*window)}
+}
+
+// SetLimit replaces Max and Expiration, the counts start over
+func (l *Limiter) SetLimit(max int, expiration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	config := l.config
+	config.Max, config.Expiration = max, expiration
+	l.config = configDefault(config)
+	l.windows = make(map[string]*window)
+}
+
+// SetGroups replaces Groups, the counts start over
+func (l *Limiter) SetGroups(groups map[string]Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config.Groups = groups
+	l.windows = make(map[string]*window)
+}
+
+// limit returns the limit of a group
+func (c Config) limit(group string) Limit {
+	limit, ok := c.Groups[group]
+	if !ok {
+		return Limit{Max: c.Max, Expiration: c.Expiration}
+	}
+	if limit.Expiration <= 0 {
+		limit.Expiration = c.Expiration
+	}
+	return limit
+}
+
+// Handler creates the middleware
+func (l *Limiter) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		l.mu.Lock()
+		config := l.config
+		l.mu.Unlock()
+
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+		group := config.Group(c)
+		limit := config.limit(group)
+		if limit.Max <= 0 {
+			return c.Next()
+		}
+
+		remaining, reset, ok := l.take(group+"/"+config.KeyGenerator(c), limit)
+		c.Set("X-RateLimit-Limit", strconv.Itoa(limit.Max))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("X-RateLimit-Reset", strconv.Itoa(int(reset.Seconds())))
+		if !ok {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(reset.Seconds())))
+			return fiber.NewError(fiber.StatusTooManyRequests, "rate limit exceeded")
+		}
+		return c.Next()
+	}
+}
+
+// take counts a request of key, it returns the requests left in the window and when the window resets
+func (l *Limiter) take(key string, limit Limit) (int, time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	current, ok := l.windows[key]
+	if !ok || !now.Before(current.reset) {
+		current = &window{reset: now.Add(limit.Expiration)}
+		l.windows[key] = current
+	}
+	reset := current.reset.Sub(now).Round(time.Second)
+	if current.count >= limit.Max {
+		return 0, reset, false
+	}
+	current.count++
+	return limit.Max - current.count, reset, true
+}
+
+// sweep drops the windows that ended, at most once per Expiration
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.config.Expiration {
+		return
+	}
+	l.swept = now
+	for key, current := range l.windows {
+		if !now.Before(current.reset) {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Record is a message waiting in the outbox. Records are written in the same transaction as the change
+// they describe and deleted once the relay has published them.
+type Record struct {
+	ID string `json:"id"`
+	// Key orders the records, records with the same key are published one after the other. It is the key of
+	// the message.
+	Key           string          `json:"key"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	OccurredAt    time.Time       `json:"occurredAt"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+}
+
+func NewRecord(id, key, recordType string, occurredAt time.Time, payload any) (Record, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Record{}, err
+	}
+
+	return Record{
+		ID:         id,
+		Key:        key,
+		Type:       recordType,
+		Payload:    data,
+		OccurredAt: occurredAt,
+	}, nil
+}
+
+type Store interface {
+	// Pending returns the records of every key in the order they were written. The keys that have a
+	// record waiting for a retry are skipped.
+	Pending(ctx context.Context, limit int) ([]Record, error)
+	Delete(ctx context.Context, id string) error
+	// Retry schedules the next attempt of a record that failed to publish
+	Retry(ctx context.Context, id string, attempts int, next time.Time) error
+	// Lock makes owner the only relay for ttl, or returns false when another relay holds the lock. The owner
+	// calls it again to extend the lock.
+	Lock(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+}
@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/broker"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Config defines the config for the relay
+type Config struct {
+	// Topic receives the records
+	Topic string
+
+	// BatchSize is the number of records read from the store at once
+	//
+	// Optional. Default: 100
+	BatchSize int
+
+	// Interval is the pause between polls of the store
+	//
+	// Optional. Default: 1 * time.Second
+	Interval time.Duration
+
+	// MaxBackoff caps the delay between attempts of a failing record
+	//
+	// Optional. Default: 1 * time.Minute
+	MaxBackoff time.Duration
+
+	// LockTTL is how long a relay stays the only one publishing without renewing its lock
+	//
+	// Optional. Default: 10 * Interval
+	LockTTL time.Duration
+}
+
+// Relay publishes outbox records to a broker. Delivery is at least once: a record is deleted only
+// after the broker accepted it. A record that fails is retried with backoff, and the records with the
+// same key wait for it, so that they keep their order.
+type Relay struct {
+	store     Store
+	publisher broker.Publisher
+	config    Config
+	owner     string
+}
+
+func NewRelay(store Store, publisher broker.Publisher, config Config) *Relay {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = 10 * config.Interval
+	}
+
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		config:    config,
+		owner:     uuid.New().String(),
+	}
+}
+
+// Run polls the store until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.relay(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relay drains the store while it holds the lock, which it renews before every batch so that a long drain
+// does not outlive it
+func (r *Relay) relay(ctx context.Context) {
+	for ctx.Err() == nil {
+		locked, err := r.store.Lock(ctx, r.owner, r.config.LockTTL)
+		if err != nil {
+			zap.L().Error("Failed to lock outbox", zap.Error(err))
+			return
+		}
+		if !locked {
+			return
+		}
+
+		records, err := r.store.Pending(ctx, r.config.BatchSize)
+		if err != nil {
+			zap.L().Error("Failed to read outbox", zap.Error(err))
+			return
+		}
+
+		if published := r.publish(ctx, records); published == 0 || len(records) < r.config.BatchSize {
+			return
+		}
+	}
+}
+
+// publish sends the records in order and returns how many were published
+func (r *Relay) publish(ctx context.Context, records []Record) int {
+	now := time.Now()
+	blocked := make(map[string]bool)
+	published := 0
+
+	for _, record := range records {
+		if blocked[record.Key] {
+			continue
+		}
+		if record.NextAttemptAt.After(now) {
+			blocked[record.Key] = true
+			continue
+		}
+
+		err := r.publisher.Publish(ctx, broker.Message{
+			ID:      record.ID,
+			Topic:   r.config.Topic,
+			Key:     record.Key,
+			Headers: map[string]string{"type": record.Type},
+			Value:   record.Payload,
+		})
+		if err != nil {
+			blocked[record.Key] = true
+			attempts := record.Attempts + 1
+			zap.L().Error("Failed to publish outbox record", zap.Error(err), zap.String("id", record.ID), zap.Int("attempts", attempts))
+			if err := r.store.Retry(ctx, record.ID, attempts, now.Add(r.backoff(attempts))); err != nil {
+				zap.L().Error("Failed to schedule outbox retry", zap.Error(err), zap.String("id", record.ID))
+			}
+			continue
+		}
+
+		// A record that is not deleted is published again, consumers drop the duplicate by its id
+		if err := r.store.Delete(ctx, record.ID); err != nil {
+			zap.L().Error("Failed to delete outbox record", zap.Error(err), zap.String("id", record.ID))
+			blocked[record.Key] = true
+			continue
+		}
+		published++
+	}
+
+	return published
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.config.Interval << min(attempts, 16)
+	return min(backoff, r.config.MaxBackoff)
+}
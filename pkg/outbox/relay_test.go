@@ -0,0 +1,166 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"golang-fiber-poc/domain"
+	"golang-fiber-poc/infra/memory"
+	"golang-fiber-poc/pkg/broker"
+	"golang-fiber-poc/pkg/outbox"
+	"golang-fiber-poc/pkg/tenant"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyPublisher fails the first attempt of the messages in fail
+type flakyPublisher struct {
+	*memory.Broker
+	mu   sync.Mutex
+	fail map[string]bool
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, message broker.Message) error {
+	p.mu.Lock()
+	failing := p.fail[message.ID]
+	delete(p.fail, message.ID)
+	p.mu.Unlock()
+	if failing {
+		return errors.New("broker unavailable")
+	}
+	return p.Broker.Publish(ctx, message)
+}
+
+// writeEvents creates and updates products a and b, and returns the ids of their events in order
+func writeEvents(t *testing.T, repository *memory.Repository) map[string][]string {
+	t.Helper()
+	ctx := context.Background()
+	ids := make(map[string][]string)
+	for _, id := range []string{"a", "b", "a", "b", "a"} {
+		product := &domain.Product{ID: id, Name: id}
+		var err error
+		var event *domain.ProductEvent
+		if len(ids[id]) == 0 {
+			event = domain.NewProductEvent(domain.ProductCreated, id, nil, product)
+			err = repository.CreateProduct(ctx, product, event)
+		} else {
+			event = domain.NewProductEvent(domain.ProductUpdated, id, nil, product)
+			err = repository.UpdateProduct(ctx, product, event)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[id] = append(ids[id], event.ID)
+	}
+	return ids
+}
+
+// relay runs a relay until every record is published, and returns the ids of the messages by key
+func relay(t *testing.T, repository *memory.Repository, publisher broker.Publisher, messages func() []broker.Message, count int) map[string][]string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	relay := outbox.NewRelay(memory.NewOutboxStore(repository), publisher, outbox.Config{
+		Topic:      "products",
+		Interval:   time.Millisecond,
+		MaxBackoff: 5 * time.Millisecond,
+	})
+	go relay.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(messages()) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("published %d messages, want %d", len(messages()), count)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	published := make(map[string][]string)
+	for _, message := range messages() {
+		published[message.Key] = append(published[message.Key], message.ID)
+	}
+	return published
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	repository := memory.NewRepository()
+	written := writeEvents(t, repository)
+	memoryBroker := memory.NewBroker()
+
+	published := relay(t, repository, memoryBroker, func() []broker.Message { return memoryBroker.Messages("products") }, 5)
+
+	for key, ids := range written {
+		if !slices.Equal(published[key], ids) {
+			t.Errorf("published %v for %s, want %v", published[key], key, ids)
+		}
+	}
+	if pending, _ := memory.NewOutboxStore(repository).Pending(context.Background(), 10); len(pending) != 0 {
+		t.Errorf("%d records left in the outbox", len(pending))
+	}
+}
+
+func TestRelayKeepsOrderOfRetriedRecords(t *testing.T) {
+	repository := memory.NewRepository()
+	written := writeEvents(t, repository)
+	publisher := &flakyPublisher{Broker: memory.NewBroker(), fail: map[string]bool{written["a"][0]: true}}
+
+	published := relay(t, repository, publisher, func() []broker.Message { return publisher.Messages("products") }, 5)
+
+	for key, ids := range written {
+		if !slices.Equal(published[key], ids) {
+			t.Errorf("published %v for %s, want %v", published[key], key, ids)
+		}
+	}
+}
+
+// expiringLock is a store whose lock is lost after the first batch
+type expiringLock struct {
+	*memory.OutboxStore
+	mu    sync.Mutex
+	locks int
+}
+
+func (s *expiringLock) Lock(context.Context, string, time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks++
+	return s.locks == 1, nil
+}
+
+func TestRelayStopsWhenTheLockIsLost(t *testing.T) {
+	repository := memory.NewRepository()
+	writeEvents(t, repository)
+	memoryBroker := memory.NewBroker()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	store := &expiringLock{OutboxStore: memory.NewOutboxStore(repository)}
+	outbox.NewRelay(store, memoryBroker, outbox.Config{Topic: "products", BatchSize: 2, Interval: time.Millisecond}).Run(ctx)
+
+	if published := len(memoryBroker.Messages("products")); published != 2 {
+		t.Errorf("published %d messages without the lock, want the 2 of the first batch", published)
+	}
+}
+
+func TestPendingBlocksTheProductOfOneTenant(t *testing.T) {
+	repository := memory.NewRepository()
+	store := memory.NewOutboxStore(repository)
+	ctx := context.Background()
+	for _, id := range []string{"acme", "globex"} {
+		product := &domain.Product{ID: "a", Name: "a"}
+		if err := repository.CreateProduct(tenant.WithTenant(ctx, id), product, domain.NewProductEvent(domain.ProductCreated, "a", nil, product)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, _ := store.Pending(ctx, 10)
+	if err := store.Retry(ctx, pending[0].ID, 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, _ = store.Pending(ctx, 10)
+	if len(pending) != 1 || pending[0].Key != "globex::a" {
+		t.Errorf("pending %v, want the record of globex::a", pending)
+	}
+}
@@ -0,0 +1,40 @@
+package tenant
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// New resolves the tenant of a request into the user context. Requests without a tenant use the shared
+// storage unless it is required.
+func New(config Config) fiber.Handler {
+	cfg := configDefault(config)
+
+	return func(c *fiber.Ctx) error {
+		id, err := cfg.resolve(c.UserContext(), func(name string) string { return c.Get(name) }, c.Hostname())
+		if err != nil {
+			return fiber.NewError(httpStatus(err), err.Error())
+		}
+		if id != "" {
+			c.SetUserContext(enter(c.UserContext(), id))
+		}
+		return c.Next()
+	}
+}
+
+// Header reads the tenant from a request header
+func Header(name string, known func(id string) bool) fiber.Handler {
+	return New(Config{Header: name, Known: known})
+}
+
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidToken), errors.Is(err, errExpiredToken), errors.Is(err, errMissingToken):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, errConflictingTenant), errors.Is(err, errForbiddenTenant):
+		return fiber.StatusForbidden
+	default:
+		return fiber.StatusBadRequest
+	}
+}
@@ -0,0 +1,54 @@
+package tenant
+
+import (
+	"context"
+	"golang-fiber-poc/pkg/auth"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAllowedTenants(t *testing.T) {
+	users := auth.Users{
+		"acme-admin": {Password: "password", Tenants: []string{"acme"}},
+		"admin":      {Password: "password", Tenants: []string{auth.AnyTenant}},
+		"shared":     {Password: "password"},
+	}
+	app := fiber.New()
+	app.Use(auth.BasicAuth(users), New(Config{
+		Header: "X-Tenant-ID",
+		Allowed: func(ctx context.Context, id string) bool {
+			principal, _ := auth.PrincipalFromContext(ctx)
+			return users.Allows(principal, id)
+		},
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		id, _ := FromContext(c.UserContext())
+		return c.SendString(id)
+	})
+
+	tests := []struct {
+		username string
+		tenant   string
+		status   int
+	}{
+		{username: "acme-admin", tenant: "ACME", status: fiber.StatusOK},
+		{username: "acme-admin", tenant: "globex", status: fiber.StatusForbidden},
+		{username: "admin", tenant: "globex", status: fiber.StatusOK},
+		{username: "shared", tenant: "acme", status: fiber.StatusForbidden},
+		{username: "shared", tenant: "", status: fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.SetBasicAuth(tt.username, "password")
+		req.Header.Set("X-Tenant-ID", tt.tenant)
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != tt.status {
+			t.Errorf("%s for tenant %q got %d, want %d", tt.username, tt.tenant, res.StatusCode, tt.status)
+		}
+	}
+}
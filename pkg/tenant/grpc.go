@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor resolves the tenant of a call from its metadata, like New does for HTTP
+func UnaryServerInterceptor(config Config, skip func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	cfg := configDefault(config)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if skip != nil && skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		get := func(name string) string {
+			if values := md.Get(name); len(values) > 0 {
+				return values[0]
+			}
+			return ""
+		}
+		id, err := cfg.resolve(ctx, get, get(":authority"))
+		if err != nil {
+			return nil, status.Error(grpcCode(err), err.Error())
+		}
+		if id != "" {
+			ctx = enter(ctx, id)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func grpcCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, errInvalidToken), errors.Is(err, errExpiredToken), errors.Is(err, errMissingToken):
+		return codes.Unauthenticated
+	case errors.Is(err, errConflictingTenant), errors.Is(err, errForbiddenTenant):
+		return codes.PermissionDenied
+	default:
+		return codes.InvalidArgument
+	}
+}
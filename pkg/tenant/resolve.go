@@ -0,0 +1,136 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	errMissingTenant     = errors.New("missing tenant")
+	errConflictingTenant = errors.New("conflicting tenants")
+	errForbiddenTenant   = errors.New("tenant not allowed")
+	errMissingToken      = errors.New("missing tenant token")
+)
+
+// Config defines how the tenant of a request is resolved. With a Claim the tenant is the one of the signed
+// token, which every request must send, and the subdomain and the header may only repeat it. Otherwise the
+// tenant is read from the subdomain and the header, which must agree.
+type Config struct {
+	// Header carries the tenant id
+	//
+	// Optional. Default: "" disables it
+	Header string
+
+	// Domain resolves the tenant of the requests to <tenant>.<Domain>
+	//
+	// Optional. Default: "" disables it
+	Domain string
+
+	// Claim is the claim of the JWT, signed with HS256 and Secret, that carries the tenant
+	//
+	// Optional. Default: "" disables it
+	Claim  string
+	Secret []byte
+
+	// TokenHeader carries the JWT, as "Bearer <token>"
+	//
+	// Optional. Default: "Authorization"
+	TokenHeader string
+
+	// Required rejects the requests without a tenant
+	//
+	// Optional. Default: false
+	Required bool
+
+	// Known tells whether a tenant exists, requests for other tenants are rejected
+	//
+	// Optional. Default: every tenant is known
+	Known func(id string) bool
+
+	// Allowed tells whether the principal on ctx may act for a tenant, requests for other tenants are rejected
+	//
+	// Optional. Default: every tenant is allowed
+	Allowed func(ctx context.Context, id string) bool
+}
+
+func configDefault(config Config) Config {
+	if config.TokenHeader == "" {
+		config.TokenHeader = "Authorization"
+	}
+	if config.Known == nil {
+		config.Known = func(string) bool { return true }
+	}
+	if config.Allowed == nil {
+		config.Allowed = func(context.Context, string) bool { return true }
+	}
+	config.Domain = strings.ToLower(strings.TrimPrefix(config.Domain, "."))
+	return config
+}
+
+// resolve reads the tenant of a request from its headers and host. Tenant ids are case-insensitive, like
+// the config keys they are looked up in.
+func (cfg Config) resolve(ctx context.Context, header func(name string) string, host string) (string, error) {
+	var id string
+	claimed := false
+	use := func(candidate string) error {
+		candidate = strings.ToLower(candidate)
+		switch {
+		case candidate == "":
+		case claimed && id != candidate:
+			return errConflictingTenant
+		case id == "":
+			id = strings.Clone(candidate)
+		case id != candidate:
+			return errConflictingTenant
+		}
+		return nil
+	}
+
+	if cfg.Claim != "" {
+		token, ok := strings.CutPrefix(header(cfg.TokenHeader), "Bearer ")
+		if !ok {
+			return "", errMissingToken
+		}
+		claim, err := claimFromJWT(token, cfg.Secret, cfg.Claim)
+		if err != nil {
+			return "", err
+		}
+		id, claimed = strings.ToLower(claim), true
+	}
+	if cfg.Domain != "" {
+		if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+			host = host[:i]
+		}
+		if subdomain, ok := strings.CutSuffix(strings.ToLower(host), "."+cfg.Domain); ok && !strings.Contains(subdomain, ".") {
+			if err := use(subdomain); err != nil {
+				return "", err
+			}
+		}
+	}
+	if cfg.Header != "" {
+		if err := use(header(cfg.Header)); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case id == "" && cfg.Required:
+		return "", errMissingTenant
+	case id != "" && !cfg.Known(id):
+		return "", fmt.Errorf("%w %s", ErrUnknownTenant, id)
+	case id != "" && !cfg.Allowed(ctx, id):
+		return "", fmt.Errorf("%w: %s", errForbiddenTenant, id)
+	}
+	return id, nil
+}
+
+// enter puts the tenant on the context and records it on the span of the request
+func enter(ctx context.Context, id string) context.Context {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", id))
+	return WithTenant(ctx, id)
+}
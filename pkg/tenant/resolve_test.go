@@ -0,0 +1,65 @@
+package tenant
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+var secret = []byte("secret")
+
+// sign returns a HS256 JWT with the claims
+func sign(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	unsigned := encode([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encode([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + encode(mac.Sum(nil))
+}
+
+func TestResolveClaim(t *testing.T) {
+	cfg := configDefault(Config{Header: "X-Tenant-ID", Domain: "example.com", Claim: "tenant", Secret: secret})
+	acme := "Bearer " + sign(`{"tenant":"acme"}`)
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		host   string
+		want   string
+		err    error
+	}{
+		{name: "claim", token: acme, host: "api.com", want: "acme"},
+		{name: "repeated by the header and subdomain", token: acme, header: "ACME", host: "acme.example.com", want: "acme"},
+		{name: "other header", token: acme, header: "globex", err: errConflictingTenant},
+		{name: "other subdomain", token: acme, host: "globex.example.com", err: errConflictingTenant},
+		{name: "header without token", header: "acme", err: errMissingToken},
+		{name: "forged token", token: "Bearer " + sign(`{"tenant":"acme"}`)[:20] + ".x.y", err: errInvalidToken},
+		{name: "header with a token of the shared storage", token: "Bearer " + sign(`{}`), header: "acme", err: errConflictingTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"Authorization": tt.token, "X-Tenant-ID": tt.header}
+			id, err := cfg.resolve(context.Background(), func(name string) string { return headers[name] }, tt.host)
+
+			if !errors.Is(err, tt.err) || id != tt.want {
+				t.Fatalf("resolved %q, %v, want %q, %v", id, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestResolveWithoutClaim(t *testing.T) {
+	cfg := configDefault(Config{Header: "X-Tenant-ID", Domain: "example.com"})
+
+	id, err := cfg.resolve(context.Background(), func(string) string { return "acme" }, "acme.example.com:8080")
+	if err != nil || id != "acme" {
+		t.Fatalf("resolved %q, %v, want acme", id, err)
+	}
+	if _, err = cfg.resolve(context.Background(), func(string) string { return "acme" }, "globex.example.com"); !errors.Is(err, errConflictingTenant) {
+		t.Fatalf("error %v, want conflicting tenants", err)
+	}
+}
@@ -0,0 +1,21 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnknownTenant is returned for a tenant that has no storage configured
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant of the request, requests without one use the shared storage
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok
+}
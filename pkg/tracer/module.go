@@ -0,0 +1,21 @@
+package tracer
+
+import (
+	"golang-fiber-poc/pkg/config"
+	"golang-fiber-poc/pkg/di"
+	"golang-fiber-poc/pkg/lifecycle"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Module provides the tracer provider, which exports the batched spans when it stops
+func Module(c *di.Container) {
+	di.Provide(c, func(c *di.Container) (*trace.TracerProvider, error) {
+		tp := InitTracer(di.MustGet[*config.AppConfig](c).Jaeger)
+		di.MustGet[*lifecycle.Manager](c).Append(lifecycle.Hook{
+			Name:   "tracer",
+			OnStop: tp.Shutdown,
+		})
+		return tp, nil
+	})
+}
@@ -3,6 +3,7 @@ package tracer
 import (
 	"context"
 	"golang-fiber-poc/pkg/config"
+	"net"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
@@ -46,3 +47,15 @@ func InitTracer(jaegerConfig config.JaegerConfig) *trace.TracerProvider {
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 	return tp
 }
+
+// Check tells whether the collector that spans are exported to can be reached
+func Check(jaegerConfig config.JaegerConfig) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", jaegerConfig.URL)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}